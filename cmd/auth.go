@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/fraser-isbester/cpr/internal/auth"
+	"github.com/spf13/cobra"
+)
+
+// Where cpr auth login stores tokens.
+const (
+	storeKeyring = "keyring"
+	storeGit     = "git"
+)
+
+var (
+	authHostname  string
+	authWithToken bool
+	authStore     string
+)
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Log in to GitHub and show where cpr's token comes from",
+	Long: `cpr looks for a GitHub token in this order:
+
+  1. GITHUB_TOKEN or GH_TOKEN, and GH_ENTERPRISE_TOKEN or
+     GITHUB_ENTERPRISE_TOKEN first for GitHub Enterprise Server
+  2. a GitHub App: GITHUB_APP_ID with GITHUB_APP_PRIVATE_KEY_PATH or
+     GITHUB_APP_PRIVATE_KEY, and GITHUB_APP_INSTALLATION_ID unless the app
+     is installed once; cpr exchanges the app's JWT for an installation token
+  3. the OS keyring, where cpr auth login stores tokens
+  4. gh auth token, when the gh CLI is installed, which finds gh's token in
+     the macOS Keychain, Windows Credential Manager, the keyring or hosts.yml
+  5. the gh CLI's hosts.yml, when gh is not installed
+  6. git's credential helpers, for https://<host>
+
+The host is github.com, or the server GITHUB_API_URL points at.`,
+}
+
+var authLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Store a GitHub token for cpr",
+	Long: `Store a GitHub token in the OS keyring, or with --store git in git's
+credential helper. The token is read from stdin with --with-token, or
+otherwise asked for without echoing it. It is checked with GitHub before
+being stored.`,
+	Example: `  echo "$TOKEN" | cpr auth login --with-token
+  cpr auth login --hostname ghe.example.com --store git`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := authLogin(cmd.Context(), cmd.Flags().Changed("store")); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show which token cpr uses and whether GitHub accepts it",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := authStatus(cmd.Context()); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+var authLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Remove the token cpr auth login stored",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := authLogout(cmd.Context()); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	authCmd.PersistentFlags().StringVar(&authHostname, "hostname", "", "GitHub host (default github.com, or GITHUB_API_URL's host)")
+	authLoginCmd.Flags().BoolVar(&authWithToken, "with-token", false, "Read the token from stdin")
+	authLoginCmd.Flags().StringVar(&authStore, "store", storeKeyring, "Where to store the token: keyring or git (the keyring falls back to git when unavailable)")
+	authCmd.AddCommand(authLoginCmd, authStatusCmd, authLogoutCmd)
+	rootCmd.AddCommand(authCmd)
+}
+
+// resolveCredential finds the token for the host of GITHUB_API_URL, or
+// github.com, and masks it in logs.
+func resolveCredential(ctx context.Context) (*auth.Credential, error) {
+	apiURL := os.Getenv("GITHUB_API_URL")
+	host := auth.HostFromAPIURL(apiURL)
+	cred, err := auth.DefaultChain(apiURL).Resolve(ctx, host)
+	if err != nil {
+		return nil, authError(err)
+	}
+	redactor.AddSecret(cred.Token)
+	logger.Debug("resolved GitHub token", "host", host, "source", cred.Source)
+	return cred, nil
+}
+
+// authHost returns the host cpr auth acts on and the REST API of that host.
+func authHost() (host, apiURL string) {
+	envURL := os.Getenv("GITHUB_API_URL")
+	if authHostname == "" || authHostname == auth.HostFromAPIURL(envURL) {
+		return auth.HostFromAPIURL(envURL), envURL
+	}
+	if authHostname == auth.DefaultHost {
+		return auth.DefaultHost, ""
+	}
+	return authHostname, "https://" + authHostname + "/api/v3/"
+}
+
+// authResult is what cpr auth login and status report.
+type authResult struct {
+	Host   string `json:"host" yaml:"host"`
+	User   string `json:"user,omitempty" yaml:"user,omitempty"`
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	Valid  bool   `json:"valid" yaml:"valid"`
+}
+
+func authLogin(ctx context.Context, storeChosen bool) error {
+	if authStore != storeKeyring && authStore != storeGit {
+		return usageErrorf("invalid --store %q, must be keyring or git", authStore)
+	}
+	host, apiURL := authHost()
+
+	token, err := readToken(ctx, host)
+	if err != nil {
+		return err
+	}
+	redactor.AddSecret(token)
+
+	login, err := checkToken(ctx, apiURL, token)
+	if err != nil {
+		return err
+	}
+
+	store := authStore
+	if store == storeKeyring {
+		err = auth.Keyring{}.Set(ctx, host, token)
+		if err != nil && !storeChosen {
+			logger.Warn("keyring unavailable, storing token with git credential helper", "error", err)
+			store = storeGit
+		}
+	}
+	if store == storeGit {
+		err = auth.GitCredential{}.Store(ctx, host, token)
+	}
+	if err != nil {
+		return err
+	}
+
+	result := authResult{Host: host, User: login, Source: store, Valid: true}
+	return printResult(result, func() {
+		fmt.Printf("Logged in to %s as %s, token stored in the %s\n", host, login, storeDescription(store))
+	})
+}
+
+// readToken reads a token from stdin with --with-token, or asks for one
+// in a terminal.
+func readToken(ctx context.Context, host string) (string, error) {
+	if !authWithToken && !isTerminal(os.Stdin) {
+		return "", usageErrorf("cannot ask for a token without a terminal, pipe one in with --with-token")
+	}
+
+	if !authWithToken {
+		fmt.Fprintf(messages(), "Paste a GitHub token for %s: ", host)
+		// Best effort: without stty the token is echoed
+		_ = stty("-echo")
+		defer func() {
+			_ = stty("echo")
+			fmt.Fprintln(messages())
+		}()
+	}
+
+	line, err := readLine(ctx, bufio.NewReader(os.Stdin))
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read token: %w", err)
+	}
+	token := strings.TrimSpace(line)
+	if token == "" {
+		return "", usageErrorf("no token given")
+	}
+	return token, nil
+}
+
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+// checkToken returns the login of the user token belongs to, or an auth
+// error if GitHub rejects it.
+func checkToken(ctx context.Context, apiURL, token string) (string, error) {
+	client, err := newClientForAPI(apiURL, token)
+	if err != nil {
+		return "", err
+	}
+	user, err := client.CurrentUser(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check token: %w", err)
+	}
+	return user.GetLogin(), nil
+}
+
+func storeDescription(store string) string {
+	if store == storeGit {
+		return "git credential helper"
+	}
+	return "keyring"
+}
+
+func authStatus(ctx context.Context) error {
+	host, apiURL := authHost()
+	cred, err := auth.DefaultChain(apiURL).Resolve(ctx, host)
+	if err != nil {
+		return authError(err)
+	}
+	redactor.AddSecret(cred.Token)
+
+	result := authResult{Host: host, Source: cred.Source}
+	login, err := checkToken(ctx, apiURL, cred.Token)
+	if err != nil {
+		err = fmt.Errorf("token from %s for %s: %w", cred.Source, host, err)
+		if structuredOutput() {
+			return &resultError{err: err, result: result}
+		}
+		return err
+	}
+	result.User = login
+	result.Valid = true
+
+	return printResult(result, func() {
+		fmt.Printf("%s\n", host)
+		fmt.Printf("  Logged in as: %s\n", login)
+		fmt.Printf("  Token from:   %s\n", cred.Source)
+	})
+}
+
+func authLogout(ctx context.Context) error {
+	host, apiURL := authHost()
+
+	keyringErr := auth.Keyring{}.Delete(ctx, host)
+	gitErr := auth.GitCredential{}.Erase(ctx, host)
+	if keyringErr != nil && gitErr != nil {
+		return errors.Join(keyringErr, gitErr)
+	}
+
+	// Tokens from the environment, gh or a GitHub App are not cpr's to remove
+	result := logoutResult{Host: host}
+	if cred, err := auth.DefaultChain(apiURL).Resolve(ctx, host); err == nil {
+		result.RemainingSource = cred.Source
+	}
+
+	return printResult(result, func() {
+		fmt.Printf("Logged out of %s\n", host)
+		if result.RemainingSource != "" {
+			fmt.Printf("A token from %s is still used\n", result.RemainingSource)
+		}
+	})
+}
+
+// logoutResult is what cpr auth logout reports.
+type logoutResult struct {
+	Host string `json:"host" yaml:"host"`
+	// RemainingSource is where cpr still finds a token for Host, if anywhere.
+	RemainingSource string `json:"remaining_source,omitempty" yaml:"remaining_source,omitempty"`
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fraser-isbester/cpr/internal/git"
+	"github.com/fraser-isbester/cpr/internal/github"
+	gogithub "github.com/google/go-github/v66/github"
+	"github.com/spf13/cobra"
+)
+
+var (
+	backportTargets []string
+	backportLabels  []string
+)
+
+var backportCmd = &cobra.Command{
+	Use:   "backport <pr-number|commit>",
+	Short: "Cherry-pick a PR or commit onto release branches and open PRs",
+	Long: `Backport a merged pull request or a single commit to one or more release
+branches. For each --to target, cpr creates a branch from origin's copy of
+the target, cherry-picks the commits, pushes it and opens a PR titled after
+the original, e.g. "fix(api): handle timeouts [backport 1.2]".
+
+Targets whose cherry-pick conflicts or whose PR cannot be opened are skipped
+and reported; the command exits non-zero if any target failed. Rerunning
+continues from backport branches an earlier run left without a PR.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := backport(cmd.Context(), args[0]); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	backportCmd.Flags().StringArrayVar(&backportTargets, "to", nil, "Target branch to backport to (repeatable)")
+	backportCmd.Flags().StringArrayVar(&backportLabels, "label", []string{"backport"}, "Label to add to backport PRs (repeatable)")
+	_ = backportCmd.MarkFlagRequired("to")
+	rootCmd.AddCommand(backportCmd)
+}
+
+func backport(ctx context.Context, ref string) error {
+	repo := openRepository()
+
+	owner, repoName, err := resolveRemote(repo)
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := checkAccess(ctx, client, owner, repoName); err != nil {
+		return err
+	}
+
+	var (
+		commits     []string
+		sourceTitle string
+		source      string
+		sourceID    string
+	)
+
+	if number, ok := parsePullRequestNumber(ref); ok {
+		pr, err := client.GetPullRequest(ctx, owner, repoName, number)
+		if err != nil {
+			return err
+		}
+
+		commits, err = client.GetPullRequestCommits(ctx, owner, repoName, number)
+		if err != nil {
+			return err
+		}
+		if len(commits) == 0 {
+			return gitStateErrorf("pull request #%d has no commits to backport besides merges", number)
+		}
+
+		// The PR's commits may only exist on its head ref
+		if err := repo.Fetch(fmt.Sprintf("pull/%d/head", number)); err != nil {
+			return err
+		}
+
+		sourceTitle = pr.GetTitle()
+		source = fmt.Sprintf("#%d", number)
+		sourceID = strconv.Itoa(number)
+	} else {
+		sourceTitle, err = repo.CommitSubject(ref)
+		if err != nil {
+			return err
+		}
+
+		commits = []string{ref}
+		source = ref
+		sourceID = ref
+		if len(sourceID) > 7 {
+			sourceID = sourceID[:7]
+		}
+	}
+
+	if err := repo.Fetch(backportTargets...); err != nil {
+		return err
+	}
+
+	result := &backportResult{Backports: []backportPR{}, Failed: []string{}}
+	for _, target := range backportTargets {
+		branch := fmt.Sprintf("backport/%s-to-%s", sourceID, strings.ReplaceAll(target, "/", "-"))
+
+		pr, created, err := backportTo(ctx, repo, client, owner, repoName, commits, target, branch)
+		if err != nil {
+			// Failures that would fail every target end the run
+			if ctx.Err() != nil || classify(err) == classAuth {
+				return &resultError{err: err, result: result}
+			}
+			fmt.Fprintf(messages(), "Skipping %s: %v\n", target, err)
+			result.Failed = append(result.Failed, target)
+			continue
+		}
+		if !created {
+			fmt.Fprintf(messages(), "Backport exists: %s\n", pr.GetHTMLURL())
+			result.Backports = append(result.Backports, backportPR{Target: target, Branch: branch, Number: pr.GetNumber(), URL: pr.GetHTMLURL()})
+			continue
+		}
+
+		prTitle := github.BackportTitle(sourceTitle, target)
+		prBody := fmt.Sprintf("Backport of %s to `%s`.\n\n## Commits\n\n", source, target)
+		for _, c := range commits {
+			prBody += fmt.Sprintf("- %s\n", c)
+		}
+
+		pr, err = client.CreatePullRequest(ctx, owner, repoName, prTitle, prBody, branch, target, false)
+		if err != nil {
+			if ctx.Err() != nil || classify(err) == classAuth {
+				return &resultError{err: err, result: result}
+			}
+			fmt.Fprintf(messages(), "Skipping %s: %v\n", target, err)
+			result.Failed = append(result.Failed, target)
+			continue
+		}
+
+		if err := client.AddLabels(ctx, owner, repoName, pr.GetNumber(), backportLabels...); err != nil {
+			logger.Warn("failed to label backport", "number", pr.GetNumber(), "err", err)
+		}
+
+		fmt.Fprintf(messages(), "Backport created: %s\n", pr.GetHTMLURL())
+		result.Backports = append(result.Backports, backportPR{Target: target, Branch: branch, Number: pr.GetNumber(), URL: pr.GetHTMLURL()})
+	}
+
+	if len(result.Failed) > 0 {
+		return &resultError{err: fmt.Errorf("backport failed for: %s", strings.Join(result.Failed, ", ")), result: result}
+	}
+
+	// Each backport was reported as it was created
+	return printResult(result, func() {})
+}
+
+// backportTo cherry-picks commits onto a new branch from target and pushes
+// it, ready for a pull request. A branch an earlier run left behind is
+// pushed again as it is; when it already has an open pull request, that is
+// returned instead, with created false.
+func backportTo(ctx context.Context, repo *git.Repository, client *github.Client, owner, name string, commits []string, target, branch string) (*gogithub.PullRequest, bool, error) {
+	logger.Info("cherry-picking", "commits", len(commits), "target", target, "branch", branch)
+
+	err := repo.Backport(commits, target, branch)
+	var exists *git.BranchExistsError
+	if !errors.As(err, &exists) {
+		return nil, true, err
+	}
+
+	pr, err := client.GetPullRequestForBranch(ctx, owner, name, branch)
+	if err != nil {
+		return nil, false, err
+	}
+	if pr != nil {
+		return pr, false, nil
+	}
+	logger.Info("continuing from existing branch", "branch", branch)
+	if err := repo.PushBranch(branch); err != nil {
+		return nil, false, err
+	}
+	return nil, true, nil
+}
+
+// backportResult is what cpr backport reports: the PRs it opened and the
+// targets it skipped because of conflicts or failures.
+type backportResult struct {
+	Backports []backportPR `json:"backports" yaml:"backports"`
+	Failed    []string     `json:"failed" yaml:"failed"`
+}
+
+type backportPR struct {
+	Target string `json:"target" yaml:"target"`
+	Branch string `json:"branch" yaml:"branch"`
+	Number int    `json:"number" yaml:"number"`
+	URL    string `json:"url" yaml:"url"`
+}
+
+// parsePullRequestNumber treats short numeric refs such as "123" or "#123" as
+// PR numbers rather than abbreviated commit hashes.
+func parsePullRequestNumber(ref string) (int, bool) {
+	ref = strings.TrimPrefix(ref, "#")
+	if len(ref) >= 7 {
+		return 0, false
+	}
+
+	number, err := strconv.Atoi(ref)
+	if err != nil || number <= 0 {
+		return 0, false
+	}
+	return number, true
+}
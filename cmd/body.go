@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+	"github.com/fraser-isbester/cpr/internal/config"
+	"github.com/fraser-isbester/cpr/internal/git"
+	"github.com/fraser-isbester/cpr/internal/render"
+)
+
+// loadTemplates reads the repository's body templates from .cpr/templates.
+func loadTemplates(repo *git.Repository) (*render.Templates, error) {
+	root, err := repo.Root()
+	if err != nil {
+		return nil, err
+	}
+	return render.Load(filepath.Join(root, render.Dir))
+}
+
+// newAnalyzer analyzes a diff and the branch's commits, leaving out the
+// files the repository's .gitattributes and noise config mark as generated
+// or vendored.
+func newAnalyzer(repo *git.Repository, cfg *config.Config, diff string, changedFiles []string, commits []commit.CommitInfo) (*commit.Analyzer, error) {
+	var attributes string
+	if files, err := repo.WorkingTree(); err == nil {
+		if attributes, _, err = files.ReadFile(".gitattributes"); err != nil {
+			return nil, err
+		}
+	}
+
+	filter, err := cfg.Noise.Filter(attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	analyzer := commit.NewAnalyzer(diff, changedFiles)
+	analyzer.SetLogger(logger)
+	analyzer.SetNoiseFilter(filter)
+	analyzer.SetCommits(commits)
+	return analyzer, nil
+}
+
+// branchCommits lists the commits from base to head, oldest first as they
+// read naturally in a PR body.
+func branchCommits(repo *git.Repository, base, head string) ([]commit.CommitInfo, error) {
+	commits, err := repo.CommitsBetween(base, head)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits: %w", err)
+	}
+
+	infos := make([]commit.CommitInfo, len(commits))
+	for i, c := range commits {
+		subject, rest, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+		infos[len(commits)-1-i] = commit.CommitInfo{
+			Hash:    c.Hash,
+			Subject: subject,
+			Body:    strings.TrimSpace(rest),
+		}
+	}
+	return infos, nil
+}
+
+// explainClassification prints the ranked commit types and the evidence
+// behind each score.
+func explainClassification(analyzer *commit.Analyzer) error {
+	scores := analyzer.Classify()
+
+	result := make([]typeScoreResult, len(scores))
+	for i, score := range scores {
+		result[i] = typeScoreResult{Type: string(score.Type), Score: score.Score, Confidence: score.Confidence, Evidence: []evidenceResult{}}
+		for _, e := range score.Evidence {
+			result[i].Evidence = append(result[i].Evidence, evidenceResult(e))
+		}
+	}
+
+	return printResult(result, func() {
+		fmt.Println("Commit type classification:")
+		for _, score := range scores {
+			fmt.Printf("  %-9s %3.0f%%  (score %.2f)\n", score.Type, score.Confidence*100, score.Score)
+			for _, e := range score.Evidence {
+				fmt.Printf("      +%.2f  %s\n", e.Weight, e.Reason)
+			}
+		}
+	})
+}
+
+// typeScoreResult is the structured form of a commit.TypeScore.
+type typeScoreResult struct {
+	Type       string           `json:"type" yaml:"type"`
+	Score      float64          `json:"score" yaml:"score"`
+	Confidence float64          `json:"confidence" yaml:"confidence"`
+	Evidence   []evidenceResult `json:"evidence" yaml:"evidence"`
+}
+
+type evidenceResult struct {
+	Weight float64 `json:"weight" yaml:"weight"`
+	Reason string  `json:"reason" yaml:"reason"`
+}
+
+// generateBody renders the repository's body template, or the default
+// summary if it has none.
+func generateBody(templates *render.Templates, analyzer *commit.Analyzer, data commit.BodyData) (string, error) {
+	if templates.Has(render.BodyTemplate) {
+		return templates.Execute(render.BodyTemplate, data)
+	}
+	return analyzer.GenerateSummary(), nil
+}
+
+// applyPullRequestTemplate places the body into the repository's PR
+// template. Templates with Go template actions are rendered with the body
+// data; others are filled in by section. Either way, checklists are ticked
+// and guidance comments stripped according to the template config.
+func applyPullRequestTemplate(cfg *config.Config, templates *render.Templates, prTemplate string, data commit.BodyData) (string, error) {
+	rules, err := cfg.Template.FillRules()
+	if err != nil {
+		return "", err
+	}
+
+	if render.IsTemplate(prTemplate) {
+		rendered, err := templates.Render(prTemplate, data)
+		if err == nil {
+			// The template decides where everything goes
+			rules.Sections = nil
+			return render.Fill(rendered, data, rules), nil
+		}
+		logger.Warn("failed to render PR template, filling it by section instead", "err", err)
+	}
+	return render.Fill(prTemplate, data, rules), nil
+}
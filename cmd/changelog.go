@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fraser-isbester/cpr/internal/changelog"
+	"github.com/fraser-isbester/cpr/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var (
+	changelogFrom    string
+	changelogTo      string
+	changelogVersion string
+	changelogFile    string
+	changelogWrite   bool
+	changelogPRs     bool
+	changelogAll     bool
+)
+
+var changelogCmd = &cobra.Command{
+	Use:   "changelog",
+	Short: "Generate release notes from Conventional commits or merged PRs",
+	Long: `Collect the commits between two refs, or the merged pull requests that
+contain them with --prs, and render them as a Keep a Changelog section.
+Changes are grouped by type and scope, and breaking changes are listed first.
+
+The section is printed to stdout, or prepended to CHANGELOG.md with --write.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := generateChangelog(cmd.Context()); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	changelogCmd.Flags().StringVar(&changelogFrom, "from", "", "Start of the range, e.g. the previous release tag (defaults to the whole history)")
+	changelogCmd.Flags().StringVar(&changelogTo, "to", "HEAD", "End of the range")
+	changelogCmd.Flags().StringVar(&changelogVersion, "version", "", "Release name for the heading (defaults to Unreleased)")
+	changelogCmd.Flags().StringVar(&changelogFile, "file", "CHANGELOG.md", "Changelog file used with --write")
+	changelogCmd.Flags().BoolVar(&changelogWrite, "write", false, "Prepend the section to the changelog file")
+	changelogCmd.Flags().BoolVar(&changelogPRs, "prs", false, "Use the titles of merged PRs instead of commit messages")
+	changelogCmd.Flags().BoolVar(&changelogAll, "all", false, "Include docs, test, build, CI and chore changes")
+	rootCmd.AddCommand(changelogCmd)
+}
+
+func generateChangelog(ctx context.Context) error {
+	repo := openRepository()
+
+	commits, err := repo.CommitsBetween(changelogFrom, changelogTo)
+	if err != nil {
+		return gitStateErrorf("failed to list commits: %w", err)
+	}
+
+	var entries []changelog.Entry
+	if changelogPRs {
+		entries, err = pullRequestEntries(ctx, repo, commits)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, c := range commits {
+			entry, err := changelog.NewEntry(c.Message, c.Hash)
+			if err != nil {
+				logger.Debug("skipping commit", "commit", c.Hash[:7], "err", err)
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	log := &changelog.Changelog{
+		Version:    changelogVersion,
+		Entries:    entries,
+		IncludeAll: changelogAll,
+	}
+	if changelogVersion != "" {
+		log.Date = time.Now()
+	}
+
+	section := log.Render()
+	result := changelogResult{Version: changelogVersion, Changes: len(entries), Markdown: section}
+
+	if !changelogWrite {
+		return printResult(result, func() {
+			fmt.Print(section)
+		})
+	}
+
+	existing, err := os.ReadFile(changelogFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", changelogFile, err)
+	}
+
+	if err := os.WriteFile(changelogFile, []byte(changelog.Prepend(string(existing), section)), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", changelogFile, err)
+	}
+
+	result.File = changelogFile
+	return printResult(result, func() {
+		fmt.Printf("Updated %s with %d changes\n", changelogFile, len(entries))
+	})
+}
+
+// changelogResult is what cpr changelog reports. File is the changelog
+// written with --write.
+type changelogResult struct {
+	Version  string `json:"version,omitempty" yaml:"version,omitempty"`
+	Changes  int    `json:"changes" yaml:"changes"`
+	Markdown string `json:"markdown" yaml:"markdown"`
+	File     string `json:"file,omitempty" yaml:"file,omitempty"`
+}
+
+// pullRequestEntries builds one entry per merged PR that contains any of the
+// commits.
+func pullRequestEntries(ctx context.Context, repo *git.Repository, commits []git.Commit) ([]changelog.Entry, error) {
+	owner, repoName, err := resolveRemote(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int]bool)
+	var entries []changelog.Entry
+	for _, c := range commits {
+		pulls, err := client.GetMergedPullRequestsForCommit(ctx, owner, repoName, c.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pr := range pulls {
+			if seen[pr.GetNumber()] {
+				continue
+			}
+			seen[pr.GetNumber()] = true
+
+			entry, err := changelog.NewEntry(pr.GetTitle()+"\n\n"+pr.GetBody(), fmt.Sprintf("#%d", pr.GetNumber()))
+			if err != nil {
+				logger.Debug("skipping pull request", "number", pr.GetNumber(), "err", err)
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
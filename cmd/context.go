@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/fraser-isbester/cpr/internal/github"
+	"github.com/spf13/cobra"
+)
+
+var (
+	timeout        time.Duration
+	requestTimeout time.Duration
+	cancelTimeout  context.CancelFunc = func() {}
+)
+
+func init() {
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Give up on the whole command after this long (0 for no limit)")
+	rootCmd.PersistentFlags().DurationVar(&requestTimeout, "request-timeout", github.DefaultRequestTimeout, "Give up on a single GitHub API request after this long (0 for no limit)")
+}
+
+// rootContext is canceled on Ctrl-C or SIGTERM, so API calls in flight are
+// abandoned instead of leaving the command hanging. Signals are only caught
+// once: should the command not stop, a second Ctrl-C kills it.
+func rootContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// readLine reads a line from r, giving up when ctx is canceled. A read
+// cannot be interrupted, so it is left to finish in the background.
+func readLine(ctx context.Context, r *bufio.Reader) (string, error) {
+	type read struct {
+		line string
+		err  error
+	}
+	done := make(chan read, 1)
+	go func() {
+		line, err := r.ReadString('\n')
+		done <- read{line, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case r := <-done:
+		return r.line, r.err
+	}
+}
+
+// applyTimeout bounds the running command's context by --timeout.
+func applyTimeout(cmd *cobra.Command, args []string) {
+	if timeout <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+	cmd.SetContext(ctx)
+	cancelTimeout = cancel
+}
@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/fraser-isbester/cpr/internal/auth"
+	"github.com/fraser-isbester/cpr/internal/git"
+	"github.com/fraser-isbester/cpr/internal/github"
+	"github.com/spf13/cobra"
+)
+
+// Outcomes of a doctor check.
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that cpr can work in this repository",
+	Long: `Run the checks cpr's main flow depends on and explain how to fix what
+fails: the git repository, current and default branch, the origin remote and
+the GitHub host it is on, the token and where it comes from, reaching the
+GitHub API, the token's permission to open pull requests, and pushing the
+current branch, tried with git push --dry-run as cpr pushes it.
+
+cpr doctor exits non-zero if any check fails. Warnings do not fail it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runDoctor(cmd.Context()); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// diagnosis is the outcome of one doctor check.
+type diagnosis struct {
+	Name    string `json:"name" yaml:"name"`
+	Status  string `json:"status" yaml:"status"`
+	Message string `json:"message" yaml:"message"`
+	Hint    string `json:"hint,omitempty" yaml:"hint,omitempty"`
+}
+
+// doctorResult is what cpr doctor reports.
+type doctorResult struct {
+	Checks   []diagnosis `json:"checks" yaml:"checks"`
+	Passed   int         `json:"passed" yaml:"passed"`
+	Warnings int         `json:"warnings" yaml:"warnings"`
+	Failures int         `json:"failures" yaml:"failures"`
+}
+
+func (r *doctorResult) add(name, status, message, hint string) {
+	r.Checks = append(r.Checks, diagnosis{Name: name, Status: status, Message: message, Hint: hint})
+	switch status {
+	case checkPass:
+		r.Passed++
+	case checkWarn:
+		r.Warnings++
+	case checkFail:
+		r.Failures++
+	}
+}
+
+func runDoctor(ctx context.Context) error {
+	result := &doctorResult{Checks: []diagnosis{}}
+	apiURL := os.Getenv("GITHUB_API_URL")
+
+	var (
+		branch      string
+		owner, name string
+		remoteHost  string
+	)
+	repo := openRepository()
+	if root, err := repo.Root(); err != nil {
+		result.add("git repository", checkFail, err.Error(), "run cpr inside the working tree of a git repository")
+	} else {
+		result.add("git repository", checkPass, root, "")
+		branch = checkCurrentBranch(result, repo)
+		checkDefaultBranch(result, repo)
+		owner, name, remoteHost = checkRemote(result, repo)
+	}
+
+	if remoteHost != "" {
+		checkHost(result, remoteHost, apiURL)
+	}
+
+	var client *github.Client
+	if cred := checkCredential(ctx, result, apiURL); cred != nil {
+		client = checkAPI(ctx, result, apiURL, cred)
+	}
+	if client != nil && owner != "" {
+		checkPermissions(ctx, result, client, owner, name)
+	}
+	if branch != "" && owner != "" {
+		checkPush(result, repo, branch)
+	}
+
+	if result.Failures == 0 {
+		return printResult(result, func() { printDiagnoses(result) })
+	}
+
+	err := fmt.Errorf("%d of %d checks failed", result.Failures, len(result.Checks))
+	if structuredOutput() {
+		return &resultError{err: err, result: result}
+	}
+	printDiagnoses(result)
+	return err
+}
+
+// checkCurrentBranch returns the branch checked out, or "" if there is none.
+func checkCurrentBranch(result *doctorResult, repo *git.Repository) string {
+	branch, err := repo.CurrentBranch()
+	switch {
+	case err != nil:
+		result.add("current branch", checkFail, err.Error(), "commit something first, cpr needs a branch with commits")
+		return ""
+	case branch == "HEAD":
+		result.add("current branch", checkFail, "detached HEAD", "check out the branch to open a pull request from with git switch <branch>")
+		return ""
+	}
+	result.add("current branch", checkPass, branch, "")
+	return branch
+}
+
+// checkDefaultBranch reports the branch pull requests target by default.
+func checkDefaultBranch(result *doctorResult, repo *git.Repository) {
+	branch, err := repo.DefaultBranch()
+	if err != nil {
+		result.add("default branch", checkFail, err.Error(), "run git remote set-head origin --auto, or pass --base")
+		return
+	}
+	if _, err := repo.BranchCommit(branch); err != nil {
+		result.add("default branch", checkWarn, fmt.Sprintf("%s is not available locally", branch),
+			fmt.Sprintf("fetch it with git fetch origin %s, cpr diffs against it", branch))
+		return
+	}
+	result.add("default branch", checkPass, branch, "")
+}
+
+// checkRemote returns the repository pull requests are opened on, upstream
+// when origin is a fork of it, and the host origin is on.
+func checkRemote(result *doctorResult, repo *git.Repository) (owner, name, host string) {
+	remoteURL, err := repo.GetRemoteURL()
+	if err != nil {
+		result.add("origin remote", checkFail, err.Error(), "add it with git remote add origin https://github.com/<owner>/<repo>.git")
+		return "", "", ""
+	}
+	result.add("origin remote", checkPass, remoteURL, "")
+
+	owner, name, err = github.ParseGitRemoteURL(remoteURL)
+	if err != nil {
+		result.add("remote URL", checkFail, fmt.Sprintf("cannot tell the repository from %s: %v", remoteURL, err),
+			"use a URL like https://<host>/<owner>/<repo>.git or git@<host>:<owner>/<repo>.git")
+		return "", "", ""
+	}
+
+	t, err := resolveTarget(repo)
+	if err != nil {
+		result.add("remote URL", checkFail, err.Error(), "check the URLs of origin and upstream with git remote -v")
+		return "", "", ""
+	}
+	message := owner + "/" + name
+	if t.owner != owner || t.name != name {
+		message += fmt.Sprintf(", a fork of %s/%s where pull requests are opened", t.owner, t.name)
+	}
+	result.add("remote URL", checkPass, message, "")
+	owner, name = t.owner, t.name
+
+	host, err = github.RemoteHost(remoteURL)
+	if err != nil {
+		return owner, name, ""
+	}
+	return owner, name, host
+}
+
+// checkHost compares the host origin is on with the one cpr's API calls go
+// to.
+func checkHost(result *doctorResult, remoteHost, apiURL string) {
+	apiHost := auth.HostFromAPIURL(apiURL)
+	switch {
+	case remoteHost == apiHost:
+		result.add("GitHub host", checkPass, remoteHost, "")
+	case apiURL == "":
+		result.add("GitHub host", checkFail, fmt.Sprintf("origin is on %s, but cpr talks to github.com", remoteHost),
+			fmt.Sprintf("for GitHub Enterprise Server, set GITHUB_API_URL=https://%s/api/v3/", remoteHost))
+	default:
+		result.add("GitHub host", checkWarn, fmt.Sprintf("origin is on %s, but GITHUB_API_URL points at %s", remoteHost, apiHost),
+			"check GITHUB_API_URL, unless it serves the API of origin's host")
+	}
+}
+
+// checkCredential returns the token cpr would use, if any.
+func checkCredential(ctx context.Context, result *doctorResult, apiURL string) *auth.Credential {
+	host := auth.HostFromAPIURL(apiURL)
+	cred, err := auth.DefaultChain(apiURL).Resolve(ctx, host)
+	var notFound *auth.NotFoundError
+	switch {
+	case errors.As(err, &notFound):
+		result.add("token", checkFail, fmt.Sprintf("no token for %s", host),
+			"set GITHUB_TOKEN, run 'cpr auth login' or 'gh auth login', or configure a GitHub App with GITHUB_APP_ID and GITHUB_APP_PRIVATE_KEY_PATH")
+		return nil
+	case err != nil:
+		result.add("token", checkFail, err.Error(), "fix or unset the source's configuration")
+		return nil
+	}
+	redactor.AddSecret(cred.Token)
+	result.add("token", checkPass, fmt.Sprintf("from %s", cred.Source), "")
+	return cred
+}
+
+// checkAPI returns a client once GitHub accepted the token.
+func checkAPI(ctx context.Context, result *doctorResult, apiURL string, cred *auth.Credential) *github.Client {
+	endpoint := apiURL
+	if endpoint == "" {
+		endpoint = auth.DefaultAPIURL
+	}
+
+	client, err := newClientForAPI(apiURL, cred.Token)
+	if err != nil {
+		result.add("GitHub API", checkFail, err.Error(), "set GITHUB_API_URL to the REST API's URL, e.g. https://<host>/api/v3/")
+		return nil
+	}
+	user, err := client.CurrentUser(ctx)
+	switch {
+	case err != nil && classify(err) == classAuth:
+		result.add("GitHub API", checkFail, fmt.Sprintf("%s rejected the token from %s", endpoint, cred.Source),
+			"the token may have expired or been revoked, run 'cpr auth login' with a new one")
+		return nil
+	case err != nil:
+		result.add("GitHub API", checkFail, err.Error(), "check your network and proxy settings, and GITHUB_API_URL")
+		return nil
+	}
+	result.add("GitHub API", checkPass, fmt.Sprintf("authenticated as %s at %s", user.GetLogin(), endpoint), "")
+	return client
+}
+
+// checkPermissions reports whether the token may open pull requests, as far
+// as GitHub tells without opening one.
+func checkPermissions(ctx context.Context, result *doctorResult, client *github.Client, owner, name string) {
+	access, err := client.CheckPullRequestAccess(ctx, owner, name)
+	var accessErr *github.AccessError
+	switch {
+	case errors.As(err, &accessErr):
+		result.add("token permissions", checkFail, err.Error(), accessHint(accessErr))
+		return
+	case err != nil:
+		result.add("token permissions", checkWarn, fmt.Sprintf("could not check: %v", err), "")
+		return
+	}
+
+	if !access.PullRequestsWritable {
+		result.add("token permissions", checkWarn,
+			fmt.Sprintf("cannot verify that the fine-grained token may open pull requests on %s, GitHub only tells when one is opened", access.Repo),
+			"make sure the token has the Pull requests: write permission on the repository")
+		return
+	}
+	result.add("token permissions", checkPass,
+		fmt.Sprintf("classic token with scopes %s may open pull requests on %s", github.FormatScopes(access.Scopes), access.Repo), "")
+}
+
+// checkPush tries pushing branch to origin without changing anything.
+func checkPush(result *doctorResult, repo *git.Repository, branch string) {
+	if err := repo.PushDryRun(branch); err != nil {
+		result.add("push", checkFail, err.Error(),
+			fmt.Sprintf("set up git's credentials for origin, then retry git push --dry-run origin %s", branch))
+		return
+	}
+	result.add("push", checkPass, fmt.Sprintf("can push %s to origin", branch), "")
+}
+
+func printDiagnoses(result *doctorResult) {
+	for _, d := range result.Checks {
+		fmt.Printf("[%s] %s: %s\n", d.Status, d.Name, d.Message)
+		if d.Hint != "" {
+			fmt.Printf("       %s\n", d.Hint)
+		}
+	}
+	fmt.Printf("\n%d passed, %d warnings, %d failed\n", result.Passed, result.Warnings, result.Failures)
+}
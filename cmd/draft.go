@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fraser-isbester/cpr/internal/github"
+	gogithub "github.com/google/go-github/v66/github"
+	"github.com/spf13/cobra"
+)
+
+var keepDraftOnFailure bool
+
+var readyCmd = &cobra.Command{
+	Use:   "ready",
+	Short: "Mark the current branch's PR as ready for review",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := changeDraftState(cmd.Context(), false); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+var draftCmd = &cobra.Command{
+	Use:   "draft",
+	Short: "Convert the current branch's PR back to a draft",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := changeDraftState(cmd.Context(), true); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.Flags().BoolVar(&keepDraftOnFailure, "keep-draft-on-failure", false, "Keep the PR in draft while its checks are failing")
+	readyCmd.Flags().BoolVar(&keepDraftOnFailure, "keep-draft-on-failure", false, "Keep the PR in draft while its checks are failing")
+	rootCmd.AddCommand(readyCmd)
+	rootCmd.AddCommand(draftCmd)
+}
+
+func changeDraftState(ctx context.Context, toDraft bool) error {
+	bc, err := newBranchContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	pr, err := bc.pullRequest(ctx)
+	if err != nil {
+		return err
+	}
+
+	pr, err = setDraftState(ctx, bc.client, bc.owner, bc.name, pr.GetNumber(), toDraft)
+	if err != nil {
+		return err
+	}
+
+	result := draftResult{Number: pr.GetNumber(), URL: pr.GetHTMLURL(), Draft: pr.GetDraft()}
+	return printResult(result, func() {
+		if toDraft {
+			fmt.Printf("Pull request converted to draft: %s\n", pr.GetHTMLURL())
+		} else {
+			fmt.Printf("Pull request ready for review: %s\n", pr.GetHTMLURL())
+		}
+	})
+}
+
+// draftResult is what cpr draft and cpr ready report.
+type draftResult struct {
+	Number int    `json:"number" yaml:"number"`
+	URL    string `json:"url" yaml:"url"`
+	Draft  bool   `json:"draft" yaml:"draft"`
+}
+
+// setDraftState flips the draft state of a pull request. With
+// --keep-draft-on-failure, a PR whose checks are failing stays in draft.
+func setDraftState(ctx context.Context, client *github.Client, owner, repo string, number int, toDraft bool) (*gogithub.PullRequest, error) {
+	if !toDraft && keepDraftOnFailure {
+		status, err := client.GetPullRequestStatus(ctx, owner, repo, number)
+		if err != nil {
+			return nil, err
+		}
+		if status.Checks.State() == github.CheckStateFailure {
+			return nil, fmt.Errorf("keeping pull request #%d in draft: %d checks failing", number, status.Checks.Failure)
+		}
+	}
+
+	return client.SetDraft(ctx, owner, repo, number, toDraft)
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+	"github.com/fraser-isbester/cpr/internal/github"
+	gogithub "github.com/google/go-github/v66/github"
+)
+
+// Exit codes are part of cpr's interface for scripts; keep them stable.
+const (
+	exitError       = 1   // anything not classified below
+	exitUsage       = 2   // invalid flags or input, e.g. a title breaking the rules
+	exitAuth        = 3   // no token, or GitHub rejected it
+	exitGitState    = 4   // the repository is not in a state cpr can work from
+	exitAPI         = 5   // GitHub answered with an error
+	exitTimeout     = 6   // --timeout or --request-timeout ran out
+	exitInterrupted = 130 // Ctrl-C or SIGTERM, as after SIGINT by convention
+)
+
+// Error classes as reported in structured errors.
+const (
+	classError       = "error"
+	classUsage       = "usage"
+	classAuth        = "auth"
+	classGitState    = "git"
+	classAPI         = "api"
+	classTimeout     = "timeout"
+	classInterrupted = "interrupted"
+)
+
+var exitCodes = map[string]int{
+	classError:       exitError,
+	classUsage:       exitUsage,
+	classAuth:        exitAuth,
+	classGitState:    exitGitState,
+	classAPI:         exitAPI,
+	classTimeout:     exitTimeout,
+	classInterrupted: exitInterrupted,
+}
+
+// classifiedError marks err with the class of failure it reports, for
+// failures that cannot be told apart by their type.
+type classifiedError struct {
+	class string
+	err   error
+}
+
+func (e *classifiedError) Error() string { return e.err.Error() }
+
+func (e *classifiedError) Unwrap() error { return e.err }
+
+// usageErrorf reports invalid flags or input.
+func usageErrorf(format string, args ...any) error {
+	return &classifiedError{class: classUsage, err: fmt.Errorf(format, args...)}
+}
+
+// gitStateErrorf reports a repository cpr cannot work from, such as a
+// detached HEAD or a branch without changes.
+func gitStateErrorf(format string, args ...any) error {
+	return &classifiedError{class: classGitState, err: fmt.Errorf(format, args...)}
+}
+
+// authError reports missing or rejected credentials.
+func authError(err error) error {
+	return &classifiedError{class: classAuth, err: err}
+}
+
+// resultError carries what a command got done before failing, such as the
+// backports created before one conflicted, so structured output keeps it.
+type resultError struct {
+	err    error
+	result any
+}
+
+func (e *resultError) Error() string { return e.err.Error() }
+
+func (e *resultError) Unwrap() error { return e.err }
+
+// classify returns the class of failure err reports.
+func classify(err error) string {
+	var timedOut *github.TimeoutError
+	var canceled *github.CanceledError
+	var classified *classifiedError
+	var titleErr *commit.TitleError
+	var errResp *gogithub.ErrorResponse
+	var rateLimit *gogithub.RateLimitError
+	var abuse *gogithub.AbuseRateLimitError
+	var access *github.AccessError
+
+	switch {
+	case errors.As(err, &canceled) || errors.Is(err, context.Canceled):
+		return classInterrupted
+	case errors.As(err, &timedOut) || errors.Is(err, context.DeadlineExceeded):
+		return classTimeout
+	case errors.As(err, &classified):
+		return classified.class
+	case errors.As(err, &titleErr):
+		return classUsage
+	case errors.As(err, &access):
+		return classAuth
+	case errors.As(err, &errResp):
+		if errResp.Response != nil && errResp.Response.StatusCode == http.StatusUnauthorized {
+			return classAuth
+		}
+		return classAPI
+	case errors.As(err, &rateLimit) || errors.As(err, &abuse):
+		return classAPI
+	}
+	return classError
+}
+
+// errorHint suggests how to get past err, if anything will.
+func errorHint(err error) string {
+	var timedOut *github.TimeoutError
+	var access *github.AccessError
+	switch {
+	case errors.As(err, &access):
+		return accessHint(access)
+	case errors.As(err, &timedOut) && !timedOut.Overall:
+		return fmt.Sprintf("GitHub did not answer within %s, raise --request-timeout to wait longer", requestTimeout)
+	case errors.As(err, &timedOut) || errors.Is(err, context.DeadlineExceeded):
+		return fmt.Sprintf("cpr did not finish within %s, raise --timeout to wait longer", timeout)
+	}
+	return ""
+}
+
+// errorReport is the structured form of an error.
+type errorReport struct {
+	Class    string   `json:"class" yaml:"class"`
+	Code     int      `json:"code" yaml:"code"`
+	Message  string   `json:"message" yaml:"message"`
+	Hint     string   `json:"hint,omitempty" yaml:"hint,omitempty"`
+	Problems []string `json:"problems,omitempty" yaml:"problems,omitempty"`
+}
+
+type errorDocument struct {
+	Error  errorReport `json:"error" yaml:"error"`
+	Result any         `json:"result,omitempty" yaml:"result,omitempty"`
+}
+
+// exitWithError reports err, as an error document on stdout with --output
+// json or yaml, and exits with the code for its class.
+func exitWithError(err error) {
+	class := classify(err)
+	report := errorReport{
+		Class:   class,
+		Code:    exitCodes[class],
+		Message: err.Error(),
+		Hint:    errorHint(err),
+	}
+	var titleErr *commit.TitleError
+	if errors.As(err, &titleErr) {
+		report.Problems = titleErr.Problems
+	}
+
+	switch {
+	case structuredOutput():
+		doc := errorDocument{Error: report}
+		var withResult *resultError
+		if errors.As(err, &withResult) {
+			doc.Result = withResult.result
+		}
+		if encodeErr := encodeOutput(os.Stdout, doc); encodeErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if report.Hint != "" {
+			fmt.Fprintln(os.Stderr, report.Hint)
+		}
+	}
+	os.Exit(report.Code)
+}
+
+// accessHint tells where to grant what an *github.AccessError says is
+// missing.
+func accessHint(err *github.AccessError) string {
+	switch {
+	case strings.HasSuffix(err.Missing, "scope"):
+		return "add the scope to the token in GitHub's developer settings, or run 'cpr auth login' with another token"
+	case strings.HasPrefix(err.Missing, "Pull requests"):
+		return "give the token read and write access to pull requests, or on GitHub Actions add 'pull-requests: write' to the workflow's permissions"
+	case strings.HasSuffix(err.Missing, "permission") || strings.HasSuffix(err.Missing, "permissions"):
+		return "grant the token the " + err.Missing + " in GitHub's settings, or run 'cpr auth login' with another token"
+	}
+	return "give the token access to " + err.Repo + ", or check the repository's name"
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fraser-isbester/cpr/internal/git"
+	"github.com/fraser-isbester/cpr/internal/github"
+	gogithub "github.com/google/go-github/v66/github"
+)
+
+// What to do when the branch's latest pull request was closed or merged,
+// set with --existing.
+const (
+	existingAsk    = "ask"
+	existingReopen = "reopen"
+	existingNew    = "new"
+	existingAbort  = "abort"
+)
+
+var existingChoices = []string{existingAsk, existingReopen, existingNew, existingAbort}
+
+// target is the repository pull requests go to and the repository holding
+// the branch, which differ when origin is a fork.
+type target struct {
+	owner, name string
+	// headOwner owns origin, where branches are pushed
+	headOwner string
+}
+
+// resolveTarget targets origin, or the repository in the upstream remote
+// when origin is a fork of it.
+func resolveTarget(repo *git.Repository) (*target, error) {
+	owner, name, err := resolveRemote(repo)
+	if err != nil {
+		return nil, err
+	}
+	t := &target{owner: owner, name: name, headOwner: owner}
+
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return nil, err
+	}
+	for _, remote := range remotes {
+		url, err := repo.RemoteURL(remote)
+		if err != nil {
+			continue
+		}
+		remoteOwner, remoteName, err := github.ParseGitRemoteURL(url)
+		if err != nil {
+			continue
+		}
+		if remote == "upstream" && remoteOwner != owner {
+			t.owner, t.name = remoteOwner, remoteName
+		}
+	}
+	return t, nil
+}
+
+// head returns branch as the API expects it for new pull requests,
+// qualified with its owner when it lives in a fork.
+func (t *target) head(branch string) string {
+	if t.headOwner == t.owner {
+		return branch
+	}
+	return t.headOwner + ":" + branch
+}
+
+// label returns branch qualified with the owner of the repository cpr
+// pushes it to, as pull requests label their head. Same-named branches in
+// other remotes are someone else's.
+func (t *target) label(branch string) string {
+	return t.headOwner + ":" + branch
+}
+
+// findExistingPullRequest looks up the pull request given with --pr, or the
+// branch's open pull request, preferring one onto base. Failing that, it
+// returns the branch's latest closed or merged pull request as closed.
+func findExistingPullRequest(ctx context.Context, client *github.Client, t *target, branch, base string) (open, closed *gogithub.PullRequest, err error) {
+	if prNumber > 0 {
+		pr, err := client.GetPullRequest(ctx, t.owner, t.name, prNumber)
+		if err != nil {
+			return nil, nil, err
+		}
+		if pr.GetHead().GetLabel() != t.label(branch) {
+			return nil, nil, fmt.Errorf("pull request #%d is opened from %s, not %s", prNumber, pr.GetHead().GetLabel(), branch)
+		}
+		if pr.GetState() == "open" {
+			return pr, nil, nil
+		}
+		return nil, pr, nil
+	}
+
+	pulls, err := client.FindPullRequests(ctx, t.owner, t.name, t.label(branch))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, pr := range pulls {
+		if pr.GetState() != "open" {
+			if closed == nil {
+				closed = pr
+			}
+			continue
+		}
+		if base == "" || pr.GetBase().GetRef() == base {
+			return pr, nil, nil
+		}
+		if open == nil {
+			open = pr
+		}
+	}
+	if open != nil {
+		return open, nil, nil
+	}
+	return nil, closed, nil
+}
+
+// reopenClosed decides between reopening the branch's closed pull request
+// and opening a new one, per --existing or by asking. Aborting, or asking
+// when cpr cannot prompt, is an error.
+func reopenClosed(ctx context.Context, pr *gogithub.PullRequest, interactive bool) (bool, error) {
+	merged := pr.MergedAt != nil
+	state := "closed"
+	if merged {
+		state = "merged"
+	}
+
+	choice := onExisting
+	if prNumber > 0 {
+		// Naming a closed PR with --pr asks for it back
+		choice = existingReopen
+	}
+	if choice == existingAsk {
+		if !interactive || !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+			hint := "--existing=new to open another"
+			if !merged {
+				hint += " or --existing=reopen to reopen it"
+			}
+			return false, fmt.Errorf("pull request #%d from this branch was %s: %s\nuse %s", pr.GetNumber(), state, pr.GetHTMLURL(), hint)
+		}
+
+		var err error
+		choice, err = askExisting(ctx, pr, merged)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	switch choice {
+	case existingReopen:
+		if merged {
+			return false, fmt.Errorf("pull request #%d was merged and cannot be reopened, use --existing=new to open another", pr.GetNumber())
+		}
+		return true, nil
+	case existingNew:
+		return false, nil
+	default:
+		return false, fmt.Errorf("aborted: pull request #%d from this branch was %s", pr.GetNumber(), state)
+	}
+}
+
+// askExisting asks what to do about a closed or merged pull request.
+func askExisting(ctx context.Context, pr *gogithub.PullRequest, merged bool) (string, error) {
+	options := "[n]ew, [a]bort"
+	state := "merged"
+	if !merged {
+		options = "[r]eopen, [n]ew, [a]bort"
+		state = "closed"
+	}
+	fmt.Fprintf(messages(), "Pull request #%d from this branch was %s: %s\n", pr.GetNumber(), state, pr.GetHTMLURL())
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Fprintf(messages(), "%s [a]: ", options)
+		line, err := readLine(ctx, reader)
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read choice: %w", err)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "", "a", existingAbort:
+			return existingAbort, nil
+		case "n", existingNew:
+			return existingNew, nil
+		case "r", existingReopen:
+			if !merged {
+				return existingReopen, nil
+			}
+		}
+		fmt.Fprintf(messages(), "Enter one of %s\n", options)
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+	"github.com/fraser-isbester/cpr/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var lintTitleCmd = &cobra.Command{
+	Use:   "lint-title [title]",
+	Short: "Validate a PR title against the Conventional Commit title rules",
+	Long: `Check a PR title against the rules in .cpr/config.yaml: allowed types,
+scope enum, subject case, maximum length and no trailing period. The title
+is read from the argument, from stdin when it is "-", or otherwise from the
+current branch's open PR.
+
+Exits non-zero and lists every problem when the title is invalid, so it can
+run as a CI step.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := lintTitle(cmd.Context(), args); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintTitleCmd)
+}
+
+func lintTitle(ctx context.Context, args []string) error {
+	repo := openRepository()
+
+	cfg, err := loadConfig(repo)
+	if err != nil {
+		return err
+	}
+
+	if !cfg.Title.IsConventional() {
+		return usageErrorf("title rules only apply to the angular title format, but %s selects %q", config.Path, cfg.Title.Format)
+	}
+
+	var prTitle string
+	switch {
+	case len(args) == 1 && args[0] == "-":
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read title from stdin: %w", err)
+		}
+		prTitle = strings.TrimSpace(line)
+	case len(args) == 1:
+		prTitle = args[0]
+	default:
+		bc, err := newBranchContext(ctx)
+		if err != nil {
+			return err
+		}
+		pr, err := bc.pullRequest(ctx)
+		if err != nil {
+			return err
+		}
+		prTitle = pr.GetTitle()
+	}
+
+	if err := commit.ValidateTitle(prTitle, cfg.Title.Rules()); err != nil {
+		return err
+	}
+
+	return printResult(lintResult{Title: prTitle, Valid: true}, func() {
+		fmt.Printf("Title OK: %s\n", prTitle)
+	})
+}
+
+// lintResult is what cpr lint-title reports for a valid title; invalid
+// titles are reported as errors listing the problems.
+type lintResult struct {
+	Title string `json:"title" yaml:"title"`
+	Valid bool   `json:"valid" yaml:"valid"`
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/fraser-isbester/cpr/internal/cache"
+	"github.com/fraser-isbester/cpr/internal/git"
+	"github.com/fraser-isbester/cpr/internal/logging"
+	"github.com/spf13/cobra"
+)
+
+var (
+	logLevel  string
+	logFormat string
+
+	// logger logs to stderr once setupLogging ran; redactor masks the
+	// credentials cpr resolves in everything it logs.
+	logger   = logging.Discard()
+	redactor = &logging.Redactor{}
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "warn", "Log level: trace, debug, info, warn or error; trace also dumps GitHub API requests and responses")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logging.FormatText, "Log format: text or json")
+}
+
+// setupLogging creates the logger from --log-level and --log-format.
+// --verbose logs at debug level unless --log-level is given as well.
+func setupLogging(cmd *cobra.Command) error {
+	level, err := logging.ParseLevel(logLevel)
+	if err != nil {
+		return usageErrorf("%v", err)
+	}
+	if verbose && !cmd.Flags().Changed("log-level") {
+		level = slog.LevelDebug
+	}
+
+	l, err := logging.New(os.Stderr, logging.Options{Level: level, Format: logFormat, Redactor: redactor})
+	if err != nil {
+		return usageErrorf("%v", err)
+	}
+	logger = l
+	return nil
+}
+
+// openRepository opens the repository in the working directory, logging
+// to the command's logger and caching what it learns over the network.
+func openRepository() *git.Repository {
+	repo := git.NewRepository("")
+	repo.SetLogger(logger)
+	if store, err := cache.New(); err == nil {
+		repo.SetCache(store, 0)
+	} else {
+		logger.Debug("not caching the default branch", "err", err)
+	}
+	return repo
+}
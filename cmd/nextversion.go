@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+	"github.com/fraser-isbester/cpr/internal/semver"
+	"github.com/spf13/cobra"
+)
+
+var (
+	preChannel string
+	createTag  bool
+	pushTag    bool
+)
+
+var nextVersionCmd = &cobra.Command{
+	Use:   "next-version",
+	Short: "Recommend the next semantic version from Conventional commits",
+	Long: `Find the latest semver tag reachable from HEAD and compute the next version from the
+Conventional commits since then: breaking changes bump the major version,
+features the minor version, and fixes and performance improvements the
+patch version.
+
+With --pre rc, the next pre-release of that version is returned instead,
+e.g. v1.3.0-rc.2 if v1.3.0-rc.1 is already tagged.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := nextVersion(); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	nextVersionCmd.Flags().StringVar(&preChannel, "pre", "", "Pre-release channel, e.g. rc or beta")
+	nextVersionCmd.Flags().BoolVar(&createTag, "tag", false, "Tag HEAD with the next version")
+	nextVersionCmd.Flags().BoolVar(&pushTag, "push", false, "Push the created tag to origin (implies --tag)")
+	rootCmd.AddCommand(nextVersionCmd)
+}
+
+type versionResult struct {
+	Current string `json:"current" yaml:"current"`
+	Next    string `json:"next" yaml:"next"`
+	Bump    string `json:"bump" yaml:"bump"`
+	Commits int    `json:"commits" yaml:"commits"`
+	Tagged  bool   `json:"tagged" yaml:"tagged"`
+	Pushed  bool   `json:"pushed" yaml:"pushed"`
+}
+
+func nextVersion() error {
+	repo := openRepository()
+
+	tags, err := repo.Tags()
+	if err != nil {
+		return err
+	}
+	// Releases tagged on other branches are not this branch's to bump from
+	reachable, err := repo.ReachableTags("HEAD")
+	if err != nil {
+		return gitStateErrorf("failed to list tags: %w", err)
+	}
+
+	// Bumps are measured from the last stable release
+	current, found := semver.Latest(reachable, false)
+	from := current.String()
+	if !found {
+		current = semver.Version{Prefix: "v"}
+		from = ""
+	}
+
+	commits, err := repo.CommitsBetween(from, "HEAD")
+	if err != nil {
+		return gitStateErrorf("failed to list commits: %w", err)
+	}
+
+	var parsed []*commit.Conventional
+	for _, c := range commits {
+		if conv, err := commit.ParseConventional(c.Message); err == nil {
+			parsed = append(parsed, conv)
+		}
+	}
+
+	bump := semver.BumpFor(parsed)
+	next := semver.Next(current, bump, preChannel, tags)
+
+	result := versionResult{
+		Current: current.String(),
+		Next:    next.String(),
+		Bump:    bump.String(),
+		Commits: len(commits),
+	}
+
+	if (createTag || pushTag) && bump != semver.BumpNone {
+		if err := repo.CreateTag(result.Next); err != nil {
+			return err
+		}
+		result.Tagged = true
+
+		if pushTag {
+			if err := repo.PushTag(result.Next); err != nil {
+				return err
+			}
+			result.Pushed = true
+		}
+	}
+
+	logger.Info("computed next version", "current", result.Current, "bump", result.Bump, "commits", len(commits), "conventional", len(parsed))
+
+	return printResult(result, func() {
+		fmt.Println(result.Next)
+	})
+}
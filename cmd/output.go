@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats for --output.
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+var outputFormats = []string{outputText, outputJSON, outputYAML}
+
+var outputFormat string
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputText, "Output format: text, json or yaml")
+}
+
+// checkOutput rejects an unknown --output before the command runs.
+func checkOutput() error {
+	if !slices.Contains(outputFormats, outputFormat) {
+		return usageErrorf("invalid --output %q, must be one of %s", outputFormat, strings.Join(outputFormats, ", "))
+	}
+	return nil
+}
+
+// structuredOutput reports whether results are printed as JSON or YAML
+// documents rather than text.
+func structuredOutput() bool {
+	return outputFormat == outputJSON || outputFormat == outputYAML
+}
+
+// messages is where progress output such as prompts goes: stdout for text,
+// and stderr with structured output so that stdout holds only the document.
+func messages() io.Writer {
+	if structuredOutput() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// printResult prints a command's result as a document with --output json
+// or yaml, and otherwise calls text to print it for people.
+func printResult(result any, text func()) error {
+	if !structuredOutput() {
+		text()
+		return nil
+	}
+	return encodeOutput(os.Stdout, result)
+}
+
+// encodeOutput writes v to w in the --output format.
+func encodeOutput(w io.Writer, v any) error {
+	switch outputFormat {
+	case outputYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+		return enc.Close()
+	default:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+		return nil
+	}
+}
+
+// prepareCommand checks the global flags, sets up logging and applies
+// --timeout before any command runs.
+func prepareCommand(cmd *cobra.Command, args []string) error {
+	if err := checkOutput(); err != nil {
+		return err
+	}
+	if err := setupLogging(cmd); err != nil {
+		return err
+	}
+	applyTimeout(cmd, args)
+	return nil
+}
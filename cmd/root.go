@@ -1,20 +1,33 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/fraser-isbester/cpr/internal/commit"
+	"github.com/fraser-isbester/cpr/internal/config"
 	"github.com/fraser-isbester/cpr/internal/git"
 	"github.com/fraser-isbester/cpr/internal/github"
+	gogithub "github.com/google/go-github/v66/github"
 	"github.com/spf13/cobra"
 )
 
 var (
-	title   string
-	body    string
-	draft   bool
-	verbose bool
+	title        string
+	body         string
+	draft        bool
+	ready        bool
+	baseRef      string
+	headRef      string
+	noVerify     bool
+	templateName string
+	explain      bool
+	prNumber     int
+	onExisting   string
+	verbose      bool
 )
 
 var rootCmd = &cobra.Command{
@@ -23,21 +36,30 @@ var rootCmd = &cobra.Command{
 	Long: `cpr is a lightweight CLI tool that creates GitHub pull requests with
 automatically generated Angular format titles and summaries based on your git diff.
 
-It analyzes the changes between your current branch and the default branch,
+It analyzes the changes between your current branch and the default branch
+(or the branch given with --base),
 generates an appropriate PR title (e.g., "feat: add new feature", "fix: resolve bug"),
 and creates a comprehensive PR summary.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := createPR(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+		if err := createPR(cmd.Context()); err != nil {
+			exitWithError(err)
 		}
 	},
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	ctx, stop := rootContext()
+	defer stop()
+	defer cancelTimeout()
+
+	// Errors are reported by exitWithError, in the --output format
+	rootCmd.SilenceErrors = true
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		// What reaches here is a bad command line
+		if classify(err) == classError {
+			err = &classifiedError{class: classUsage, err: err}
+		}
+		exitWithError(err)
 	}
 }
 
@@ -45,121 +67,448 @@ func init() {
 	rootCmd.Flags().StringVarP(&title, "title", "t", "", "Custom PR title (overrides auto-generation)")
 	rootCmd.Flags().StringVarP(&body, "body", "b", "", "Custom PR body (overrides auto-generation)")
 	rootCmd.Flags().BoolVarP(&draft, "draft", "d", false, "Create PR as draft")
-	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+	rootCmd.Flags().StringVar(&baseRef, "base", "", "Branch to target and diff against (defaults to the existing PR's base or the default branch)")
+	rootCmd.Flags().StringVar(&headRef, "head", "", "Branch to open the PR from (defaults to the current branch)")
+	rootCmd.Flags().BoolVar(&noVerify, "no-verify", false, "Skip validating a custom --title against the title rules")
+	rootCmd.Flags().BoolVar(&ready, "ready", false, "Mark an existing draft PR as ready for review")
+	rootCmd.Flags().BoolVar(&explain, "explain", false, "Print the evidence behind the detected commit type and exit")
+	rootCmd.Flags().StringVar(&templateName, "template", "", "PR template to use from .github/PULL_REQUEST_TEMPLATE/, or \"none\"")
+	rootCmd.Flags().IntVar(&prNumber, "pr", 0, "Update this PR instead of looking one up by branch, reopening it if closed")
+	rootCmd.Flags().StringVar(&onExisting, "existing", existingAsk, "What to do when the branch's PR was closed or merged: ask, reopen, new or abort")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Log at debug level, the same as --log-level debug")
+	rootCmd.PersistentPreRunE = prepareCommand
+	rootCmd.PersistentPostRun = reportRateLimit
 }
 
-func createPR() error {
-	repo := git.NewRepository("")
+func createPR(ctx context.Context) error {
+	if draft && ready {
+		return usageErrorf("--draft and --ready cannot be used together")
+	}
+	if !slices.Contains(existingChoices, onExisting) {
+		return usageErrorf("invalid --existing %q, must be one of %s", onExisting, strings.Join(existingChoices, ", "))
+	}
+
+	repo := openRepository()
+
+	headBranch := headRef
+	if headBranch == "" {
+		currentBranch, err := repo.CurrentBranch()
+		if err != nil {
+			return gitStateErrorf("failed to get current branch: %w", err)
+		}
+		headBranch = currentBranch
+	}
+
+	if headBranch == "HEAD" {
+		return gitStateErrorf("in detached HEAD state, please checkout a branch")
+	}
+
+	// PRs can only target branches, so accept origin/<branch> as well
+	baseBranch := strings.TrimPrefix(baseRef, "origin/")
 
-	currentBranch, err := repo.CurrentBranch()
+	// Explaining only reads local history, so it needs no token or network
+	if explain {
+		if baseBranch == "" {
+			defaultBranch, err := repo.DefaultBranch()
+			if err != nil {
+				return gitStateErrorf("failed to get default branch: %w", err)
+			}
+			baseBranch = defaultBranch
+		}
+		_, analyzer, _, err := analyzeBranch(repo, baseBranch, headBranch)
+		if err != nil {
+			return err
+		}
+		return explainClassification(analyzer)
+	}
+
+	t, err := resolveTarget(repo)
 	if err != nil {
-		return fmt.Errorf("failed to get current branch: %w", err)
+		return err
 	}
+	owner, repoName := t.owner, t.name
 
-	if currentBranch == "HEAD" {
-		return fmt.Errorf("in detached HEAD state, please checkout a branch")
+	logger.Info("resolved repository", "repo", owner+"/"+repoName, "head_repo", t.headOwner+"/"+repoName)
+
+	client, err := newClient(ctx)
+	if err != nil {
+		return err
 	}
+	lookUpDefaultBranch(ctx, repo, client, t)
 
-	defaultBranch, err := repo.DefaultBranch()
+	existing, closed, err := findExistingPullRequest(ctx, client, t, headBranch, baseBranch)
 	if err != nil {
-		return fmt.Errorf("failed to get default branch: %w", err)
+		return err
+	}
+	if baseBranch == "" {
+		// Keep an existing PR on its current base, e.g. one created by `cpr stack`
+		switch {
+		case existing != nil:
+			baseBranch = existing.GetBase().GetRef()
+		case closed != nil && prNumber > 0:
+			baseBranch = closed.GetBase().GetRef()
+		}
+	}
+	if baseBranch == "" {
+		defaultBranch, err := repo.DefaultBranch()
+		if err != nil {
+			return gitStateErrorf("failed to get default branch: %w", err)
+		}
+		baseBranch = defaultBranch
 	}
 
-	if currentBranch == defaultBranch {
-		return fmt.Errorf("cannot create PR from default branch '%s'", defaultBranch)
+	cfg, analyzer, commits, err := analyzeBranch(repo, baseBranch, headBranch)
+	if err != nil {
+		return err
 	}
 
-	if verbose {
-		fmt.Printf("Current branch: %s\n", currentBranch)
-		fmt.Printf("Default branch: %s\n", defaultBranch)
+	// Catch titles CI lint would reject before anything is pushed
+	if title != "" && !noVerify && cfg.Title.IsConventional() {
+		if err := commit.ValidateTitle(title, cfg.Title.Rules()); err != nil {
+			return &classifiedError{class: classUsage, err: fmt.Errorf("%w\nuse --no-verify to skip this check", err)}
+		}
 	}
 
-	diff, err := repo.DiffAgainstDefault()
+	if title == "" {
+		title, err = generateTitle(cfg, analyzer, headBranch)
+		if err != nil {
+			return err
+		}
+		logger.Info("generated title", "title", title)
+	}
+
+	templates, err := loadTemplates(repo)
 	if err != nil {
-		return fmt.Errorf("failed to get diff: %w", err)
+		return err
 	}
 
-	if diff == "" {
-		if verbose {
-			fmt.Printf("No changes detected between %s and %s\n", currentBranch, defaultBranch)
+	data := analyzer.BodyData(title, headBranch, baseBranch, commits)
+
+	if body == "" {
+		body, err = generateBody(templates, analyzer, data)
+		if err != nil {
+			return err
 		}
-		return fmt.Errorf("no changes detected between %s and %s", currentBranch, defaultBranch)
+		logger.Debug("generated body", "body", body)
 	}
+	data.Summary = body
 
-	changedFiles, err := repo.GetChangedFiles()
+	if err := checkAccess(ctx, client, owner, repoName); err != nil {
+		return err
+	}
+
+	// Decide before pushing anything, so aborting leaves no trace
+	reopen := false
+	if existing == nil && closed != nil {
+		reopen, err = reopenClosed(ctx, closed, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Push the head branch to origin if needed
+	if err := repo.PushBranch(headBranch); err != nil {
+		logger.Warn("failed to push branch", "branch", headBranch, "err", err)
+	}
+
+	// Check for PR template
+	prTemplates, err := pullRequestTemplates(ctx, repo, client, owner, repoName, headBranch, baseBranch)
 	if err != nil {
-		return fmt.Errorf("failed to get changed files: %w", err)
+		logger.Warn("failed to fetch PR templates", "err", err)
+	}
+	template, found, err := selectTemplate(ctx, cfg, prTemplates, data.Type, true)
+	if err != nil {
+		return err
 	}
 
-	analyzer := commit.NewAnalyzer(diff, changedFiles)
+	// Apply template if found
+	if found {
+		logger.Info("applying PR template", "path", template.Path)
+		body, err = applyPullRequestTemplate(cfg, templates, template.Content, data)
+		if err != nil {
+			return err
+		}
+	}
 
-	if title == "" {
-		title = analyzer.GenerateTitle()
-		if verbose {
-			fmt.Printf("Generated title: %s\n", title)
+	if reopen {
+		existing, err = client.ReopenPullRequest(ctx, owner, repoName, closed.GetNumber())
+		if err != nil {
+			return err
 		}
+		fmt.Fprintf(messages(), "Pull request reopened: %s\n", existing.GetHTMLURL())
 	}
 
-	if body == "" {
-		body = analyzer.GenerateSummary()
-		if verbose {
-			fmt.Printf("Generated body:\n%s\n", body)
+	// Create or update PR
+	var pr *gogithub.PullRequest
+	updated := existing != nil
+	if updated {
+		// Retarget the existing PR if the requested base changed
+		newBase := ""
+		if existing.GetBase().GetRef() != baseBranch {
+			newBase = baseBranch
 		}
+		pr, err = client.UpdatePullRequest(ctx, owner, repoName, existing.GetNumber(), title, body, newBase)
+	} else {
+		pr, err = client.CreatePullRequest(ctx, owner, repoName, title, body, t.head(headBranch), baseBranch, draft)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create/update pull request: %w", err)
 	}
 
+	// Draft state is only set at creation, so apply it explicitly on updates
+	if updated && (draft || ready) {
+		changed, err := setDraftState(ctx, client, owner, repoName, pr.GetNumber(), draft)
+		if err != nil {
+			logger.Warn("failed to change draft state", "number", pr.GetNumber(), "err", err)
+		} else {
+			pr = changed
+		}
+	}
+
+	result := newPullRequestResult(pr, data)
+	result.Created = !updated
+	result.Reopened = reopen
+	return printResult(result, func() {
+		if updated {
+			fmt.Printf("Pull request updated: %s\n", pr.GetHTMLURL())
+		} else {
+			fmt.Printf("Pull request created: %s\n", pr.GetHTMLURL())
+		}
+	})
+}
+
+// pullRequestResult is what cpr reports about the pull request it created
+// or updated.
+type pullRequestResult struct {
+	Number    int      `json:"number" yaml:"number"`
+	URL       string   `json:"url" yaml:"url"`
+	Created   bool     `json:"created" yaml:"created"`
+	Reopened  bool     `json:"reopened" yaml:"reopened"`
+	Draft     bool     `json:"draft" yaml:"draft"`
+	Title     string   `json:"title" yaml:"title"`
+	Body      string   `json:"body" yaml:"body"`
+	Base      string   `json:"base" yaml:"base"`
+	Head      string   `json:"head" yaml:"head"`
+	Type      string   `json:"type" yaml:"type"`
+	Scope     string   `json:"scope,omitempty" yaml:"scope,omitempty"`
+	Labels    []string `json:"labels" yaml:"labels"`
+	Reviewers []string `json:"reviewers" yaml:"reviewers"`
+}
+
+func newPullRequestResult(pr *gogithub.PullRequest, data commit.BodyData) *pullRequestResult {
+	result := &pullRequestResult{
+		Number:    pr.GetNumber(),
+		URL:       pr.GetHTMLURL(),
+		Draft:     pr.GetDraft(),
+		Title:     pr.GetTitle(),
+		Body:      pr.GetBody(),
+		Base:      pr.GetBase().GetRef(),
+		Head:      pr.GetHead().GetRef(),
+		Type:      string(data.Type),
+		Scope:     data.Scope,
+		Labels:    []string{},
+		Reviewers: []string{},
+	}
+	for _, label := range pr.Labels {
+		result.Labels = append(result.Labels, label.GetName())
+	}
+	for _, user := range pr.RequestedReviewers {
+		result.Reviewers = append(result.Reviewers, user.GetLogin())
+	}
+	for _, team := range pr.RequestedTeams {
+		result.Reviewers = append(result.Reviewers, team.GetSlug())
+	}
+	return result
+}
+
+// resolveRemote returns the GitHub owner and repository name of origin.
+func resolveRemote(repo *git.Repository) (string, string, error) {
 	remoteURL, err := repo.GetRemoteURL()
 	if err != nil {
-		return fmt.Errorf("failed to get remote URL: %w", err)
+		return "", "", gitStateErrorf("failed to get remote URL: %w", err)
 	}
 
 	owner, repoName, err := github.ParseGitRemoteURL(remoteURL)
 	if err != nil {
-		return fmt.Errorf("failed to parse remote URL: %w", err)
+		return "", "", gitStateErrorf("failed to parse remote URL: %w", err)
 	}
 
-	if verbose {
-		fmt.Printf("Repository: %s/%s\n", owner, repoName)
+	return owner, repoName, nil
+}
+
+// lookUpDefaultBranch makes repo ask GitHub for the default branch of the
+// repository pull requests target before listing origin's refs, saving a
+// round-trip to the git server.
+func lookUpDefaultBranch(ctx context.Context, repo *git.Repository, client *github.Client, t *target) {
+	repo.SetDefaultBranchLookup(func() (string, error) {
+		return client.DefaultBranch(ctx, t.owner, t.name)
+	})
+}
+
+// analyzeBranch loads the config and analyzes the changes and commits on
+// head since base.
+func analyzeBranch(repo *git.Repository, base, head string) (*config.Config, *commit.Analyzer, []commit.CommitInfo, error) {
+	if head == base {
+		return nil, nil, nil, gitStateErrorf("cannot create PR from base branch '%s'", base)
 	}
 
-	// Push the current branch to origin if needed
-	if err := repo.PushCurrentBranch(); err != nil {
-		if verbose {
-			fmt.Printf("Note: %v\n", err)
-		}
+	logger.Info("resolved branches", "head", head, "base", base)
+
+	diff, err := repo.Diff(base, head)
+	if err != nil {
+		return nil, nil, nil, gitStateErrorf("failed to get diff: %w", err)
 	}
 
-	token, err := github.GetToken()
+	if diff == "" {
+		return nil, nil, nil, gitStateErrorf("no changes detected between %s and %s", head, base)
+	}
+
+	changedFiles, err := repo.GetChangedFiles(base, head)
 	if err != nil {
-		return err
+		return nil, nil, nil, fmt.Errorf("failed to get changed files: %w", err)
 	}
 
-	client := github.NewClient(token)
+	cfg, err := loadConfig(repo)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-	// Check for PR template
-	template, err := client.GetPullRequestTemplate(owner, repoName)
-	if err != nil && verbose {
-		fmt.Printf("Failed to fetch PR template: %v\n", err)
+	commits, err := branchCommits(repo, base, head)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	// Apply template if found
-	if template != "" {
-		if verbose {
-			fmt.Printf("Found PR template, applying...\n")
-		}
-		body = github.ApplyTemplate(template, title, body)
+	analyzer, err := newAnalyzer(repo, cfg, diff, changedFiles, commits)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	// Create or update PR
-	pr, updated, err := client.CreateOrUpdatePullRequest(owner, repoName, title, body, currentBranch, defaultBranch, draft)
+	return cfg, analyzer, commits, nil
+}
+
+// loadConfig reads the configuration of the repository.
+func loadConfig(repo *git.Repository) (*config.Config, error) {
+	root, err := repo.Root()
 	if err != nil {
-		return fmt.Errorf("failed to create/update pull request: %w", err)
+		return nil, err
 	}
+	return config.Load(root)
+}
 
-	if updated {
-		fmt.Printf("Pull request updated: %s\n", pr.GetHTMLURL())
-	} else {
-		fmt.Printf("Pull request created: %s\n", pr.GetHTMLURL())
+// generateTitle renders the analyzer's title in the repository's format.
+func generateTitle(cfg *config.Config, analyzer *commit.Analyzer, branch string) (string, error) {
+	formatter, err := cfg.Title.Formatter()
+	if err != nil {
+		return "", err
+	}
+	return analyzer.GenerateTitleWith(formatter, branch)
+}
+
+// apiClient is the client the running command made, if any.
+var apiClient *github.Client
+
+// newClient returns a GitHub client authenticated with the resolved token.
+// ctx bounds resolving it, which may run gh or git or call the API for a
+// GitHub App's token.
+func newClient(ctx context.Context) (*github.Client, error) {
+	cred, err := resolveCredential(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return newClientForAPI(os.Getenv("GITHUB_API_URL"), cred.Token)
+}
 
+// newClientForAPI returns a GitHub client for the REST API at apiURL, or
+// github.com's if apiURL is "", authenticated with token.
+func newClientForAPI(apiURL, token string) (*github.Client, error) {
+	opts := []github.Option{
+		github.WithRequestTimeout(requestTimeout),
+		github.WithLogger(logger),
+	}
+
+	// GITHUB_API_URL is set on GitHub Actions, also for GitHub Enterprise
+	// Server
+	if apiURL != "" {
+		var err error
+		apiClient, err = github.NewClientWithBaseURL(apiURL, token, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return apiClient, nil
+	}
+
+	apiClient = github.NewClient(token, opts...)
+	return apiClient, nil
+}
+
+// checkAccess fails if the token may not open pull requests on owner/repo,
+// as far as GitHub tells before anything is pushed, and warns when it can't
+// tell.
+func checkAccess(ctx context.Context, client *github.Client, owner, repo string) error {
+	access, err := client.CheckPullRequestAccess(ctx, owner, repo)
+	if err != nil {
+		return err
+	}
+	if !access.PullRequestsWritable {
+		logger.Warn("cannot verify before pushing that the token has the Pull requests: write permission, GitHub only tells when the pull request is opened",
+			"repo", access.Repo, "kind", access.TokenKind)
+		return nil
+	}
+	logger.Debug("token may open pull requests", "repo", access.Repo, "kind", access.TokenKind, "scopes", access.Scopes)
 	return nil
-}
\ No newline at end of file
+}
+
+// reportRateLimit logs the API quota left after the command.
+func reportRateLimit(cmd *cobra.Command, args []string) {
+	if apiClient == nil {
+		return
+	}
+	if rate, ok := apiClient.RateLimit(); ok {
+		logger.Info("GitHub API quota", "quota", github.FormatRate(rate))
+	}
+}
+
+// branchContext bundles what subcommands need to act on the pull request
+// opened from the current branch.
+type branchContext struct {
+	repo   *git.Repository
+	client *github.Client
+	target *target
+	owner  string
+	name   string
+	branch string
+}
+
+func newBranchContext(ctx context.Context) (*branchContext, error) {
+	repo := openRepository()
+
+	branch, err := repo.CurrentBranch()
+	if err != nil {
+		return nil, gitStateErrorf("failed to get current branch: %w", err)
+	}
+
+	t, err := resolveTarget(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &branchContext{repo: repo, client: client, target: t, owner: t.owner, name: t.name, branch: branch}, nil
+}
+
+// pullRequest returns the open pull request for the branch or an error if
+// none exists.
+func (b *branchContext) pullRequest(ctx context.Context) (*gogithub.PullRequest, error) {
+	pr, _, err := findExistingPullRequest(ctx, b.client, b.target, b.branch, "")
+	if err != nil {
+		return nil, err
+	}
+	if pr == nil {
+		return nil, gitStateErrorf("no open pull request found for branch '%s'", b.branch)
+	}
+	return pr, nil
+}
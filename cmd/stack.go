@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fraser-isbester/cpr/internal/config"
+	"github.com/fraser-isbester/cpr/internal/git"
+	"github.com/fraser-isbester/cpr/internal/github"
+	gogithub "github.com/google/go-github/v66/github"
+	"github.com/spf13/cobra"
+)
+
+var stackCmd = &cobra.Command{
+	Use:   "stack",
+	Short: "Create or update a PR for every branch in the current stack",
+	Long: `Detect the stack of branches the current branch belongs to, e.g.
+feat-a → feat-b → feat-c, from branch ancestry. Each branch gets a PR based
+on its parent branch, with the title and summary generated from the diff
+against that parent and a navigation table linking the whole stack.
+
+Branches whose PR has been merged drop out of the stack, and their children
+are retargeted onto the next branch down. PRs whose title, body and base
+are already up to date are left alone.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := createStack(cmd.Context()); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	stackCmd.Flags().BoolVarP(&draft, "draft", "d", false, "Create new PRs as drafts")
+	stackCmd.Flags().StringVar(&templateName, "template", "", "PR template to use for every PR, or \"none\"")
+	rootCmd.AddCommand(stackCmd)
+}
+
+type stackedPR struct {
+	branch git.StackBranch
+	title  string
+	body   string
+	pr     *gogithub.PullRequest
+	// existing is true when the PR was opened on an earlier run
+	existing bool
+	action   string
+}
+
+// stackResult is what cpr stack reports: the PR of every branch in the
+// stack, bottom first.
+type stackResult struct {
+	PullRequests []stackedPRResult `json:"pull_requests" yaml:"pull_requests"`
+}
+
+type stackedPRResult struct {
+	Number int    `json:"number" yaml:"number"`
+	URL    string `json:"url" yaml:"url"`
+	Title  string `json:"title" yaml:"title"`
+	Head   string `json:"head" yaml:"head"`
+	Base   string `json:"base" yaml:"base"`
+	// Action is created, updated, retargeted or unchanged
+	Action string `json:"action" yaml:"action"`
+}
+
+func newStackResult(prs []*stackedPR) *stackResult {
+	result := &stackResult{PullRequests: []stackedPRResult{}}
+	for _, spr := range prs {
+		result.PullRequests = append(result.PullRequests, stackedPRResult{
+			Number: spr.pr.GetNumber(),
+			URL:    spr.pr.GetHTMLURL(),
+			Title:  spr.title,
+			Head:   spr.branch.Name,
+			Base:   spr.branch.Parent,
+			Action: spr.action,
+		})
+	}
+	return result
+}
+
+func createStack(ctx context.Context) error {
+	repo := openRepository()
+
+	currentBranch, err := repo.CurrentBranch()
+	if err != nil {
+		return gitStateErrorf("failed to get current branch: %w", err)
+	}
+
+	if currentBranch == "HEAD" {
+		return gitStateErrorf("in detached HEAD state, please checkout a branch")
+	}
+
+	defaultBranch, err := repo.DefaultBranch()
+	if err != nil {
+		return gitStateErrorf("failed to get default branch: %w", err)
+	}
+
+	t, err := resolveTarget(repo)
+	if err != nil {
+		return err
+	}
+	owner, repoName := t.owner, t.name
+
+	client, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	stack, err := repo.Stack(defaultBranch, currentBranch)
+	if err != nil {
+		return fmt.Errorf("failed to detect stack: %w", err)
+	}
+
+	// Squash merges leave no trace in ancestry, so ask GitHub what merged
+	var merged []string
+	for _, b := range stack {
+		sha, err := repo.BranchCommit(b.Name)
+		if err != nil {
+			return gitStateErrorf("failed to resolve %s: %w", b.Name, err)
+		}
+		isMerged, err := client.IsBranchMerged(ctx, owner, repoName, t.label(b.Name), sha)
+		if err != nil {
+			return err
+		}
+		if isMerged && b.Name != currentBranch {
+			merged = append(merged, b.Name)
+		}
+	}
+	if len(merged) > 0 {
+		logger.Info("skipping merged branches", "branches", merged)
+		stack, err = repo.Stack(defaultBranch, currentBranch, merged...)
+		if err != nil {
+			return fmt.Errorf("failed to detect stack: %w", err)
+		}
+	}
+
+	for _, b := range stack {
+		logger.Info("stacked branch", "branch", b.Name, "parent", b.Parent)
+	}
+
+	cfg, err := loadConfig(repo)
+	if err != nil {
+		return err
+	}
+
+	prTemplates, err := pullRequestTemplates(ctx, repo, client, owner, repoName, "", defaultBranch)
+	if err != nil {
+		logger.Warn("failed to fetch PR templates", "err", err)
+	}
+
+	if err := checkAccess(ctx, client, owner, repoName); err != nil {
+		return err
+	}
+
+	prs := make([]*stackedPR, 0, len(stack))
+	for _, b := range stack {
+		if err := repo.PushBranch(b.Name); err != nil {
+			logger.Warn("failed to push branch", "branch", b.Name, "err", err)
+		}
+
+		spr, err := syncStackedPR(ctx, repo, client, cfg, t, b, prTemplates)
+		if err != nil {
+			return &resultError{err: fmt.Errorf("failed to sync %s: %w", b.Name, err), result: newStackResult(prs)}
+		}
+		prs = append(prs, spr)
+	}
+
+	// Now that every PR has a number, link the stack together
+	entries := make([]github.StackEntry, len(prs))
+	for i, spr := range prs {
+		entries[i] = github.StackEntry{Number: spr.pr.GetNumber(), Branch: spr.branch.Name, Title: spr.title}
+	}
+	for i, spr := range prs {
+		spr.body = github.ApplyStackTable(spr.body, entries, i)
+		if err := finishStackedPR(ctx, client, owner, repoName, spr); err != nil {
+			return &resultError{err: fmt.Errorf("failed to update %s: %w", spr.branch.Name, err), result: newStackResult(prs)}
+		}
+	}
+
+	// Each PR was reported as it was synced
+	return printResult(newStackResult(prs), func() {})
+}
+
+// syncStackedPR generates the title and body of the PR for one branch of a
+// stack, based on its parent branch, and opens the PR if there is none yet.
+// Existing PRs are edited by finishStackedPR once the stack table is known.
+func syncStackedPR(ctx context.Context, repo *git.Repository, client *github.Client, cfg *config.Config, t *target, b git.StackBranch, prTemplates []github.PullRequestTemplate) (*stackedPR, error) {
+	diff, err := repo.Diff(b.Parent, b.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get diff: %w", err)
+	}
+
+	changedFiles, err := repo.GetChangedFiles(b.Parent, b.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get changed files: %w", err)
+	}
+
+	commits, err := branchCommits(repo, b.Parent, b.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	analyzer, err := newAnalyzer(repo, cfg, diff, changedFiles, commits)
+	if err != nil {
+		return nil, err
+	}
+	prTitle, err := generateTitle(cfg, analyzer, b.Name)
+	if err != nil {
+		return nil, err
+	}
+	templates, err := loadTemplates(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	data := analyzer.BodyData(prTitle, b.Name, b.Parent, commits)
+
+	prBody, err := generateBody(templates, analyzer, data)
+	if err != nil {
+		return nil, err
+	}
+	template, found, err := selectTemplate(ctx, cfg, prTemplates, data.Type, false)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		data.Summary = prBody
+		prBody, err = applyPullRequestTemplate(cfg, templates, template.Content, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	existing, err := client.GetPullRequestForBranch(ctx, t.owner, t.name, t.label(b.Name))
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return &stackedPR{branch: b, title: prTitle, body: prBody, pr: existing, existing: true}, nil
+	}
+
+	pr, err := client.CreatePullRequest(ctx, t.owner, t.name, prTitle, prBody, t.head(b.Name), b.Parent, draft)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(messages(), "Pull request created: %s (%s → %s)\n", pr.GetHTMLURL(), b.Name, b.Parent)
+
+	return &stackedPR{branch: b, title: prTitle, body: prBody, pr: pr, action: "created"}, nil
+}
+
+// finishStackedPR brings a stacked PR's title, body and base up to date,
+// skipping the edit when they already are.
+func finishStackedPR(ctx context.Context, client *github.Client, owner, repoName string, spr *stackedPR) error {
+	base := ""
+	if spr.existing && spr.pr.GetBase().GetRef() != spr.branch.Parent {
+		base = spr.branch.Parent
+	}
+	if base == "" && spr.pr.GetTitle() == spr.title && spr.pr.GetBody() == spr.body {
+		if spr.existing {
+			spr.action = "unchanged"
+			fmt.Fprintf(messages(), "Pull request unchanged: %s (%s → %s)\n", spr.pr.GetHTMLURL(), spr.branch.Name, spr.branch.Parent)
+		}
+		return nil
+	}
+
+	pr, err := client.UpdatePullRequest(ctx, owner, repoName, spr.pr.GetNumber(), spr.title, spr.body, base)
+	if err != nil {
+		return err
+	}
+	spr.pr = pr
+	if !spr.existing {
+		return nil
+	}
+	if base != "" {
+		spr.action = "retargeted"
+		fmt.Fprintf(messages(), "Pull request retargeted: %s (%s → %s)\n", pr.GetHTMLURL(), spr.branch.Name, spr.branch.Parent)
+	} else {
+		spr.action = "updated"
+		fmt.Fprintf(messages(), "Pull request updated: %s (%s → %s)\n", pr.GetHTMLURL(), spr.branch.Name, spr.branch.Parent)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fraser-isbester/cpr/internal/github"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watch         bool
+	watchInterval time.Duration
+	watchGrace    time.Duration
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show PR, review and CI state for the current branch",
+	Long: `Show the pull request opened from the current branch together with its
+draft state, mergeability, review decision, requested reviewers and the
+combined result of its check runs and commit statuses.
+
+With --watch, cpr polls until all checks have finished and exits non-zero
+if any of them failed. Checks take a moment to be registered after a push,
+so a pull request without any is polled for --grace before cpr concludes
+it has none.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := showStatus(cmd.Context()); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	statusCmd.Flags().BoolVarP(&watch, "watch", "w", false, "Poll until checks finish and exit non-zero on failure")
+	statusCmd.Flags().DurationVar(&watchInterval, "interval", 15*time.Second, "Polling interval for --watch")
+	statusCmd.Flags().DurationVar(&watchGrace, "grace", 2*time.Minute, "How long --watch waits for checks to appear")
+	rootCmd.AddCommand(statusCmd)
+}
+
+func showStatus(ctx context.Context) error {
+	bc, err := newBranchContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	pr, err := bc.pullRequest(ctx)
+	if err != nil {
+		return err
+	}
+
+	graceEnds := time.Now().Add(watchGrace)
+	for {
+		status, err := bc.client.GetPullRequestStatus(ctx, bc.owner, bc.name, pr.GetNumber())
+		if err != nil {
+			return err
+		}
+
+		state := status.Checks.State()
+		waiting := state == github.CheckStatePending ||
+			state == github.CheckStateNone && time.Now().Before(graceEnds)
+		if !watch || !waiting {
+			return reportStatus(status, watch && state == github.CheckStateFailure)
+		}
+
+		// Structured output reports only the final state, as one document
+		if !structuredOutput() {
+			printStatus(status)
+		}
+
+		if state == github.CheckStateNone {
+			logger.Info("waiting for checks to appear", "interval", watchInterval, "until", graceEnds.Format(time.TimeOnly))
+		} else {
+			logger.Info("waiting for pending checks", "pending", status.Checks.Pending, "interval", watchInterval)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("stopped watching checks: %w", ctx.Err())
+		case <-time.After(watchInterval):
+		}
+		if !structuredOutput() {
+			fmt.Println()
+		}
+	}
+}
+
+// reportStatus prints the final status of a pull request, and fails if
+// its checks failed.
+func reportStatus(status *github.PullRequestStatus, failed bool) error {
+	result := newStatusResult(status)
+	if !failed {
+		return printResult(result, func() { printStatus(status) })
+	}
+
+	err := fmt.Errorf("%d of %d checks failed", status.Checks.Failure, len(status.Checks.Results))
+	if structuredOutput() {
+		return &resultError{err: err, result: result}
+	}
+	printStatus(status)
+	return err
+}
+
+// statusResult is the structured form of a github.PullRequestStatus.
+type statusResult struct {
+	Number         int          `json:"number" yaml:"number"`
+	URL            string       `json:"url" yaml:"url"`
+	Title          string       `json:"title" yaml:"title"`
+	Draft          bool         `json:"draft" yaml:"draft"`
+	Mergeable      *bool        `json:"mergeable" yaml:"mergeable"`
+	MergeableState string       `json:"mergeable_state,omitempty" yaml:"mergeable_state,omitempty"`
+	ReviewDecision string       `json:"review_decision" yaml:"review_decision"`
+	Reviewers      []string     `json:"reviewers" yaml:"reviewers"`
+	Checks         checksResult `json:"checks" yaml:"checks"`
+}
+
+type checksResult struct {
+	State   string        `json:"state" yaml:"state"`
+	Passed  int           `json:"passed" yaml:"passed"`
+	Pending int           `json:"pending" yaml:"pending"`
+	Failed  int           `json:"failed" yaml:"failed"`
+	Results []checkResult `json:"results" yaml:"results"`
+}
+
+type checkResult struct {
+	Name  string `json:"name" yaml:"name"`
+	State string `json:"state" yaml:"state"`
+}
+
+func newStatusResult(status *github.PullRequestStatus) *statusResult {
+	pr := status.PullRequest
+	result := &statusResult{
+		Number:         pr.GetNumber(),
+		URL:            pr.GetHTMLURL(),
+		Title:          pr.GetTitle(),
+		Draft:          pr.GetDraft(),
+		Mergeable:      pr.Mergeable,
+		MergeableState: pr.GetMergeableState(),
+		ReviewDecision: string(status.ReviewDecision),
+		Reviewers:      append([]string{}, status.RequestedReviewers...),
+		Checks: checksResult{
+			State:   string(status.Checks.State()),
+			Passed:  status.Checks.Success,
+			Pending: status.Checks.Pending,
+			Failed:  status.Checks.Failure,
+			Results: []checkResult{},
+		},
+	}
+	for _, r := range status.Checks.Results {
+		result.Checks.Results = append(result.Checks.Results, checkResult{Name: r.Name, State: string(r.State)})
+	}
+	return result
+}
+
+func printStatus(status *github.PullRequestStatus) {
+	pr := status.PullRequest
+
+	state := "ready for review"
+	if pr.GetDraft() {
+		state = "draft"
+	}
+
+	mergeable := "unknown"
+	if pr.Mergeable != nil {
+		mergeable = fmt.Sprintf("%t (%s)", pr.GetMergeable(), pr.GetMergeableState())
+	}
+
+	decision := status.ReviewDecision
+	if decision == "" {
+		decision = "none"
+	}
+
+	reviewers := "none"
+	if len(status.RequestedReviewers) > 0 {
+		reviewers = strings.Join(status.RequestedReviewers, ", ")
+	}
+
+	fmt.Printf("Pull request #%d: %s\n", pr.GetNumber(), pr.GetTitle())
+	fmt.Printf("URL:        %s\n", pr.GetHTMLURL())
+	fmt.Printf("State:      %s\n", state)
+	fmt.Printf("Mergeable:  %s\n", mergeable)
+	fmt.Printf("Review:     %s\n", decision)
+	fmt.Printf("Reviewers:  %s\n", reviewers)
+	fmt.Printf("Checks:     %s (%d passed, %d pending, %d failed)\n",
+		status.Checks.State(), status.Checks.Success, status.Checks.Pending, status.Checks.Failure)
+
+	for _, result := range status.Checks.Results {
+		fmt.Printf("  - [%s] %s\n", result.State, result.Name)
+	}
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fraser-isbester/cpr/internal/cache"
+	"github.com/fraser-isbester/cpr/internal/commit"
+	"github.com/fraser-isbester/cpr/internal/config"
+	"github.com/fraser-isbester/cpr/internal/git"
+	"github.com/fraser-isbester/cpr/internal/github"
+)
+
+// noTemplate is the --template value that skips the PR template.
+const noTemplate = "none"
+
+// pullRequestTemplates lists the PR templates for a PR from head onto base.
+// They are read from the working tree when head is checked out, then from
+// base's tree, and only then through the API. When base's tree was read and
+// has none, only the owner's .github repository is asked. API results are
+// cached per repository and base commit, or per owner for .github.
+func pullRequestTemplates(ctx context.Context, repo *git.Repository, client *github.Client, owner, name, head, base string) ([]github.PullRequestTemplate, error) {
+	var sources []*git.Files
+	if current, err := repo.CurrentBranch(); err == nil && head != "" && current == head {
+		if files, err := repo.WorkingTree(); err == nil {
+			sources = append(sources, files)
+		}
+	}
+	baseRead := false
+	if files, err := repo.TreeAt(base); err == nil {
+		sources = append(sources, files)
+		baseRead = true
+	} else {
+		logger.Debug("not reading PR templates from base", "base", base, "err", err)
+	}
+
+	for _, files := range sources {
+		templates, err := github.FindPullRequestTemplates(files)
+		if err != nil {
+			return nil, err
+		}
+		if len(templates) > 0 {
+			return templates, nil
+		}
+	}
+
+	var (
+		key  string
+		list func() ([]github.PullRequestTemplate, error)
+	)
+	if baseRead {
+		// The API would only list what base's tree already showed
+		key = fmt.Sprintf("templates/%s/%s", owner, github.OrgDefaultsRepo)
+		list = func() ([]github.PullRequestTemplate, error) {
+			return client.ListOrgPullRequestTemplates(ctx, owner)
+		}
+	} else {
+		if sha, err := repo.BranchCommit(base); err == nil {
+			key = fmt.Sprintf("templates/%s/%s/%s", owner, name, sha)
+		}
+		list = func() ([]github.PullRequestTemplate, error) {
+			return client.ListPullRequestTemplates(ctx, owner, name, base)
+		}
+	}
+
+	store, err := cache.New()
+	if err != nil {
+		logger.Warn("not caching PR templates", "err", err)
+	}
+
+	var templates []github.PullRequestTemplate
+	if key != "" && store.Get(key, &templates) {
+		return templates, nil
+	}
+
+	templates, err = list()
+	if err != nil {
+		return nil, err
+	}
+
+	if key != "" {
+		if err := store.Put(key, templates); err != nil {
+			logger.Warn("failed to cache PR templates", "err", err)
+		}
+	}
+	return templates, nil
+}
+
+// selectTemplate picks the PR template to use: the one named by --template,
+// the only one, the one mapped to the commit type, one picked interactively
+// when interactive is set and cpr runs in a terminal, or else the default
+// template or the first named one.
+func selectTemplate(ctx context.Context, cfg *config.Config, templates []github.PullRequestTemplate, commitType commit.CommitType, interactive bool) (github.PullRequestTemplate, bool, error) {
+	if templateName == noTemplate {
+		return github.PullRequestTemplate{}, false, nil
+	}
+	if templateName != "" {
+		t, ok := github.FindTemplate(templates, templateName)
+		if !ok {
+			return t, false, fmt.Errorf("PR template %q not found; available: %s", templateName, availableTemplates(templates))
+		}
+		return t, true, nil
+	}
+
+	switch len(templates) {
+	case 0:
+		return github.PullRequestTemplate{}, false, nil
+	case 1:
+		return templates[0], true, nil
+	}
+
+	var candidates []string
+	if name, ok := cfg.Template.Types[string(commitType)]; ok {
+		candidates = append(candidates, name)
+	}
+	candidates = append(candidates, github.TemplateCandidates(string(commitType))...)
+	for _, name := range candidates {
+		if t, ok := github.FindTemplate(templates, name); ok {
+			logger.Info("using PR template for commit type", "template", t.Name, "type", commitType)
+			return t, true, nil
+		}
+	}
+
+	if interactive && isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		return pickTemplate(ctx, templates)
+	}
+
+	if t, ok := github.FindTemplate(templates, github.DefaultTemplateName); ok {
+		return t, true, nil
+	}
+	logger.Info("no PR template matches the commit type, using the first; choose one with --template", "type", commitType, "template", templates[0].Name)
+	return templates[0], true, nil
+}
+
+// pickTemplate asks which template to use.
+func pickTemplate(ctx context.Context, templates []github.PullRequestTemplate) (github.PullRequestTemplate, bool, error) {
+	fmt.Fprintln(messages(), "Choose a PR template:")
+	for i, t := range templates {
+		fmt.Fprintf(messages(), "  %d) %s\n", i+1, t.Name)
+	}
+	fmt.Fprintln(messages(), "  0) none")
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Fprintf(messages(), "Template [1]: ")
+		line, err := readLine(ctx, reader)
+		if ctx.Err() != nil {
+			return github.PullRequestTemplate{}, false, ctx.Err()
+		}
+		if err != nil && line == "" {
+			return github.PullRequestTemplate{}, false, fmt.Errorf("failed to read template choice: %w", err)
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return templates[0], true, nil
+		}
+		if t, ok := github.FindTemplate(templates, line); ok {
+			return t, true, nil
+		}
+		n, err := strconv.Atoi(line)
+		switch {
+		case err == nil && n == 0:
+			return github.PullRequestTemplate{}, false, nil
+		case err == nil && n >= 1 && n <= len(templates):
+			return templates[n-1], true, nil
+		}
+		fmt.Fprintf(messages(), "Enter a number between 0 and %d\n", len(templates))
+	}
+}
+
+func availableTemplates(templates []github.PullRequestTemplate) string {
+	if len(templates) == 0 {
+		return "none"
+	}
+	return strings.Join(github.TemplateNames(templates), ", ")
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,702 @@
+package e2e_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+
+	"github.com/fraser-isbester/cpr/internal/github/githubtest"
+)
+
+const remoteURL = "https://github.com/octo/hello.git"
+
+var _ = Describe("cpr", func() {
+	var (
+		server *githubtest.Server
+		root   string
+		work   string
+		origin string
+		// env is added to cpr's environment, overriding the defaults, and
+		// stdin is its input
+		env   []string
+		stdin string
+	)
+
+	git := func(dir string, args ...string) string {
+		GinkgoHelper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+		out, err := cmd.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(out))
+		return strings.TrimSpace(string(out))
+	}
+
+	writeFile := func(name, content string) {
+		GinkgoHelper()
+		path := filepath.Join(work, name)
+		Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+	}
+
+	commit := func(message string) {
+		GinkgoHelper()
+		git(work, "add", "-A")
+		git(work, "commit", "-q", "-m", message)
+	}
+
+	start := func(input io.Reader, args ...string) *gexec.Session {
+		GinkgoHelper()
+		cmd := exec.Command(cprPath, args...)
+		cmd.Dir = work
+		cmd.Env = []string{
+			"PATH=" + os.Getenv("PATH"),
+			"HOME=" + root,
+			"GIT_CONFIG_GLOBAL=/dev/null",
+			"GIT_CONFIG_NOSYSTEM=1",
+			"GITHUB_TOKEN=test-token",
+			"GITHUB_API_URL=" + server.URL(),
+			"CPR_CACHE_DIR=" + filepath.Join(root, "cache"),
+			// Keep away from the user's keyring
+			"DBUS_SESSION_BUS_ADDRESS=unix:path=" + filepath.Join(root, "bus"),
+		}
+		cmd.Env = append(cmd.Env, env...)
+		cmd.Stdin = input
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		return session
+	}
+
+	cpr := func(args ...string) *gexec.Session {
+		GinkgoHelper()
+		session := start(strings.NewReader(stdin), args...)
+		Eventually(session, 30*time.Second).Should(gexec.Exit())
+		return session
+	}
+
+	BeforeEach(func() {
+		server = githubtest.NewServer()
+		server.AddRepo("octo", "hello", "main")
+		env, stdin = nil, ""
+
+		root = GinkgoT().TempDir()
+		origin = filepath.Join(root, "origin.git")
+		work = filepath.Join(root, "work")
+		Expect(os.Mkdir(work, 0o755)).To(Succeed())
+
+		git(root, "init", "-q", "--bare", "-b", "main", origin)
+		git(work, "init", "-q", "-b", "main")
+		git(work, "config", "user.name", "Test User")
+		git(work, "config", "user.email", "test@example.com")
+		// origin names the repository on GitHub but pushes to the bare repo
+		git(work, "remote", "add", "origin", remoteURL)
+		git(work, "config", "url."+origin+".insteadOf", remoteURL)
+
+		writeFile("README.md", "# hello\n")
+		writeFile("go.mod", "module example.com/hello\n")
+		commit("chore: initial commit")
+		git(work, "push", "-q", "origin", "main")
+
+		git(work, "checkout", "-q", "-b", "add-widget")
+		writeFile("internal/widget/widget.go", "package widget\n\n// Widget does things.\ntype Widget struct{}\n\nfunc NewWidget() *Widget {\n\treturn &Widget{}\n}\n")
+		commit("feat(widget): add widget")
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should push the branch and open a pull request", func() {
+		session := cpr()
+		Expect(session).To(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say("Pull request created: https://github.com/octo/hello/pull/1"))
+
+		pulls := server.PullRequests("octo", "hello")
+		Expect(pulls).To(HaveLen(1))
+		Expect(pulls[0].GetTitle()).To(HavePrefix("feat(widget): "))
+		Expect(pulls[0].GetHead().GetRef()).To(Equal("add-widget"))
+		Expect(pulls[0].GetBase().GetRef()).To(Equal("main"))
+		Expect(pulls[0].GetBody()).To(ContainSubstring("internal/widget/widget.go"))
+		Expect(pulls[0].GetDraft()).To(BeFalse())
+
+		Expect(git(origin, "rev-parse", "add-widget")).To(Equal(git(work, "rev-parse", "HEAD")))
+	})
+
+	It("should update the pull request on a second run", func() {
+		Expect(cpr("--draft")).To(gexec.Exit(0))
+
+		writeFile("internal/widget/widget_test.go", "package widget\n")
+		commit("test(widget): cover widget")
+
+		session := cpr("--title", "feat(widget): add widgets", "--ready")
+		Expect(session).To(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say("Pull request updated: https://github.com/octo/hello/pull/1"))
+
+		pulls := server.PullRequests("octo", "hello")
+		Expect(pulls).To(HaveLen(1))
+		Expect(pulls[0].GetTitle()).To(Equal("feat(widget): add widgets"))
+		Expect(pulls[0].GetBody()).To(ContainSubstring("widget_test.go"))
+		Expect(pulls[0].GetDraft()).To(BeFalse())
+	})
+
+	It("should fill the repository's PR template", func() {
+		writeFile(".github/pull_request_template.md", "## Description\n\n<!-- What does this change? -->\n\n## Checklist\n\n- [ ] Tests added\n")
+		commit("docs: add PR template")
+
+		Expect(cpr()).To(gexec.Exit(0))
+
+		body := server.PullRequests("octo", "hello")[0].GetBody()
+		Expect(body).To(HavePrefix("## Description\n"))
+		Expect(body).To(ContainSubstring("internal/widget/widget.go"))
+		Expect(body).To(ContainSubstring("- [ ] Tests added"))
+		Expect(body).NotTo(ContainSubstring("<!--"))
+	})
+
+	It("should only ask the API for the organization's PR template when the checkout has none", func() {
+		server.AddRepo("octo", ".github", "main")
+		server.AddFile("octo", ".github", ".github/pull_request_template.md", "## Summary\n")
+		lookups := func() []string {
+			var found []string
+			for _, request := range server.Requests() {
+				if strings.Contains(request, "/contents/") {
+					found = append(found, request)
+				}
+			}
+			return found
+		}
+
+		Expect(cpr("--draft")).To(gexec.Exit(0))
+		Expect(server.PullRequests("octo", "hello")[0].GetBody()).To(HavePrefix("## Summary\n"))
+		first := lookups()
+		Expect(first).NotTo(BeEmpty())
+		for _, request := range first {
+			Expect(request).To(HavePrefix("GET /repos/octo/.github/"))
+		}
+
+		// The second run finds the owner's templates in the cache
+		Expect(cpr("--ready")).To(gexec.Exit(0))
+		Expect(lookups()).To(Equal(first))
+	})
+
+	It("should not open a duplicate when the create's response is lost", func() {
+		server.FailAfter(http.MethodPost, "/repos/octo/hello/pulls", http.StatusBadGateway, 1)
+
+		session := cpr()
+		Expect(session).To(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say("Pull request created: https://github.com/octo/hello/pull/1"))
+		Expect(server.PullRequests("octo", "hello")).To(HaveLen(1))
+	})
+
+	It("should explain the commit type without creating anything", func() {
+		session := cpr("--explain")
+		Expect(session).To(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say("Commit type classification:"))
+		Expect(server.PullRequests("octo", "hello")).To(BeEmpty())
+		Expect(server.Requests()).To(BeEmpty())
+	})
+
+	It("should explain the commit type offline without a token", func() {
+		env = []string{"GITHUB_TOKEN=", "GITHUB_API_URL=http://127.0.0.1:1"}
+		session := cpr("--explain", "--base", "main")
+		Expect(session).To(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say("Commit type classification:"))
+	})
+
+	Context("when the branch's pull request was merged", func() {
+		BeforeEach(func() {
+			server.AddPullRequest("octo", "hello", "add-widget", "main", "feat(widget): add widget")
+			server.MergePullRequest("octo", "hello", 1, "abc123")
+		})
+
+		It("should refuse to open another without being told to", func() {
+			session := cpr()
+			Expect(session).To(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say("pull request #1 from this branch was merged"))
+			Expect(session.Err).To(gbytes.Say("--existing=new"))
+			Expect(server.PullRequests("octo", "hello")).To(HaveLen(1))
+			Expect(git(root, "--git-dir", origin, "branch", "--list", "add-widget")).To(BeEmpty())
+		})
+
+		It("should open a new one with --existing=new", func() {
+			session := cpr("--existing=new")
+			Expect(session).To(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("Pull request created: https://github.com/octo/hello/pull/2"))
+		})
+
+		It("should not reopen it", func() {
+			session := cpr("--existing=reopen")
+			Expect(session).To(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say("was merged and cannot be reopened"))
+		})
+	})
+
+	Context("when the branch's pull request was closed", func() {
+		BeforeEach(func() {
+			server.AddPullRequest("octo", "hello", "add-widget", "main", "wip")
+			server.ClosePullRequest("octo", "hello", 1)
+		})
+
+		It("should reopen and update it with --existing=reopen", func() {
+			session := cpr("--existing=reopen")
+			Expect(session).To(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("Pull request reopened: https://github.com/octo/hello/pull/1"))
+			Expect(session.Out).To(gbytes.Say("Pull request updated: https://github.com/octo/hello/pull/1"))
+
+			pulls := server.PullRequests("octo", "hello")
+			Expect(pulls).To(HaveLen(1))
+			Expect(pulls[0].GetState()).To(Equal("open"))
+			Expect(pulls[0].GetTitle()).To(HavePrefix("feat(widget): "))
+		})
+
+		It("should reopen the PR given with --pr", func() {
+			Expect(cpr("--pr", "1")).To(gexec.Exit(0))
+			Expect(server.PullRequests("octo", "hello")[0].GetState()).To(Equal("open"))
+		})
+
+		It("should abort with --existing=abort", func() {
+			session := cpr("--existing=abort")
+			Expect(session).To(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say("aborted: pull request #1 from this branch was closed"))
+		})
+	})
+
+	It("should refuse a --pr opened from another branch", func() {
+		server.AddPullRequest("octo", "hello", "other", "main", "fix: other")
+
+		session := cpr("--pr", "1")
+		Expect(session).To(gexec.Exit(1))
+		Expect(session.Err).To(gbytes.Say("pull request #1 is opened from octo:other, not add-widget"))
+	})
+
+	It("should open pull requests from a fork onto upstream", func() {
+		fork := filepath.Join(root, "fork.git")
+		forkURL := "https://github.com/alice/hello.git"
+		git(root, "clone", "-q", "--bare", origin, fork)
+		git(work, "remote", "rename", "origin", "upstream")
+		git(work, "remote", "add", "origin", forkURL)
+		git(work, "config", "url."+fork+".insteadOf", forkURL)
+		git(work, "fetch", "-q", "origin")
+
+		session := cpr("--base", "main")
+		Expect(session).To(gexec.Exit(0))
+
+		pulls := server.PullRequests("octo", "hello")
+		Expect(pulls).To(HaveLen(1))
+		Expect(pulls[0].GetHead().GetLabel()).To(Equal("alice:add-widget"))
+		Expect(git(fork, "rev-parse", "add-widget")).To(Equal(git(work, "rev-parse", "HEAD")))
+
+		session = cpr("--base", "main")
+		Expect(session).To(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say("Pull request updated: https://github.com/octo/hello/pull/1"))
+	})
+
+	It("should target upstream's default branch from a fork", func() {
+		server.AddRepo("octo", "hello", "develop")
+		git(work, "push", "-q", "origin", "main:develop")
+		fork := filepath.Join(root, "fork.git")
+		forkURL := "https://github.com/alice/hello.git"
+		git(root, "clone", "-q", "--bare", origin, fork)
+		git(work, "remote", "rename", "origin", "upstream")
+		git(work, "remote", "add", "origin", forkURL)
+		git(work, "config", "url."+fork+".insteadOf", forkURL)
+		git(work, "fetch", "-q", "origin")
+		git(work, "branch", "develop", "main")
+
+		Expect(cpr()).To(gexec.Exit(0))
+		Expect(server.PullRequests("octo", "hello")[0].GetBase().GetRef()).To(Equal("develop"))
+	})
+
+	It("should open a backport's pull request on a rerun after it failed to", func() {
+		git(work, "push", "-q", "origin", "main:release/1.0")
+		git(work, "push", "-q", "origin", "HEAD:refs/pull/1/head")
+		server.AddPullRequest("octo", "hello", "add-widget", "main", "feat(widget): add widget")
+		server.SetCommits("octo", "hello", 1, git(work, "rev-parse", "HEAD"))
+		server.Fail(http.MethodPost, "/repos/octo/hello/pulls", http.StatusUnprocessableEntity, 1)
+
+		session := cpr("backport", "1", "--to", "release/1.0")
+		Expect(session).To(gexec.Exit(1))
+		Expect(session.Out).To(gbytes.Say("Skipping release/1.0"))
+		Expect(server.PullRequests("octo", "hello")).To(HaveLen(1))
+
+		session = cpr("backport", "1", "--to", "release/1.0")
+		Expect(session).To(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say("Backport created: https://github.com/octo/hello/pull/2"))
+		Expect(server.PullRequests("octo", "hello")[1].GetHead().GetRef()).To(Equal("backport/1-to-release-1.0"))
+
+		session = cpr("backport", "1", "--to", "release/1.0")
+		Expect(session).To(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say("Backport exists: https://github.com/octo/hello/pull/2"))
+	})
+
+	Describe("stack", func() {
+		BeforeEach(func() {
+			git(work, "checkout", "-q", "-b", "add-gadget")
+			writeFile("internal/gadget/gadget.go", "package gadget\n\n// Gadget does other things.\ntype Gadget struct{}\n")
+			commit("feat(gadget): add gadget")
+		})
+
+		It("should leave up-to-date pull requests alone on a second run", func() {
+			Expect(cpr("stack")).To(gexec.Exit(0))
+			Expect(server.PullRequests("octo", "hello")).To(HaveLen(2))
+			seen := len(server.Requests())
+
+			session := cpr("stack")
+			Expect(session).To(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("Pull request unchanged: https://github.com/octo/hello/pull/1"))
+			Expect(session.Out).To(gbytes.Say("Pull request unchanged: https://github.com/octo/hello/pull/2"))
+			for _, request := range server.Requests()[seen:] {
+				Expect(request).NotTo(HavePrefix("PATCH "))
+			}
+		})
+
+		It("should drop a branch whose pull request merged and keep a reused branch name", func() {
+			old := server.AddPullRequest("octo", "hello", "add-widget", "main", "feat(widget): an older widget")
+			server.MergePullRequest("octo", "hello", old.GetNumber(), "abc123")
+
+			Expect(cpr("stack")).To(gexec.Exit(0))
+			pulls := server.PullRequests("octo", "hello")
+			Expect(pulls).To(HaveLen(3))
+			Expect(pulls[2].GetHead().GetRef()).To(Equal("add-gadget"))
+			Expect(pulls[2].GetBase().GetRef()).To(Equal("add-widget"))
+
+			server.SetHeadSHA("octo", "hello", pulls[1].GetNumber(), git(work, "rev-parse", "add-widget"))
+			server.MergePullRequest("octo", "hello", pulls[1].GetNumber(), "def456")
+
+			Expect(cpr("stack")).To(gexec.Exit(0))
+			Expect(server.PullRequests("octo", "hello")[2].GetBase().GetRef()).To(Equal("main"))
+		})
+	})
+
+	It("should wait for checks to appear when watching", func() {
+		pr := server.AddPullRequest("octo", "hello", "add-widget", "main", "feat(widget): add widget")
+		checkRuns := func() int {
+			n := 0
+			for _, request := range server.Requests() {
+				if strings.HasSuffix(request, "/check-runs") {
+					n++
+				}
+			}
+			return n
+		}
+
+		session := start(strings.NewReader(""), "status", "--watch", "--interval", "50ms")
+		Eventually(checkRuns, 5*time.Second).Should(BeNumerically(">=", 2))
+		server.AddCheckRun("octo", "hello", pr.GetHead().GetSHA(), "build", "failure")
+		Eventually(session, 5*time.Second).Should(gexec.Exit(1))
+		Expect(session.Err).To(gbytes.Say("1 of 1 checks failed"))
+	})
+
+	It("should stop watching once the grace period passes without checks", func() {
+		server.AddPullRequest("octo", "hello", "add-widget", "main", "feat(widget): add widget")
+
+		session := cpr("status", "--watch", "--interval", "50ms", "--grace", "200ms")
+		Expect(session).To(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say("Checks:     none"))
+	})
+
+	It("should leave pull requests from upstream's same-named branch alone", func() {
+		fork := filepath.Join(root, "fork.git")
+		forkURL := "https://github.com/alice/hello.git"
+		git(root, "clone", "-q", "--bare", origin, fork)
+		git(work, "remote", "rename", "origin", "upstream")
+		git(work, "remote", "add", "origin", forkURL)
+		git(work, "config", "url."+fork+".insteadOf", forkURL)
+		git(work, "fetch", "-q", "origin")
+		server.AddPullRequest("octo", "hello", "add-widget", "main", "feat: their widget")
+
+		session := cpr("--base", "main")
+		Expect(session).To(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say("Pull request created: https://github.com/octo/hello/pull/2"))
+
+		pulls := server.PullRequests("octo", "hello")
+		Expect(pulls).To(HaveLen(2))
+		Expect(pulls[0].GetHead().GetLabel()).To(Equal("octo:add-widget"))
+		Expect(pulls[0].GetTitle()).To(Equal("feat: their widget"))
+		Expect(pulls[0].GetState()).To(Equal("open"))
+		Expect(pulls[1].GetHead().GetLabel()).To(Equal("alice:add-widget"))
+	})
+
+	It("should fail without changes against the base", func() {
+		git(work, "checkout", "-q", "-b", "empty", "main")
+
+		session := cpr()
+		Expect(session).To(gexec.Exit(4))
+		Expect(session.Err).To(gbytes.Say("no changes detected between empty and main"))
+	})
+
+	Context("with logging", func() {
+		It("should stay quiet by default", func() {
+			session := cpr()
+			Expect(session).To(gexec.Exit(0))
+			Expect(session.Err.Contents()).To(BeEmpty())
+		})
+
+		It("should trace API requests as JSON without leaking the token", func() {
+			session := cpr("--log-level", "trace", "--log-format", "json")
+			Expect(session).To(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("Pull request created"))
+
+			stderr := string(session.Err.Contents())
+			Expect(stderr).NotTo(ContainSubstring("test-token"))
+
+			var dumps []string
+			for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+				var entry map[string]any
+				Expect(json.Unmarshal([]byte(line), &entry)).To(Succeed(), line)
+				if entry["msg"] == "GitHub API request dump" {
+					Expect(entry).To(HaveKeyWithValue("level", "TRACE"))
+					dumps = append(dumps, entry["dump"].(string))
+				}
+			}
+			Expect(dumps).NotTo(BeEmpty())
+			Expect(dumps[0]).To(ContainSubstring("Authorization: [REDACTED]"))
+		})
+
+		It("should reject unknown levels", func() {
+			session := cpr("--log-level", "loud")
+			Expect(session).To(gexec.Exit(2))
+			Expect(session.Err).To(gbytes.Say(`invalid log level "loud"`))
+		})
+	})
+
+	Context("with structured output", func() {
+		It("should print the pull request as JSON, keeping messages off stdout", func() {
+			session := cpr("--output", "json", "--verbose")
+			Expect(session).To(gexec.Exit(0))
+			Expect(session.Err).To(gbytes.Say(`level=INFO msg="resolved repository" repo=octo/hello`))
+
+			var result map[string]any
+			Expect(json.Unmarshal(session.Out.Contents(), &result)).To(Succeed())
+			Expect(result).To(HaveKeyWithValue("number", BeEquivalentTo(1)))
+			Expect(result).To(HaveKeyWithValue("url", "https://github.com/octo/hello/pull/1"))
+			Expect(result).To(HaveKeyWithValue("created", true))
+			Expect(result).To(HaveKeyWithValue("base", "main"))
+			Expect(result).To(HaveKeyWithValue("head", "add-widget"))
+			Expect(result).To(HaveKeyWithValue("type", "feat"))
+			Expect(result).To(HaveKeyWithValue("title", HavePrefix("feat(widget): ")))
+			Expect(result).To(HaveKeyWithValue("labels", BeEmpty()))
+			Expect(result).To(HaveKey("body"))
+		})
+
+		It("should report failures as error documents with the exit code of their class", func() {
+			git(work, "checkout", "-q", "-b", "empty", "main")
+
+			session := cpr("-o", "json")
+			Expect(session).To(gexec.Exit(4))
+
+			var doc struct {
+				Error struct {
+					Class   string
+					Code    int
+					Message string
+				}
+			}
+			Expect(json.Unmarshal(session.Out.Contents(), &doc)).To(Succeed())
+			Expect(doc.Error.Class).To(Equal("git"))
+			Expect(doc.Error.Code).To(Equal(4))
+			Expect(doc.Error.Message).To(Equal("no changes detected between empty and main"))
+		})
+
+		It("should list the problems with a title in YAML", func() {
+			session := cpr("lint-title", "Added stuff.", "-o", "yaml")
+			Expect(session).To(gexec.Exit(2))
+			Expect(session.Out).To(gbytes.Say("error:\n  class: usage\n  code: 2\n"))
+			Expect(session.Out).To(gbytes.Say("problems:\n    - "))
+		})
+
+		It("should reject unknown formats as usage errors", func() {
+			session := cpr("status", "--output", "xml")
+			Expect(session).To(gexec.Exit(2))
+			Expect(session.Err).To(gbytes.Say(`invalid --output "xml"`))
+		})
+	})
+
+	Context("with authentication", func() {
+		BeforeEach(func() {
+			// No token in the environment, and tokens kept in a file
+			gitconfig := filepath.Join(root, "gitconfig")
+			helper := "[credential]\n\thelper = store --file " + filepath.Join(root, "credentials") + "\n"
+			Expect(os.WriteFile(gitconfig, []byte(helper), 0o600)).To(Succeed())
+			env = []string{"GITHUB_TOKEN=", "GIT_CONFIG_GLOBAL=" + gitconfig}
+		})
+
+		It("should fail with the auth exit code without a token", func() {
+			session := cpr()
+			Expect(session).To(gexec.Exit(3))
+			Expect(session.Err).To(gbytes.Say("GitHub token not found for 127.0.0.1"))
+		})
+
+		It("should stop waiting for a token on Ctrl-C", func() {
+			r, w, err := os.Pipe()
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(r.Close)
+			DeferCleanup(w.Close)
+
+			session := start(r, "auth", "login", "--with-token")
+			Consistently(session, 500*time.Millisecond).ShouldNot(gexec.Exit())
+			session.Interrupt()
+			Eventually(session, 5*time.Second).Should(gexec.Exit(130))
+		})
+
+		It("should log in with a token from stdin, use it and log out", func() {
+			server.SetUser("hubot")
+			stdin = "ghp_stored\n"
+			session := cpr("auth", "login", "--with-token", "--store", "git")
+			Expect(session).To(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("Logged in to 127.0.0.1 as hubot, token stored in the git credential helper"))
+
+			session = cpr("auth", "status", "-o", "json")
+			Expect(session).To(gexec.Exit(0))
+			var status map[string]any
+			Expect(json.Unmarshal(session.Out.Contents(), &status)).To(Succeed())
+			Expect(status).To(HaveKeyWithValue("user", "hubot"))
+			Expect(status).To(HaveKeyWithValue("source", "git credential helper"))
+			Expect(status).To(HaveKeyWithValue("valid", true))
+
+			Expect(cpr()).To(gexec.Exit(0))
+
+			session = cpr("auth", "logout")
+			Expect(session).To(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("Logged out of 127.0.0.1"))
+			Expect(cpr("auth", "status")).To(gexec.Exit(3))
+		})
+	})
+
+	It("should fail before pushing when the token lacks the scope to open pull requests", func() {
+		server.SetPrivate("octo", "hello", true)
+		server.SetTokenScopes("public_repo")
+
+		session := cpr()
+		Expect(session).To(gexec.Exit(3))
+		Expect(session.Err).To(gbytes.Say("token lacks the repo scope needed to open pull requests on octo/hello"))
+		Expect(git(origin, "branch", "--list", "add-widget")).To(BeEmpty())
+	})
+
+	It("should name the permission GitHub asks for when it refuses the pull request", func() {
+		server.SetFineGrainedToken(false)
+
+		session := cpr()
+		Expect(session).To(gexec.Exit(3))
+		Expect(session.Err).To(gbytes.Say("cannot verify before pushing that the token has the Pull requests: write permission"))
+		Expect(session.Err).To(gbytes.Say("token lacks the Pull requests: write permission needed to open pull requests on octo/hello"))
+		Expect(session.Err).To(gbytes.Say("pull-requests: write"))
+		// Refused once, not retried
+		creates := 0
+		for _, request := range server.Requests() {
+			if request == "POST /repos/octo/hello/pulls" {
+				creates++
+			}
+		}
+		Expect(creates).To(Equal(1))
+		Expect(server.PullRequests("octo", "hello")).To(BeEmpty())
+	})
+
+	Context("doctor", func() {
+		type diagnosis struct {
+			Name, Status, Message, Hint string
+		}
+		diagnoses := func(out []byte) map[string]diagnosis {
+			GinkgoHelper()
+			var doc struct {
+				Checks []diagnosis
+				Result struct{ Checks []diagnosis }
+			}
+			Expect(json.Unmarshal(out, &doc)).To(Succeed())
+			checks := map[string]diagnosis{}
+			for _, d := range append(doc.Checks, doc.Result.Checks...) {
+				checks[d.Name] = d
+			}
+			return checks
+		}
+
+		It("should pass every check cpr depends on", func() {
+			session := cpr("doctor", "-o", "json")
+			Expect(session).To(gexec.Exit(0))
+
+			checks := diagnoses(session.Out.Contents())
+			Expect(checks).To(HaveKeyWithValue("current branch", diagnosis{Name: "current branch", Status: "pass", Message: "add-widget"}))
+			Expect(checks).To(HaveKeyWithValue("remote URL", HaveField("Message", "octo/hello")))
+			Expect(checks).To(HaveKeyWithValue("token", HaveField("Message", "from environment")))
+			Expect(checks).To(HaveKeyWithValue("GitHub API", HaveField("Message", HavePrefix("authenticated as octocat"))))
+			Expect(checks).To(HaveKeyWithValue("token permissions", HaveField("Status", "pass")))
+			Expect(checks).To(HaveKeyWithValue("push", HaveField("Status", "pass")))
+			// The fake API is not on github.com, where origin is
+			Expect(checks).To(HaveKeyWithValue("GitHub host", HaveField("Status", "warn")))
+			Expect(git(origin, "branch", "--list", "add-widget")).To(BeEmpty())
+		})
+
+		It("should fail with hints on a detached HEAD without a token", func() {
+			git(work, "checkout", "-q", "--detach")
+			env = []string{"GITHUB_TOKEN="}
+
+			session := cpr("doctor", "-o", "json")
+			Expect(session).To(gexec.Exit(1))
+
+			checks := diagnoses(session.Out.Contents())
+			Expect(checks).To(HaveKeyWithValue("current branch", HaveField("Status", "fail")))
+			Expect(checks).To(HaveKeyWithValue("current branch", HaveField("Hint", ContainSubstring("git switch"))))
+			Expect(checks).To(HaveKeyWithValue("token", HaveField("Status", "fail")))
+			Expect(checks).NotTo(HaveKey("GitHub API"))
+		})
+
+		It("should check permissions on upstream when origin is a fork", func() {
+			fork := filepath.Join(root, "fork.git")
+			forkURL := "https://github.com/alice/hello.git"
+			git(root, "clone", "-q", "--bare", origin, fork)
+			git(work, "remote", "rename", "origin", "upstream")
+			git(work, "remote", "add", "origin", forkURL)
+			git(work, "config", "url."+fork+".insteadOf", forkURL)
+
+			session := cpr("doctor", "-o", "json")
+			Expect(session).To(gexec.Exit(0))
+
+			checks := diagnoses(session.Out.Contents())
+			Expect(checks).To(HaveKeyWithValue("remote URL", HaveField("Message", "alice/hello, a fork of octo/hello where pull requests are opened")))
+			Expect(checks).To(HaveKeyWithValue("token permissions", HaveField("Message", ContainSubstring("on octo/hello"))))
+		})
+
+		It("should warn that a fine-grained token's pull request permission is unverified", func() {
+			server.SetFineGrainedToken(true)
+
+			session := cpr("doctor", "-o", "json")
+			Expect(session).To(gexec.Exit(0))
+
+			checks := diagnoses(session.Out.Contents())
+			Expect(checks).To(HaveKeyWithValue("token permissions", HaveField("Status", "warn")))
+			Expect(checks).To(HaveKeyWithValue("token permissions", HaveField("Hint", ContainSubstring("Pull requests: write"))))
+		})
+
+		It("should check the host of an SSH remote on GitHub Enterprise Server", func() {
+			sshURL := "git@ghe.example.com:octo/hello.git"
+			git(work, "remote", "set-url", "origin", sshURL)
+			git(work, "config", "url."+origin+".insteadOf", sshURL)
+
+			session := cpr("doctor", "-o", "json")
+			Expect(session).To(gexec.Exit(0))
+
+			checks := diagnoses(session.Out.Contents())
+			Expect(checks).To(HaveKeyWithValue("remote URL", HaveField("Message", "octo/hello")))
+			Expect(checks).To(HaveKeyWithValue("GitHub host", HaveField("Message", ContainSubstring("origin is on ghe.example.com"))))
+		})
+
+		It("should report a token that may not open pull requests", func() {
+			server.SetPrivate("octo", "hello", true)
+			server.SetTokenScopes("public_repo")
+
+			session := cpr("doctor")
+			Expect(session).To(gexec.Exit(1))
+			Expect(session.Out).To(gbytes.Say(`\[fail\] token permissions: token lacks the repo scope`))
+			Expect(session.Out).To(gbytes.Say("1 failed"))
+		})
+	})
+})
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the REST API of github.com.
+const DefaultAPIURL = "https://api.github.com/"
+
+// App authenticates as an installation of a GitHub App: it signs a JWT with
+// the app's private key and exchanges it for an installation token.
+type App struct {
+	ID int64
+	// InstallationID is the installation to get a token for. If 0, the app
+	// must be installed exactly once.
+	InstallationID int64
+	PrivateKey     []byte
+	// APIURL is the REST API, by default api.github.com.
+	APIURL string
+	// HTTPClient sends the requests, by default one that gives up on a
+	// request after appRequestTimeout.
+	HTTPClient *http.Client
+	// Now returns the time JWTs are issued at, by default time.Now.
+	Now func() time.Time
+}
+
+// AppFromEnv configures an App from GITHUB_APP_ID, GITHUB_APP_PRIVATE_KEY_PATH
+// or GITHUB_APP_PRIVATE_KEY and, optionally, GITHUB_APP_INSTALLATION_ID. Its
+// Token returns ErrNotFound if GITHUB_APP_ID is not set.
+func AppFromEnv(apiURL string) Source {
+	return appFromEnv{apiURL: apiURL}
+}
+
+type appFromEnv struct {
+	apiURL string
+}
+
+func (appFromEnv) Name() string { return "GitHub App" }
+
+func (a appFromEnv) Token(ctx context.Context, host string) (string, error) {
+	id := os.Getenv("GITHUB_APP_ID")
+	if id == "" {
+		return "", ErrNotFound
+	}
+
+	app := &App{APIURL: a.apiURL}
+	var err error
+	if app.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return "", fmt.Errorf("invalid GITHUB_APP_ID %q", id)
+	}
+	if installation := os.Getenv("GITHUB_APP_INSTALLATION_ID"); installation != "" {
+		if app.InstallationID, err = strconv.ParseInt(installation, 10, 64); err != nil {
+			return "", fmt.Errorf("invalid GITHUB_APP_INSTALLATION_ID %q", installation)
+		}
+	}
+
+	switch {
+	case os.Getenv("GITHUB_APP_PRIVATE_KEY_PATH") != "":
+		if app.PrivateKey, err = os.ReadFile(os.Getenv("GITHUB_APP_PRIVATE_KEY_PATH")); err != nil {
+			return "", fmt.Errorf("failed to read GitHub App private key: %w", err)
+		}
+	case os.Getenv("GITHUB_APP_PRIVATE_KEY") != "":
+		app.PrivateKey = []byte(os.Getenv("GITHUB_APP_PRIVATE_KEY"))
+	default:
+		return "", errors.New("GITHUB_APP_ID is set without GITHUB_APP_PRIVATE_KEY_PATH or GITHUB_APP_PRIVATE_KEY")
+	}
+
+	return app.Token(ctx, host)
+}
+
+func (a *App) Name() string { return "GitHub App" }
+
+// Token returns an installation token. host is not used: the app's API URL
+// decides which server issues it.
+func (a *App) Token(ctx context.Context, host string) (string, error) {
+	jwt, err := a.JWT()
+	if err != nil {
+		return "", err
+	}
+
+	installation := a.InstallationID
+	if installation == 0 {
+		if installation, err = a.findInstallation(ctx, jwt); err != nil {
+			return "", err
+		}
+	}
+
+	var token struct {
+		Token string `json:"token"`
+	}
+	path := fmt.Sprintf("app/installations/%d/access_tokens", installation)
+	if err := a.do(ctx, http.MethodPost, path, jwt, &token); err != nil {
+		return "", fmt.Errorf("failed to create installation token: %w", err)
+	}
+	if token.Token == "" {
+		return "", errors.New("failed to create installation token: empty token in response")
+	}
+	return token.Token, nil
+}
+
+// JWT returns a token authenticating as the app itself, valid for nine
+// minutes. It is issued a minute in the past to allow for clock drift.
+func (a *App) JWT() (string, error) {
+	key, err := parsePrivateKey(a.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	issued := now().Add(-time.Minute)
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iat": issued.Unix(),
+		"exp": issued.Add(10 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(a.ID, 10),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWT claims: %w", err)
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (a *App) findInstallation(ctx context.Context, jwt string) (int64, error) {
+	var installations []struct {
+		ID      int64 `json:"id"`
+		Account struct {
+			Login string `json:"login"`
+		} `json:"account"`
+	}
+	if err := a.do(ctx, http.MethodGet, "app/installations", jwt, &installations); err != nil {
+		return 0, fmt.Errorf("failed to list GitHub App installations: %w", err)
+	}
+
+	switch len(installations) {
+	case 0:
+		return 0, errors.New("GitHub App is not installed anywhere")
+	case 1:
+		return installations[0].ID, nil
+	}
+	accounts := make([]string, 0, len(installations))
+	for _, installation := range installations {
+		accounts = append(accounts, fmt.Sprintf("%s (%d)", installation.Account.Login, installation.ID))
+	}
+	return 0, fmt.Errorf("GitHub App is installed on %s, set GITHUB_APP_INSTALLATION_ID to choose one", strings.Join(accounts, ", "))
+}
+
+// appRequestTimeout bounds a request for an app's token, as the GitHub
+// client bounds API requests.
+const appRequestTimeout = 30 * time.Second
+
+var appHTTPClient = &http.Client{Timeout: appRequestTimeout}
+
+func (a *App) do(ctx context.Context, method, path, jwt string, v any) error {
+	base := a.APIURL
+	if base == "" {
+		base = DefaultAPIURL
+	}
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, base+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	client := a.HTTPClient
+	if client == nil {
+		client = appHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(body, &apiErr)
+		return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, apiErr.Message)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// parsePrivateKey parses a PEM encoded RSA key in the PKCS #1 format GitHub
+// generates, or PKCS #8.
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to parse GitHub App private key: no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub App private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("failed to parse GitHub App private key: not an RSA key")
+	}
+	return key, nil
+}
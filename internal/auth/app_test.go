@@ -0,0 +1,147 @@
+package auth_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/auth"
+)
+
+var _ = Describe("App", func() {
+	var (
+		key           *rsa.PrivateKey
+		keyPEM        []byte
+		server        *httptest.Server
+		installations string
+		requests      []string
+	)
+	ctx := context.Background()
+
+	// verify checks the request's JWT was signed by key for app 42.
+	verify := func(r *http.Request) {
+		jwt, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		Expect(ok).To(BeTrue())
+		parts := strings.Split(jwt, ".")
+		Expect(parts).To(HaveLen(3))
+
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		Expect(err).NotTo(HaveOccurred())
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		Expect(rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature)).To(Succeed())
+
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		Expect(err).NotTo(HaveOccurred())
+		var claims struct {
+			Iat int64  `json:"iat"`
+			Exp int64  `json:"exp"`
+			Iss string `json:"iss"`
+		}
+		Expect(json.Unmarshal(payload, &claims)).To(Succeed())
+		Expect(claims.Iss).To(Equal("42"))
+		Expect(claims.Exp - claims.Iat).To(BeNumerically("<=", 600))
+		Expect(claims.Iat).To(BeNumerically("<", time.Now().Unix()))
+	}
+
+	BeforeEach(func() {
+		var err error
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+		installations = `[{"id": 7, "account": {"login": "octo-org"}}]`
+		requests = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			verify(r)
+			requests = append(requests, r.Method+" "+r.URL.Path)
+			switch {
+			case r.Method == http.MethodGet && r.URL.Path == "/app/installations":
+				w.Write([]byte(installations))
+			case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/app/installations/"):
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"token": "ghs_installation", "expires_at": "2030-01-01T00:00:00Z"}`))
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		DeferCleanup(server.Close)
+	})
+
+	It("should exchange a JWT for the token of the given installation", func() {
+		app := &auth.App{ID: 42, InstallationID: 9, PrivateKey: keyPEM, APIURL: server.URL}
+		Expect(app.Token(ctx, auth.DefaultHost)).To(Equal("ghs_installation"))
+		Expect(requests).To(Equal([]string{"POST /app/installations/9/access_tokens"}))
+	})
+
+	It("should use the app's only installation", func() {
+		app := &auth.App{ID: 42, PrivateKey: keyPEM, APIURL: server.URL}
+		Expect(app.Token(ctx, auth.DefaultHost)).To(Equal("ghs_installation"))
+		Expect(requests).To(Equal([]string{"GET /app/installations", "POST /app/installations/7/access_tokens"}))
+	})
+
+	It("should ask for an installation when there are several", func() {
+		installations = `[{"id": 7, "account": {"login": "octo-org"}}, {"id": 8, "account": {"login": "octocat"}}]`
+		app := &auth.App{ID: 42, PrivateKey: keyPEM, APIURL: server.URL}
+		_, err := app.Token(ctx, auth.DefaultHost)
+		Expect(err).To(MatchError(ContainSubstring("installed on octo-org (7), octocat (8), set GITHUB_APP_INSTALLATION_ID")))
+	})
+
+	It("should accept PKCS #8 keys", func() {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		Expect(err).NotTo(HaveOccurred())
+		app := &auth.App{ID: 42, InstallationID: 9, APIURL: server.URL,
+			PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})}
+		Expect(app.Token(ctx, auth.DefaultHost)).To(Equal("ghs_installation"))
+	})
+
+	It("should reject a malformed key", func() {
+		app := &auth.App{ID: 42, PrivateKey: []byte("not a key")}
+		_, err := app.JWT()
+		Expect(err).To(MatchError(ContainSubstring("no PEM block found")))
+	})
+
+	Describe("AppFromEnv", func() {
+		BeforeEach(func() {
+			for _, name := range []string{"GITHUB_APP_ID", "GITHUB_APP_INSTALLATION_ID", "GITHUB_APP_PRIVATE_KEY", "GITHUB_APP_PRIVATE_KEY_PATH"} {
+				GinkgoT().Setenv(name, "")
+			}
+		})
+
+		It("should return ErrNotFound unless GITHUB_APP_ID is set", func() {
+			_, err := auth.AppFromEnv(server.URL).Token(ctx, auth.DefaultHost)
+			Expect(err).To(MatchError(auth.ErrNotFound))
+		})
+
+		It("should read the private key from GITHUB_APP_PRIVATE_KEY_PATH", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "app.pem")
+			Expect(os.WriteFile(path, keyPEM, 0o600)).To(Succeed())
+			GinkgoT().Setenv("GITHUB_APP_ID", "42")
+			GinkgoT().Setenv("GITHUB_APP_INSTALLATION_ID", "9")
+			GinkgoT().Setenv("GITHUB_APP_PRIVATE_KEY_PATH", path)
+
+			Expect(auth.AppFromEnv(server.URL).Token(ctx, auth.DefaultHost)).To(Equal("ghs_installation"))
+		})
+
+		It("should fail without a private key", func() {
+			GinkgoT().Setenv("GITHUB_APP_ID", "42")
+			_, err := auth.AppFromEnv(server.URL).Token(ctx, auth.DefaultHost)
+			Expect(err).To(MatchError(ContainSubstring("without GITHUB_APP_PRIVATE_KEY_PATH")))
+		})
+	})
+})
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DefaultHost is the host of github.com's API tokens.
+const DefaultHost = "github.com"
+
+// ErrNotFound is returned by a Source that has no token for a host.
+var ErrNotFound = errors.New("no token")
+
+// Source is one place a token may come from.
+type Source interface {
+	// Name identifies the source to people, e.g. "GITHUB_TOKEN".
+	Name() string
+	// Token returns the token for host, or ErrNotFound.
+	Token(ctx context.Context, host string) (string, error)
+}
+
+// Credential is a token and where it came from.
+type Credential struct {
+	Token  string
+	Source string
+}
+
+// NotFoundError reports that no source had a token for Host.
+type NotFoundError struct {
+	Host    string
+	Sources []string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("GitHub token not found for %s, looked in: %s\n"+
+		"set GITHUB_TOKEN, run 'cpr auth login' or 'gh auth login', or configure a GitHub App with GITHUB_APP_ID and GITHUB_APP_PRIVATE_KEY_PATH",
+		e.Host, strings.Join(e.Sources, ", "))
+}
+
+// Chain tries its sources in order.
+type Chain []Source
+
+// Resolve returns the token of the first source that has one for host. A
+// source failing for another reason than having no token stops the chain,
+// as it was meant to be used, e.g. a GitHub App whose key cannot be read.
+func (c Chain) Resolve(ctx context.Context, host string) (*Credential, error) {
+	names := make([]string, 0, len(c))
+	for _, source := range c {
+		token, err := source.Token(ctx, host)
+		if errors.Is(err, ErrNotFound) {
+			names = append(names, source.Name())
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get token from %s: %w", source.Name(), err)
+		}
+		return &Credential{Token: token, Source: source.Name()}, nil
+	}
+	return nil, &NotFoundError{Host: host, Sources: names}
+}
+
+// DefaultChain looks for a token in the environment, then a GitHub App
+// configured in the environment, cpr's entry in the keyring, the gh CLI,
+// gh's configuration when gh is not installed and finally git's credential
+// helpers. apiURL is the REST API GitHub App tokens are requested from, ""
+// for github.com.
+func DefaultChain(apiURL string) Chain {
+	return Chain{
+		Env{},
+		AppFromEnv(apiURL),
+		Keyring{},
+		GHCLI{},
+		GHConfig{},
+		GitCredential{},
+	}
+}
+
+// HostFromAPIURL returns the host tokens for the REST API at apiURL belong
+// to: github.com for api.github.com, and the server's host for GitHub
+// Enterprise Server.
+func HostFromAPIURL(apiURL string) string {
+	if apiURL == "" {
+		return DefaultHost
+	}
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Host == "" {
+		return DefaultHost
+	}
+	host := u.Hostname()
+	if host == "api.github.com" {
+		return DefaultHost
+	}
+	return strings.TrimPrefix(host, "api.")
+}
@@ -0,0 +1,104 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/auth"
+)
+
+// fakeSource returns token or err for every host.
+type fakeSource struct {
+	name  string
+	token string
+	err   error
+}
+
+func (s fakeSource) Name() string { return s.name }
+
+func (s fakeSource) Token(ctx context.Context, host string) (string, error) {
+	return s.token, s.err
+}
+
+var _ = Describe("Auth", func() {
+	ctx := context.Background()
+
+	Describe("Chain", func() {
+		It("should return the token of the first source that has one", func() {
+			chain := auth.Chain{
+				fakeSource{name: "first", err: auth.ErrNotFound},
+				fakeSource{name: "second", token: "second-token"},
+				fakeSource{name: "third", token: "third-token"},
+			}
+			cred, err := chain.Resolve(ctx, auth.DefaultHost)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cred).To(Equal(&auth.Credential{Token: "second-token", Source: "second"}))
+		})
+
+		It("should stop at a source that fails", func() {
+			chain := auth.Chain{
+				fakeSource{name: "broken", err: errors.New("bad key")},
+				fakeSource{name: "second", token: "second-token"},
+			}
+			_, err := chain.Resolve(ctx, auth.DefaultHost)
+			Expect(err).To(MatchError("failed to get token from broken: bad key"))
+		})
+
+		It("should list the sources it looked in when none has a token", func() {
+			chain := auth.Chain{
+				fakeSource{name: "first", err: auth.ErrNotFound},
+				fakeSource{name: "second", err: auth.ErrNotFound},
+			}
+			_, err := chain.Resolve(ctx, "ghe.example.com")
+
+			var notFound *auth.NotFoundError
+			Expect(errors.As(err, &notFound)).To(BeTrue())
+			Expect(notFound.Sources).To(Equal([]string{"first", "second"}))
+			Expect(err.Error()).To(ContainSubstring("GitHub token not found for ghe.example.com, looked in: first, second"))
+		})
+	})
+
+	Describe("HostFromAPIURL", func() {
+		It("should map github.com's API to github.com", func() {
+			Expect(auth.HostFromAPIURL("")).To(Equal("github.com"))
+			Expect(auth.HostFromAPIURL("https://api.github.com/")).To(Equal("github.com"))
+		})
+
+		It("should return the host of GitHub Enterprise Server", func() {
+			Expect(auth.HostFromAPIURL("https://ghe.example.com/api/v3/")).To(Equal("ghe.example.com"))
+			Expect(auth.HostFromAPIURL("https://api.ghe.example.com/")).To(Equal("ghe.example.com"))
+			Expect(auth.HostFromAPIURL("http://127.0.0.1:8080/")).To(Equal("127.0.0.1"))
+		})
+	})
+
+	Describe("Env", func() {
+		BeforeEach(func() {
+			for _, name := range []string{"GITHUB_TOKEN", "GH_TOKEN", "GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"} {
+				GinkgoT().Setenv(name, "")
+			}
+		})
+
+		It("should prefer GITHUB_TOKEN to GH_TOKEN", func() {
+			GinkgoT().Setenv("GH_TOKEN", "gh-token")
+			Expect(auth.Env{}.Token(ctx, auth.DefaultHost)).To(Equal("gh-token"))
+
+			GinkgoT().Setenv("GITHUB_TOKEN", "github-token")
+			Expect(auth.Env{}.Token(ctx, auth.DefaultHost)).To(Equal("github-token"))
+		})
+
+		It("should prefer enterprise tokens for other hosts", func() {
+			GinkgoT().Setenv("GITHUB_TOKEN", "github-token")
+			GinkgoT().Setenv("GH_ENTERPRISE_TOKEN", "enterprise-token")
+			Expect(auth.Env{}.Token(ctx, "ghe.example.com")).To(Equal("enterprise-token"))
+			Expect(auth.Env{}.Token(ctx, auth.DefaultHost)).To(Equal("github-token"))
+		})
+
+		It("should return ErrNotFound without a token", func() {
+			_, err := auth.Env{}.Token(ctx, auth.DefaultHost)
+			Expect(err).To(MatchError(auth.ErrNotFound))
+		})
+	})
+})
@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"context"
+	"os"
+)
+
+// Env reads GITHUB_TOKEN or GH_TOKEN. For hosts other than github.com,
+// GH_ENTERPRISE_TOKEN and GITHUB_ENTERPRISE_TOKEN come first, as with the
+// gh CLI.
+type Env struct{}
+
+func (Env) Name() string { return "environment" }
+
+func (Env) Token(ctx context.Context, host string) (string, error) {
+	names := []string{"GITHUB_TOKEN", "GH_TOKEN"}
+	if host != DefaultHost {
+		names = append([]string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}, names...)
+	}
+
+	for _, name := range names {
+		if token := os.Getenv(name); token != "" {
+			return token, nil
+		}
+	}
+	return "", ErrNotFound
+}
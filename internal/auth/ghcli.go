@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// GHCLI asks the gh CLI for its token with gh auth token, which finds it
+// wherever gh keeps it: the macOS Keychain, Windows Credential Manager, the
+// Secret Service or hosts.yml.
+type GHCLI struct {
+	// Command is the gh executable, by default gh on PATH.
+	Command string
+}
+
+func (GHCLI) Name() string { return "gh auth token" }
+
+func (g GHCLI) Token(ctx context.Context, host string) (string, error) {
+	command := g.Command
+	if command == "" {
+		command = "gh"
+	}
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return "", ErrNotFound
+	}
+
+	cmd := exec.CommandContext(ctx, path, "auth", "token", "--hostname", host)
+	cmd.Env = append(os.Environ(), "GH_PROMPT_DISABLED=1")
+	out, err := cmd.Output()
+	if err != nil {
+		// Not logged in to host
+		return "", ErrNotFound
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", ErrNotFound
+	}
+	return token, nil
+}
@@ -0,0 +1,45 @@
+package auth_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/auth"
+)
+
+// fakeGH prints a token for github.com only, like gh auth token logged in
+// to that host alone.
+const fakeGH = `#!/bin/sh
+[ "$1 $2 $3 $4" = "auth token --hostname github.com" ] || exit 1
+echo gho_cli
+`
+
+var _ = Describe("GHCLI", func() {
+	var gh auth.GHCLI
+	ctx := context.Background()
+
+	BeforeEach(func() {
+		command := filepath.Join(GinkgoT().TempDir(), "gh")
+		Expect(os.WriteFile(command, []byte(fakeGH), 0o755)).To(Succeed())
+		gh = auth.GHCLI{Command: command}
+	})
+
+	It("should return the token gh has for the host", func() {
+		Expect(gh.Token(ctx, "github.com")).To(Equal("gho_cli"))
+	})
+
+	It("should return ErrNotFound for hosts gh is not logged in to", func() {
+		_, err := gh.Token(ctx, "ghe.example.com")
+		Expect(err).To(MatchError(auth.ErrNotFound))
+	})
+
+	It("should return ErrNotFound without gh", func() {
+		GinkgoT().Setenv("PATH", GinkgoT().TempDir())
+		_, err := auth.GHCLI{}.Token(ctx, "github.com")
+		Expect(err).To(MatchError(auth.ErrNotFound))
+	})
+})
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GHConfig reads the tokens the gh CLI keeps in hosts.yml, without running
+// gh. Tokens gh stored in the keyring are found by GHCLI instead.
+type GHConfig struct {
+	// Dir is gh's configuration directory, by default $GH_CONFIG_DIR,
+	// $XDG_CONFIG_HOME/gh or ~/.config/gh.
+	Dir string
+}
+
+func (GHConfig) Name() string { return "gh hosts.yml" }
+
+// ghHost is a host's entry in hosts.yml. gh 2.40 and later keep a token per
+// account under users, and the active account's at the top.
+type ghHost struct {
+	OAuthToken string `yaml:"oauth_token"`
+	User       string `yaml:"user"`
+	Users      map[string]struct {
+		OAuthToken string `yaml:"oauth_token"`
+	} `yaml:"users"`
+}
+
+func (g GHConfig) Token(ctx context.Context, host string) (string, error) {
+	dir := g.Dir
+	if dir == "" {
+		dir = ghConfigDir()
+	}
+	if dir == "" {
+		return "", ErrNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "hosts.yml"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read gh hosts.yml: %w", err)
+	}
+
+	var hosts map[string]ghHost
+	if err := yaml.Unmarshal(data, &hosts); err != nil {
+		return "", fmt.Errorf("failed to parse gh hosts.yml: %w", err)
+	}
+
+	entry, ok := hosts[host]
+	if !ok {
+		return "", ErrNotFound
+	}
+	if entry.OAuthToken != "" {
+		return entry.OAuthToken, nil
+	}
+	if user, ok := entry.Users[entry.User]; ok && user.OAuthToken != "" {
+		return user.OAuthToken, nil
+	}
+	return "", ErrNotFound
+}
+
+func ghConfigDir() string {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gh")
+}
@@ -0,0 +1,62 @@
+package auth_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/auth"
+)
+
+var _ = Describe("GHConfig", func() {
+	var dir string
+	ctx := context.Background()
+
+	writeHosts := func(content string) {
+		Expect(os.WriteFile(filepath.Join(dir, "hosts.yml"), []byte(content), 0o600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+	})
+
+	It("should read the host's token", func() {
+		writeHosts("github.com:\n  user: octocat\n  oauth_token: gho_top\n  git_protocol: https\n")
+		Expect(auth.GHConfig{Dir: dir}.Token(ctx, "github.com")).To(Equal("gho_top"))
+	})
+
+	It("should read the active account's token", func() {
+		writeHosts("ghe.example.com:\n  user: hubot\n  users:\n    hubot:\n      oauth_token: gho_hubot\n    octocat:\n      oauth_token: gho_octocat\n")
+		Expect(auth.GHConfig{Dir: dir}.Token(ctx, "ghe.example.com")).To(Equal("gho_hubot"))
+	})
+
+	It("should return ErrNotFound for tokens gh keeps in the keyring", func() {
+		writeHosts("github.com:\n  user: octocat\n  users:\n    octocat: {}\n")
+		_, err := auth.GHConfig{Dir: dir}.Token(ctx, "github.com")
+		Expect(err).To(MatchError(auth.ErrNotFound))
+	})
+
+	It("should return ErrNotFound for other hosts or without hosts.yml", func() {
+		_, err := auth.GHConfig{Dir: dir}.Token(ctx, "github.com")
+		Expect(err).To(MatchError(auth.ErrNotFound))
+
+		writeHosts("github.com:\n  oauth_token: gho_top\n")
+		_, err = auth.GHConfig{Dir: dir}.Token(ctx, "ghe.example.com")
+		Expect(err).To(MatchError(auth.ErrNotFound))
+	})
+
+	It("should use GH_CONFIG_DIR", func() {
+		writeHosts("github.com:\n  oauth_token: gho_env\n")
+		GinkgoT().Setenv("GH_CONFIG_DIR", dir)
+		Expect(auth.GHConfig{}.Token(ctx, "github.com")).To(Equal("gho_env"))
+	})
+
+	It("should fail on a malformed hosts.yml", func() {
+		writeHosts("github.com: [\n")
+		_, err := auth.GHConfig{Dir: dir}.Token(ctx, "github.com")
+		Expect(err).To(MatchError(ContainSubstring("failed to parse gh hosts.yml")))
+	})
+})
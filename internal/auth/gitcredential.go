@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// GitCredential asks git's credential helpers for the password they keep
+// for https://<host>, never prompting for one.
+type GitCredential struct{}
+
+// credentialUsername is the username cpr stores tokens under. GitHub
+// accepts tokens with any username, and this one keeps cpr's entry apart
+// from the credentials git push uses.
+const credentialUsername = "x-access-token"
+
+func (GitCredential) Name() string { return "git credential helper" }
+
+func (GitCredential) Token(ctx context.Context, host string) (string, error) {
+	_, password, err := fillCredential(ctx, credentialInput(host, "", ""))
+	if err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// Store saves token for host with git's credential helpers.
+func (GitCredential) Store(ctx context.Context, host, token string) error {
+	if _, err := gitCredential(ctx, "approve", credentialInput(host, credentialUsername, token)); err != nil {
+		return fmt.Errorf("failed to store token with git credential helper: %w", err)
+	}
+	return nil
+}
+
+// Erase removes the token Store saved for host from git's credential
+// helpers, leaving credentials stored under other usernames alone.
+func (GitCredential) Erase(ctx context.Context, host string) error {
+	username, token, err := fillCredential(ctx, credentialInput(host, credentialUsername, ""))
+	if err != nil || username != credentialUsername {
+		// cpr stored nothing for host
+		return nil
+	}
+	if _, err := gitCredential(ctx, "reject", credentialInput(host, credentialUsername, token)); err != nil {
+		return fmt.Errorf("failed to erase token from git credential helper: %w", err)
+	}
+	return nil
+}
+
+// fillCredential asks the helpers for the credential input describes and
+// returns its username and password, or ErrNotFound.
+func fillCredential(ctx context.Context, input string) (username, password string, err error) {
+	out, err := gitCredential(ctx, "fill", input)
+	if err != nil {
+		// No helper, or none with a password for host
+		return "", "", ErrNotFound
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), "=")
+		switch key {
+		case "username":
+			username = value
+		case "password":
+			password = value
+		}
+	}
+	if password == "" {
+		return "", "", ErrNotFound
+	}
+	return username, password, nil
+}
+
+// credentialInput describes the credential for https://<host> in git's
+// credential format, with username and password when they are given.
+func credentialInput(host, username, password string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "protocol=https\nhost=%s\n", host)
+	if username != "" {
+		fmt.Fprintf(&b, "username=%s\n", username)
+	}
+	if password != "" {
+		fmt.Fprintf(&b, "password=%s\n", password)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func gitCredential(ctx context.Context, action, input string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", "-c", "credential.interactive=false", "credential", action)
+	cmd.Stdin = strings.NewReader(input)
+	// Helpers and git itself must fail rather than prompt
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git credential %s: %w: %s", action, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
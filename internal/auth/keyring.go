@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/zalando/go-keyring"
+)
+
+// Keyring keeps tokens in the OS keyring: the macOS Keychain, the Windows
+// Credential Manager, or the Secret Service that GNOME Keyring and KWallet
+// serve on the D-Bus session bus. Tokens the gh CLI stored in the keyring
+// are found as well.
+type Keyring struct{}
+
+// keyringService returns the service cpr stores host's token under. Like
+// gh, which uses "gh:<host>", it keeps the token under an empty user.
+func keyringService(host string) string {
+	return "cpr:" + host
+}
+
+func (Keyring) Name() string { return "keyring" }
+
+func (Keyring) Token(ctx context.Context, host string) (string, error) {
+	// cpr's own entry, then the one gh login stores for its active account
+	for _, service := range []string{keyringService(host), "gh:" + host} {
+		token, err := withKeyring(ctx, func() (string, error) {
+			return keyring.Get(service, "")
+		})
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if err != nil {
+			// Not stored, or no keyring to look in
+			continue
+		}
+		if token != "" {
+			return token, nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+// Set stores token for host in the keyring, replacing the one stored before.
+func (Keyring) Set(ctx context.Context, host, token string) error {
+	_, err := withKeyring(ctx, func() (string, error) {
+		return "", keyring.Set(keyringService(host), "", token)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store token in keyring: %w", err)
+	}
+	return nil
+}
+
+// Delete removes cpr's token for host from the keyring.
+func (Keyring) Delete(ctx context.Context, host string) error {
+	_, err := withKeyring(ctx, func() (string, error) {
+		return "", keyring.Delete(keyringService(host), "")
+	})
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("failed to delete token from keyring: %w", err)
+	}
+	return nil
+}
+
+// withKeyring runs call, which may wait on the keyring asking the user to
+// unlock it, until ctx is done.
+func withKeyring(ctx context.Context, call func() (string, error)) (string, error) {
+	type result struct {
+		value string
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := call()
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
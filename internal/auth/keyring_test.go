@@ -0,0 +1,57 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/zalando/go-keyring"
+
+	"github.com/fraser-isbester/cpr/internal/auth"
+)
+
+var _ = Describe("Keyring", func() {
+	ctx := context.Background()
+
+	BeforeEach(func() {
+		keyring.MockInit()
+	})
+
+	It("should set, find and delete a token", func() {
+		Expect(auth.Keyring{}.Set(ctx, "github.com", "ghp_keyring")).To(Succeed())
+		Expect(auth.Keyring{}.Set(ctx, "github.com", "ghp_replaced")).To(Succeed())
+		Expect(auth.Keyring{}.Token(ctx, "github.com")).To(Equal("ghp_replaced"))
+		_, err := auth.Keyring{}.Token(ctx, "ghe.example.com")
+		Expect(err).To(MatchError(auth.ErrNotFound))
+
+		Expect(auth.Keyring{}.Delete(ctx, "github.com")).To(Succeed())
+		_, err = auth.Keyring{}.Token(ctx, "github.com")
+		Expect(err).To(MatchError(auth.ErrNotFound))
+		Expect(auth.Keyring{}.Delete(ctx, "github.com")).To(Succeed())
+	})
+
+	It("should find the token gh stored", func() {
+		Expect(keyring.Set("gh:github.com", "", "gho_gh")).To(Succeed())
+		Expect(auth.Keyring{}.Token(ctx, "github.com")).To(Equal("gho_gh"))
+
+		Expect(auth.Keyring{}.Set(ctx, "github.com", "ghp_cpr")).To(Succeed())
+		Expect(auth.Keyring{}.Token(ctx, "github.com")).To(Equal("ghp_cpr"))
+	})
+
+	It("should return ErrNotFound without a keyring", func() {
+		keyring.MockInitWithError(errors.New("no D-Bus session bus"))
+
+		_, err := auth.Keyring{}.Token(ctx, "github.com")
+		Expect(err).To(MatchError(auth.ErrNotFound))
+		Expect(auth.Keyring{}.Set(ctx, "github.com", "ghp_token")).To(MatchError(ContainSubstring("no D-Bus session bus")))
+	})
+
+	It("should stop waiting for the keyring when the context is done", func() {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := auth.Keyring{}.Token(canceled, "github.com")
+		Expect(err).To(MatchError(context.Canceled))
+	})
+})
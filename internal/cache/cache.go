@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EnvDir overrides the cache directory.
+const EnvDir = "CPR_CACHE_DIR"
+
+// Store keeps JSON values in files under a directory. Keys are slash
+// separated paths such as "templates/owner/repo/sha".
+type Store struct {
+	dir string
+}
+
+// New returns a store in $CPR_CACHE_DIR, or cpr's directory in the user
+// cache directory.
+func New() (*Store, error) {
+	if dir := os.Getenv(EnvDir); dir != "" {
+		return &Store{dir: dir}, nil
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find cache directory: %w", err)
+	}
+	return &Store{dir: filepath.Join(dir, "cpr")}, nil
+}
+
+// NewAt returns a store in dir.
+func NewAt(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Get decodes the value stored under key into v and reports whether there
+// was one. Unreadable entries count as missing.
+func (s *Store) Get(key string, v any) bool {
+	if s == nil {
+		return false
+	}
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+// Put stores v under key.
+func (s *Store) Put(key string, v any) error {
+	if s == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	// Write then rename so concurrent runs never read a partial entry
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Delete removes the value stored under key.
+func (s *Store) Delete(key string) error {
+	if s == nil {
+		return nil
+	}
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete cache entry: %w", err)
+	}
+	return nil
+}
+
+// path maps a key onto a file, keeping every segment inside the store.
+func (s *Store) path(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segment = strings.ReplaceAll(segment, "..", "_")
+		if segment == "" || segment == "." {
+			segment = "_"
+		}
+		segments[i] = segment
+	}
+	return filepath.Join(s.dir, filepath.Join(segments...)+".json")
+}
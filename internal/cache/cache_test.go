@@ -0,0 +1,57 @@
+package cache_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/cache"
+)
+
+var _ = Describe("Store", func() {
+	var (
+		tmpDir string
+		store  *cache.Store
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "cpr-cache-*")
+		Expect(err).NotTo(HaveOccurred())
+		store = cache.NewAt(tmpDir)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	It("should round-trip values", func() {
+		Expect(store.Put("templates/octo/repo/abc", []string{"bugfix", "feature"})).To(Succeed())
+
+		var names []string
+		Expect(store.Get("templates/octo/repo/abc", &names)).To(BeTrue())
+		Expect(names).To(Equal([]string{"bugfix", "feature"}))
+	})
+
+	It("should report missing and deleted entries", func() {
+		var v string
+		Expect(store.Get("missing", &v)).To(BeFalse())
+
+		Expect(store.Put("key", "value")).To(Succeed())
+		Expect(store.Delete("key")).To(Succeed())
+		Expect(store.Get("key", &v)).To(BeFalse())
+	})
+
+	It("should keep keys inside the store", func() {
+		Expect(store.Put("../../escape", "value")).To(Succeed())
+		Expect(filepath.Join(tmpDir, "_", "_", "escape.json")).To(BeAnExistingFile())
+	})
+
+	It("should ignore a nil store", func() {
+		var s *cache.Store
+		Expect(s.Put("key", "value")).To(Succeed())
+		Expect(s.Get("key", new(string))).To(BeFalse())
+	})
+})
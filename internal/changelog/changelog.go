@@ -0,0 +1,182 @@
+package changelog
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+)
+
+// Header starts a new CHANGELOG.md.
+const Header = `# Changelog
+
+All notable changes to this project will be documented in this file.
+
+The format is based on [Keep a Changelog](https://keepachangelog.com/en/1.1.0/).
+`
+
+var prRefPattern = regexp.MustCompile(`\s*\(#(\d+)\)$`)
+
+// sections maps commit types onto Keep a Changelog categories, in order.
+var sections = []struct {
+	title string
+	types []commit.CommitType
+	all   bool
+}{
+	{title: "Added", types: []commit.CommitType{commit.TypeFeat}},
+	{title: "Fixed", types: []commit.CommitType{commit.TypeFix}},
+	{title: "Changed", types: []commit.CommitType{commit.TypePerf, commit.TypeRefactor}},
+	{title: "Other", all: true, types: []commit.CommitType{
+		commit.TypeDocs, commit.TypeStyle, commit.TypeTest, commit.TypeBuild, commit.TypeCI, commit.TypeChore,
+	}},
+}
+
+// Entry is one change in the changelog.
+type Entry struct {
+	*commit.Conventional
+
+	// Ref identifies the change, e.g. "#123" or an abbreviated commit hash.
+	Ref string
+}
+
+// NewEntry parses a Conventional commit message or PR title. A trailing
+// "(#123)" as added by squash merges becomes the entry's ref; otherwise ref
+// is used, shortened if it is a commit hash.
+func NewEntry(message, ref string) (Entry, error) {
+	c, err := commit.ParseConventional(message)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if m := prRefPattern.FindStringSubmatch(c.Subject); m != nil {
+		c.Subject = prRefPattern.ReplaceAllString(c.Subject, "")
+		ref = "#" + m[1]
+	} else if len(ref) > 7 && !strings.HasPrefix(ref, "#") {
+		ref = ref[:7]
+	}
+
+	return Entry{Conventional: c, Ref: ref}, nil
+}
+
+// Changelog is the set of changes in one release.
+type Changelog struct {
+	// Version is the release name; "Unreleased" if empty.
+	Version string
+	// Date is the release date; omitted from the heading if zero.
+	Date    time.Time
+	Entries []Entry
+
+	// IncludeAll also lists docs, test, build, CI and chore changes.
+	IncludeAll bool
+}
+
+// Heading returns the Markdown heading of the release.
+func (c *Changelog) Heading() string {
+	version := c.Version
+	if version == "" {
+		version = "Unreleased"
+	}
+
+	if c.Date.IsZero() {
+		return fmt.Sprintf("## [%s]", version)
+	}
+	return fmt.Sprintf("## [%s] - %s", version, c.Date.Format("2006-01-02"))
+}
+
+// Render formats the release as a Keep a Changelog section. Breaking changes
+// are listed first, and entries in each category are grouped by scope.
+func (c *Changelog) Render() string {
+	var out strings.Builder
+	out.WriteString(c.Heading() + "\n")
+
+	var breaking []Entry
+	for _, e := range c.Entries {
+		if e.Breaking {
+			breaking = append(breaking, e)
+		}
+	}
+	if len(breaking) > 0 {
+		out.WriteString("\n### ⚠ BREAKING CHANGES\n\n")
+		for _, e := range sortByScope(breaking) {
+			note := e.BreakingNote
+			if note == "" {
+				note = e.Subject
+			}
+			out.WriteString(formatLine(e, note))
+		}
+	}
+
+	for _, section := range sections {
+		if section.all && !c.IncludeAll {
+			continue
+		}
+
+		var entries []Entry
+		for _, e := range c.Entries {
+			for _, t := range section.types {
+				if e.Type == t {
+					entries = append(entries, e)
+				}
+			}
+		}
+		if len(entries) == 0 {
+			continue
+		}
+
+		out.WriteString(fmt.Sprintf("\n### %s\n\n", section.title))
+		for _, e := range sortByScope(entries) {
+			out.WriteString(formatLine(e, e.Subject))
+		}
+	}
+
+	return out.String()
+}
+
+// Prepend adds a rendered release section to the top of an existing
+// changelog, below its introduction. A section with the same heading, such
+// as a previous Unreleased one, is replaced.
+func Prepend(existing, section string) string {
+	if strings.TrimSpace(existing) == "" {
+		return Header + "\n" + section
+	}
+
+	heading, _, _ := strings.Cut(section, "\n")
+	if idx := strings.Index(existing, heading+"\n"); idx >= 0 {
+		end := len(existing)
+		if next := strings.Index(existing[idx+len(heading):], "\n## "); next >= 0 {
+			end = idx + len(heading) + next + 1
+		}
+		return existing[:idx] + section + "\n" + strings.TrimLeft(existing[end:], "\n")
+	}
+
+	if idx := strings.Index(existing, "\n## "); idx >= 0 {
+		return existing[:idx+1] + section + "\n" + existing[idx+1:]
+	}
+
+	return strings.TrimRight(existing, "\n") + "\n\n" + section
+}
+
+func formatLine(e Entry, text string) string {
+	line := "- "
+	if e.Scope != "" {
+		line += fmt.Sprintf("**%s:** ", e.Scope)
+	}
+	line += text
+	if e.Ref != "" {
+		line += fmt.Sprintf(" (%s)", e.Ref)
+	}
+	return line + "\n"
+}
+
+// sortByScope groups entries by scope, unscoped first, keeping the original
+// order within a scope.
+func sortByScope(entries []Entry) []Entry {
+	sorted := append([]Entry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Scope < sorted[j].Scope
+	})
+	return sorted
+}
@@ -0,0 +1,13 @@
+package changelog_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestChangelog(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Changelog Suite")
+}
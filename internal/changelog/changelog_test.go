@@ -0,0 +1,95 @@
+package changelog_test
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/changelog"
+)
+
+var _ = Describe("Changelog", func() {
+	entry := func(message, ref string) changelog.Entry {
+		e, err := changelog.NewEntry(message, ref)
+		Expect(err).NotTo(HaveOccurred())
+		return e
+	}
+
+	Describe("NewEntry", func() {
+		It("should take the PR number from squash merge subjects", func() {
+			e := entry("fix(api): handle timeouts (#42)", "0123456789abcdef")
+			Expect(e.Subject).To(Equal("handle timeouts"))
+			Expect(e.Ref).To(Equal("#42"))
+		})
+
+		It("should abbreviate commit hashes", func() {
+			Expect(entry("fix: typo", "0123456789abcdef").Ref).To(Equal("0123456"))
+		})
+
+		It("should reject non-conventional messages", func() {
+			_, err := changelog.NewEntry("Merge pull request #1 from a/b", "abc")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Render", func() {
+		It("should group entries into Keep a Changelog categories", func() {
+			c := &changelog.Changelog{
+				Version: "1.2.0",
+				Date:    time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+				Entries: []changelog.Entry{
+					entry("feat(cli): add status command", "#1"),
+					entry("fix(api): handle timeouts", "#2"),
+					entry("feat(api): add retries", "#3"),
+					entry("docs: update readme", "#4"),
+				},
+			}
+
+			out := c.Render()
+			Expect(out).To(HavePrefix("## [1.2.0] - 2026-10-18\n"))
+			Expect(out).To(ContainSubstring("### Added\n\n- **api:** add retries (#3)\n- **cli:** add status command (#1)\n"))
+			Expect(out).To(ContainSubstring("### Fixed\n\n- **api:** handle timeouts (#2)\n"))
+			Expect(out).NotTo(ContainSubstring("update readme"))
+		})
+
+		It("should include other types when asked", func() {
+			c := &changelog.Changelog{IncludeAll: true, Entries: []changelog.Entry{entry("docs: update readme", "#4")}}
+			Expect(c.Render()).To(ContainSubstring("## [Unreleased]\n\n### Other\n\n- update readme (#4)"))
+		})
+
+		It("should highlight breaking changes first", func() {
+			c := &changelog.Changelog{Entries: []changelog.Entry{
+				entry("fix: small fix", "#1"),
+				entry("feat(auth)!: drop basic auth\n\nBREAKING CHANGE: use tokens instead", "#2"),
+			}}
+
+			out := c.Render()
+			Expect(out).To(ContainSubstring("### ⚠ BREAKING CHANGES\n\n- **auth:** use tokens instead (#2)"))
+			Expect(strings.Index(out, "BREAKING")).To(BeNumerically("<", strings.Index(out, "### Added")))
+		})
+	})
+
+	Describe("Prepend", func() {
+		It("should start a new changelog", func() {
+			out := changelog.Prepend("", "## [1.0.0]\n")
+			Expect(out).To(HavePrefix(changelog.Header))
+			Expect(out).To(HaveSuffix("## [1.0.0]\n"))
+		})
+
+		It("should insert above the latest release", func() {
+			existing := changelog.Header + "\n## [1.0.0]\n\n### Added\n\n- first\n"
+			out := changelog.Prepend(existing, "## [1.1.0]\n\n### Fixed\n\n- second\n")
+			Expect(strings.Index(out, "## [1.1.0]")).To(BeNumerically("<", strings.Index(out, "## [1.0.0]")))
+			Expect(out).To(HavePrefix(changelog.Header))
+		})
+
+		It("should replace a section with the same heading", func() {
+			existing := changelog.Header + "\n## [Unreleased]\n\n- old\n\n## [1.0.0]\n\n- first\n"
+			out := changelog.Prepend(existing, "## [Unreleased]\n\n- new\n")
+			Expect(out).NotTo(ContainSubstring("- old"))
+			Expect(out).To(ContainSubstring("## [Unreleased]\n\n- new\n\n## [1.0.0]"))
+		})
+	})
+})
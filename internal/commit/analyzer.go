@@ -2,9 +2,12 @@ package commit
 
 import (
 	"fmt"
+	"log/slog"
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"github.com/fraser-isbester/cpr/internal/logging"
 )
 
 type CommitType string
@@ -25,24 +28,34 @@ const (
 type Analyzer struct {
 	diff         string
 	changedFiles []string
+
+	// noise classifies the changed files; sourceDiff and sourceFiles leave
+	// out the noise, unless the change is only noise.
+	noise       *NoiseFilter
+	classes     map[string]FileClass
+	sourceDiff  string
+	sourceFiles []string
+	onlyNoise   bool
+
+	// commits are the branch's commits, used as classification evidence.
+	commits []CommitInfo
+
+	logger *slog.Logger
 }
 
 func NewAnalyzer(diff string, changedFiles []string) *Analyzer {
-	return &Analyzer{
+	a := &Analyzer{
 		diff:         diff,
 		changedFiles: changedFiles,
+		logger:       logging.Discard(),
 	}
+	a.SetNoiseFilter(DefaultNoiseFilter())
+	return a
 }
 
 func (a *Analyzer) GenerateTitle() string {
-	commitType := a.detectCommitType()
-	scope := a.detectScope()
-	description := a.generateDescription()
-
-	if scope != "" {
-		return fmt.Sprintf("%s(%s): %s", commitType, scope, description)
-	}
-	return fmt.Sprintf("%s: %s", commitType, description)
+	title, _ := a.GenerateTitleWith(AngularFormat{}, "")
+	return title
 }
 
 func (a *Analyzer) GenerateSummary() string {
@@ -55,101 +68,38 @@ func (a *Analyzer) GenerateSummary() string {
 		summary.WriteString(fmt.Sprintf("- %s\n", change))
 	}
 
-	if len(a.changedFiles) > 0 {
+	if len(a.sourceFiles) > 0 {
 		summary.WriteString("\n## Changed Files\n\n")
-		for _, file := range a.changedFiles {
+		for _, file := range a.sourceFiles {
 			summary.WriteString(fmt.Sprintf("- `%s`\n", file))
 		}
+		if noise := a.noiseSummary(); noise != "" {
+			summary.WriteString(fmt.Sprintf("- %s\n", noise))
+		}
 	}
 
 	return summary.String()
 }
 
-func (a *Analyzer) detectCommitType() CommitType {
-	lowerDiff := strings.ToLower(a.diff)
-
-	testFilePattern := regexp.MustCompile(`(?i)(_test\.go|\.test\.|spec\.|test/)`)
-	hasTestFiles := false
-	for _, file := range a.changedFiles {
-		if testFilePattern.MatchString(file) {
-			hasTestFiles = true
-			break
-		}
-	}
-	if hasTestFiles && !a.hasNonTestChanges() {
-		return TypeTest
-	}
-
-	if a.hasFilePattern(`(?i)(readme|\.md$|docs/)`) {
-		return TypeDocs
-	}
-
-	if a.hasFilePattern(`(?i)(makefile|dockerfile|\.yml$|\.yaml$|go\.mod|go\.sum|package\.json)`) {
-		return TypeBuild
-	}
-
-	if a.hasFilePattern(`(?i)(\.github/|\.circleci/|\.travis|jenkins)`) {
-		return TypeCI
-	}
-
-	bugPatterns := []string{
-		`fix\s*\(`,
-		`bug\s*fix`,
-		`error\s*handling`,
-		`nil\s*pointer`,
-		`panic`,
-		`segfault`,
-		`crash`,
-		`exception`,
-	}
-	for _, pattern := range bugPatterns {
-		if match, _ := regexp.MatchString(pattern, lowerDiff); match {
-			return TypeFix
-		}
-	}
-
-	perfPatterns := []string{
-		`performance`,
-		`optimize`,
-		`speed\s*up`,
-		`reduce\s*memory`,
-		`cache`,
-	}
-	for _, pattern := range perfPatterns {
-		if match, _ := regexp.MatchString(pattern, lowerDiff); match {
-			return TypePerf
-		}
-	}
-
-	refactorPatterns := []string{
-		`refactor`,
-		`rename`,
-		`move\s*to`,
-		`extract`,
-		`simplify`,
-		`clean\s*up`,
-	}
-	for _, pattern := range refactorPatterns {
-		if match, _ := regexp.MatchString(pattern, lowerDiff); match {
-			return TypeRefactor
-		}
-	}
-
-	if strings.Contains(lowerDiff, "+func") || strings.Contains(lowerDiff, "+type") ||
-		strings.Contains(lowerDiff, "+struct") || strings.Contains(lowerDiff, "+interface") {
-		return TypeFeat
-	}
+// SetLogger logs the analyzer's decisions, such as the files it leaves out
+// as noise and the commit type it detects, to logger.
+func (a *Analyzer) SetLogger(logger *slog.Logger) {
+	a.logger = logging.OrDiscard(logger)
+}
 
-	return TypeFeat
+func (a *Analyzer) detectCommitType() CommitType {
+	best := a.Classify()[0]
+	a.logger.Debug("detected commit type", "type", best.Type, "confidence", fmt.Sprintf("%.0f%%", best.Confidence*100))
+	return best.Type
 }
 
 func (a *Analyzer) detectScope() string {
-	if len(a.changedFiles) == 0 {
+	if len(a.sourceFiles) == 0 {
 		return ""
 	}
 
 	commonPrefixes := make(map[string]int)
-	for _, file := range a.changedFiles {
+	for _, file := range a.sourceFiles {
 		dir := filepath.Dir(file)
 		parts := strings.Split(dir, "/")
 
@@ -216,7 +166,7 @@ func (a *Analyzer) analyzePrimaryChanges() []string {
 	funcPattern := regexp.MustCompile(`^\+func\s+(\w+)`)
 	typePattern := regexp.MustCompile(`^\+type\s+(\w+)`)
 
-	lines := strings.Split(a.diff, "\n")
+	lines := strings.Split(a.sourceDiff, "\n")
 	for _, line := range lines {
 		if matches := funcPattern.FindStringSubmatch(line); len(matches) > 1 {
 			changes = append(changes, fmt.Sprintf("add %s function", matches[1]))
@@ -252,20 +202,10 @@ func (a *Analyzer) analyzeChanges() []string {
 
 func (a *Analyzer) hasFilePattern(pattern string) bool {
 	re := regexp.MustCompile(pattern)
-	for _, file := range a.changedFiles {
+	for _, file := range a.sourceFiles {
 		if re.MatchString(file) {
 			return true
 		}
 	}
 	return false
 }
-
-func (a *Analyzer) hasNonTestChanges() bool {
-	testPattern := regexp.MustCompile(`(?i)(_test\.go|\.test\.|spec\.|test/)`)
-	for _, file := range a.changedFiles {
-		if !testPattern.MatchString(file) {
-			return true
-		}
-	}
-	return false
-}
@@ -0,0 +1,228 @@
+package commit
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	diffHeaderPattern = regexp.MustCompile(`^diff --git a/(.+) b/(.+)$`)
+	symbolPattern     = regexp.MustCompile(`^([+-])(func|type)\s+(?:\([^)]*\)\s*)?(\w+)`)
+)
+
+// FileChange is one file in the change set.
+type FileChange struct {
+	Path string
+	// Status is "added", "deleted", "renamed" or "modified".
+	Status    string
+	Additions int
+	Deletions int
+	// Class marks generated, vendored, lock and other noise files; it is
+	// empty for files written by people.
+	Class FileClass
+}
+
+// Symbol is a Go function, method or type touched by the diff.
+type Symbol struct {
+	// Kind is "func" or "type".
+	Kind string
+	Name string
+	// Change is "added", "removed" or "changed".
+	Change string
+}
+
+// CommitInfo is a commit on the branch.
+type CommitInfo struct {
+	Hash    string
+	Subject string
+	Body    string
+}
+
+// Stats totals the change set.
+type Stats struct {
+	Files     int
+	Additions int
+	Deletions int
+}
+
+// BodyData is the data model available to PR body templates.
+type BodyData struct {
+	// Title is the PR title.
+	Title string
+	// Type, Scope and Description are the detected title parts.
+	Type        CommitType
+	Scope       string
+	Description string
+
+	// Breaking is set when the title or any commit marks a breaking change;
+	// BreakingChanges holds their descriptions.
+	Breaking        bool
+	BreakingChanges []string
+
+	// Files is the change set and Stats its totals.
+	Files []FileChange
+	Stats Stats
+
+	// Symbols are the functions, methods and types touched by the diff.
+	Symbols []Symbol
+
+	// Commits are the branch's commits, oldest first.
+	Commits []CommitInfo
+
+	// Issues are ticket keys found in the branch name and commit messages.
+	Issues []string
+
+	// Branch and Base are the head and base branches of the PR.
+	Branch string
+	Base   string
+
+	// Changes are the summary bullet points cpr generates by default, and
+	// Summary is the complete default (or --body) summary.
+	Changes []string
+	Summary string
+}
+
+// BodyData collects everything body templates can use. Commits are expected
+// oldest first.
+func (a *Analyzer) BodyData(title, branch, base string, commits []CommitInfo) BodyData {
+	data := BodyData{
+		Title:       title,
+		Type:        a.detectCommitType(),
+		Scope:       a.detectScope(),
+		Description: a.generateDescription(),
+		Files:       a.FileChanges(),
+		Symbols:     a.Symbols(),
+		Commits:     commits,
+		Branch:      branch,
+		Base:        base,
+		Changes:     a.analyzeChanges(),
+		Summary:     a.GenerateSummary(),
+	}
+
+	for _, f := range data.Files {
+		data.Stats.Additions += f.Additions
+		data.Stats.Deletions += f.Deletions
+	}
+	data.Stats.Files = len(data.Files)
+
+	if c, err := ParseConventional(title); err == nil && c.Breaking {
+		data.Breaking = true
+		data.BreakingChanges = append(data.BreakingChanges, c.Subject)
+	}
+
+	issueText := []string{branch}
+	for _, commit := range commits {
+		issueText = append(issueText, commit.Subject, commit.Body)
+
+		c, err := ParseConventional(commit.Subject + "\n\n" + commit.Body)
+		if err != nil || !c.Breaking {
+			continue
+		}
+		data.Breaking = true
+		note := c.BreakingNote
+		if note == "" {
+			note = c.Subject
+		}
+		data.BreakingChanges = append(data.BreakingChanges, note)
+	}
+	data.Issues = IssueKeys(strings.Join(issueText, "\n"))
+
+	return data
+}
+
+// FileChanges parses the diff into per-file changes. Files listed as changed
+// but absent from the diff, such as binaries, are reported as modified.
+func (a *Analyzer) FileChanges() []FileChange {
+	byPath := make(map[string]*FileChange)
+	var order []string
+
+	var current *FileChange
+	for _, line := range strings.Split(a.diff, "\n") {
+		if m := diffHeaderPattern.FindStringSubmatch(line); m != nil {
+			current = &FileChange{Path: m[2], Status: "modified"}
+			if m[1] != m[2] {
+				current.Status = "renamed"
+			}
+			byPath[current.Path] = current
+			order = append(order, current.Path)
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "new file mode"):
+			current.Status = "added"
+		case strings.HasPrefix(line, "deleted file mode"):
+			current.Status = "deleted"
+		case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+		case strings.HasPrefix(line, "+"):
+			current.Additions++
+		case strings.HasPrefix(line, "-"):
+			current.Deletions++
+		}
+	}
+
+	for _, file := range a.changedFiles {
+		if _, ok := byPath[file]; !ok {
+			byPath[file] = &FileChange{Path: file, Status: "modified"}
+			order = append(order, file)
+		}
+	}
+
+	changes := make([]FileChange, 0, len(order))
+	for _, path := range order {
+		change := *byPath[path]
+		change.Class = a.classes[path]
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// Symbols lists the Go functions, methods and types the diff adds, removes
+// or changes outside noise files, sorted by name.
+func (a *Analyzer) Symbols() []Symbol {
+	added := make(map[Symbol]bool)
+	removed := make(map[Symbol]bool)
+
+	for _, line := range strings.Split(a.sourceDiff, "\n") {
+		if strings.HasPrefix(line, "+++ ") || strings.HasPrefix(line, "--- ") {
+			continue
+		}
+		m := symbolPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key := Symbol{Kind: m[2], Name: m[3]}
+		if m[1] == "+" {
+			added[key] = true
+		} else {
+			removed[key] = true
+		}
+	}
+
+	var symbols []Symbol
+	for key := range added {
+		key.Change = "added"
+		if removed[Symbol{Kind: key.Kind, Name: key.Name}] {
+			key.Change = "changed"
+		}
+		symbols = append(symbols, key)
+	}
+	for key := range removed {
+		if !added[key] {
+			key.Change = "removed"
+			symbols = append(symbols, key)
+		}
+	}
+
+	sort.Slice(symbols, func(i, j int) bool {
+		if symbols[i].Name != symbols[j].Name {
+			return symbols[i].Name < symbols[j].Name
+		}
+		return symbols[i].Kind < symbols[j].Kind
+	})
+	return symbols
+}
@@ -0,0 +1,66 @@
+package commit_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+)
+
+var _ = Describe("BodyData", func() {
+	diff := `diff --git a/internal/auth/handler.go b/internal/auth/handler.go
+new file mode 100644
+index 0000000..def456
+--- /dev/null
++++ b/internal/auth/handler.go
+@@ -0,0 +1,4 @@
++package auth
++
++func NewHandler() *Handler {
++}
+diff --git a/internal/auth/legacy.go b/internal/auth/legacy.go
+index abc123..def456 100644
+--- a/internal/auth/legacy.go
++++ b/internal/auth/legacy.go
+@@ -1,3 +1,2 @@
+ package auth
+-func (h *Handler) Login() {}
+-type Session struct{}
++type Session struct{ ID string }
+`
+	files := []string{"internal/auth/handler.go", "internal/auth/legacy.go", "assets/logo.png"}
+
+	It("should parse file changes and stats", func() {
+		analyzer := commit.NewAnalyzer(diff, files)
+		Expect(analyzer.FileChanges()).To(Equal([]commit.FileChange{
+			{Path: "internal/auth/handler.go", Status: "added", Additions: 4},
+			{Path: "internal/auth/legacy.go", Status: "modified", Additions: 1, Deletions: 2},
+			{Path: "assets/logo.png", Status: "modified"},
+		}))
+	})
+
+	It("should list added, removed and changed symbols", func() {
+		analyzer := commit.NewAnalyzer(diff, files)
+		Expect(analyzer.Symbols()).To(Equal([]commit.Symbol{
+			{Kind: "func", Name: "Login", Change: "removed"},
+			{Kind: "func", Name: "NewHandler", Change: "added"},
+			{Kind: "type", Name: "Session", Change: "changed"},
+		}))
+	})
+
+	It("should collect commits, issues and breaking changes", func() {
+		analyzer := commit.NewAnalyzer(diff, files)
+		commits := []commit.CommitInfo{
+			{Hash: "a1", Subject: "feat(auth): add handler", Body: "Refs OPS-7"},
+			{Hash: "b2", Subject: "refactor(auth)!: drop Login", Body: "BREAKING CHANGE: use NewHandler instead"},
+		}
+
+		data := analyzer.BodyData("feat(auth): add handler", "PROJ-1-auth", "main", commits)
+		Expect(data.Stats).To(Equal(commit.Stats{Files: 3, Additions: 5, Deletions: 2}))
+		Expect(data.Scope).To(Equal("auth"))
+		Expect(data.Issues).To(Equal([]string{"PROJ-1", "OPS-7"}))
+		Expect(data.Breaking).To(BeTrue())
+		Expect(data.BreakingChanges).To(Equal([]string{"use NewHandler instead"}))
+		Expect(data.Summary).To(ContainSubstring("## Summary"))
+	})
+})
@@ -0,0 +1,332 @@
+package commit
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Evidence weights. File categories share weightFiles by their part of the
+// changed lines, commit messages share weightCommits, and diff keywords add
+// weightAdded per added line and weightRemoved per removed line, up to
+// maxKeyword per pattern.
+const (
+	weightFiles      = 3.0
+	weightSourceBase = 0.5
+	weightCommits    = 4.0
+	weightAdded      = 0.75
+	weightRemoved    = 0.25
+	weightWeak       = 0.2
+	maxKeyword       = 1.5
+	weightSymbol     = 0.5
+	maxSymbols       = 2.0
+	weightRefactor   = 0.5
+)
+
+// fileCategories assigns changed files to a commit type, first match wins.
+// Files matching none are source code.
+var fileCategories = []struct {
+	commitType CommitType
+	pattern    *regexp.Regexp
+}{
+	{TypeCI, regexp.MustCompile(`(?i)(^\.github/|\.circleci/|\.travis|jenkins|\.gitlab-ci)`)},
+	{TypeTest, regexp.MustCompile(`(?i)(_test\.go|\.test\.|spec\.|test/)`)},
+	{TypeDocs, regexp.MustCompile(`(?i)(readme|\.md$|docs/)`)},
+	{TypeBuild, regexp.MustCompile(`(?i)(makefile|dockerfile|\.yml$|\.yaml$|go\.mod|go\.sum|package\.json)`)},
+}
+
+// keyword is a pattern in source lines hinting at a commit type. Weak
+// keywords, such as "cache", are common outside their type and count less.
+type keyword struct {
+	commitType CommitType
+	name       string
+	pattern    *regexp.Regexp
+	weak       bool
+}
+
+var keywords = []keyword{
+	{TypeFix, "fix", regexp.MustCompile(`(?i)\bfix(e[sd])?\b|bug\s*fix`), false},
+	{TypeFix, "nil pointer", regexp.MustCompile(`(?i)nil\s*pointer`), false},
+	{TypeFix, "panic", regexp.MustCompile(`(?i)\bpanic`), false},
+	{TypeFix, "crash", regexp.MustCompile(`(?i)\bcrash|segfault`), false},
+	{TypeFix, "error handling", regexp.MustCompile(`(?i)error\s*handling`), false},
+	{TypeFix, "exception", regexp.MustCompile(`(?i)exception`), true},
+	{TypePerf, "optimize", regexp.MustCompile(`(?i)optimi[sz]`), false},
+	{TypePerf, "performance", regexp.MustCompile(`(?i)performance|speed\s*up|faster`), false},
+	{TypePerf, "allocations", regexp.MustCompile(`(?i)reduce\s*(memory|allocations?)|prealloc|sync\.Pool`), false},
+	{TypePerf, "cache", regexp.MustCompile(`(?i)cache`), true},
+	{TypeRefactor, "refactor", regexp.MustCompile(`(?i)refactor`), false},
+	{TypeRefactor, "rename", regexp.MustCompile(`(?i)\brenam(e|ed|ing)\b`), false},
+	{TypeRefactor, "extract", regexp.MustCompile(`(?i)\bextract(ed|s)?\b`), false},
+	{TypeRefactor, "simplify", regexp.MustCompile(`(?i)simplif`), false},
+	{TypeRefactor, "clean up", regexp.MustCompile(`(?i)clean\s*up`), false},
+	{TypeRefactor, "move to", regexp.MustCompile(`(?i)move[sd]?\s+to\b`), true},
+}
+
+// messageVerbs maps the first word of non-conventional commit messages to
+// a commit type.
+var messageVerbs = map[string]CommitType{
+	"fix": TypeFix, "fixes": TypeFix, "fixed": TypeFix, "resolve": TypeFix, "resolves": TypeFix,
+	"add": TypeFeat, "adds": TypeFeat, "added": TypeFeat, "implement": TypeFeat, "introduce": TypeFeat, "support": TypeFeat,
+	"refactor": TypeRefactor, "rename": TypeRefactor, "extract": TypeRefactor, "simplify": TypeRefactor, "move": TypeRefactor,
+	"document": TypeDocs, "docs": TypeDocs, "doc": TypeDocs,
+	"test": TypeTest, "tests": TypeTest,
+	"optimize": TypePerf, "optimise": TypePerf, "speed": TypePerf,
+	"bump": TypeBuild, "upgrade": TypeBuild,
+	"format": TypeStyle, "lint": TypeStyle,
+}
+
+var addedSymbolPattern = regexp.MustCompile(`^\+(func|type)\s+(?:\([^)]*\)\s*)?(\w+)`)
+
+// Evidence is one reason a commit type scored.
+type Evidence struct {
+	Weight float64
+	Reason string
+}
+
+// TypeScore is a commit type's score, its share of all scores, and the
+// evidence behind it.
+type TypeScore struct {
+	Type       CommitType
+	Score      float64
+	Confidence float64
+	Evidence   []Evidence
+}
+
+// SetCommits gives the classifier the branch's commits, whose messages
+// count as evidence.
+func (a *Analyzer) SetCommits(commits []CommitInfo) {
+	a.commits = commits
+}
+
+// Classify scores every commit type the change shows evidence for and
+// returns them best first. Evidence comes from the share of changed lines
+// per file category, keywords in added and removed source lines, added
+// functions and types, and commit messages. Without any evidence the change
+// is a feat.
+func (a *Analyzer) Classify() []TypeScore {
+	scores := make(map[CommitType]*TypeScore)
+	add := func(t CommitType, weight float64, format string, args ...any) {
+		if weight <= 0 {
+			return
+		}
+		s, ok := scores[t]
+		if !ok {
+			s = &TypeScore{Type: t}
+			scores[t] = s
+		}
+		s.Score += weight
+		s.Evidence = append(s.Evidence, Evidence{Weight: weight, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	a.classifyFiles(add)
+	a.classifyLines(add)
+	a.classifyCommits(add)
+
+	if len(scores) == 0 {
+		add(TypeFeat, 1, "no evidence for any type")
+	}
+
+	ranked := make([]TypeScore, 0, len(scores))
+	total := 0.0
+	for _, s := range scores {
+		total += s.Score
+	}
+	for _, s := range scores {
+		s.Confidence = s.Score / total
+		sort.SliceStable(s.Evidence, func(i, j int) bool { return s.Evidence[i].Weight > s.Evidence[j].Weight })
+		ranked = append(ranked, *s)
+	}
+
+	// Break ties in the order types are listed in DefaultTypes
+	sort.Slice(ranked, func(i, j int) bool {
+		if math.Abs(ranked[i].Score-ranked[j].Score) > 1e-9 {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return slices.Index(DefaultTypes, ranked[i].Type) < slices.Index(DefaultTypes, ranked[j].Type)
+	})
+	return ranked
+}
+
+// fileCategory returns the commit type a file's path suggests, or "" for
+// source code.
+func fileCategory(file string) CommitType {
+	for _, c := range fileCategories {
+		if c.pattern.MatchString(file) {
+			return c.commitType
+		}
+	}
+	return ""
+}
+
+// classifyFiles weighs file categories by their share of changed lines.
+// Tests next to source changes usually accompany them, so they count half.
+func (a *Analyzer) classifyFiles(add func(CommitType, float64, string, ...any)) {
+	lines := make(map[string]int)
+	for _, f := range a.FileChanges() {
+		lines[f.Path] = f.Additions + f.Deletions
+	}
+
+	byCategory := make(map[CommitType]int)
+	examples := make(map[CommitType][]string)
+	total := 0
+	for _, file := range a.sourceFiles {
+		n := max(lines[file], 1)
+		category := fileCategory(file)
+		byCategory[category] += n
+		examples[category] = append(examples[category], file)
+		total += n
+	}
+	if total == 0 {
+		return
+	}
+
+	for _, category := range append([]CommitType{""}, DefaultTypes...) {
+		n, ok := byCategory[category]
+		if !ok {
+			continue
+		}
+		share := float64(n) / float64(total)
+		reason := fmt.Sprintf("%s: %.0f%% of changed lines (%s)", categoryName(category), share*100, sampleFiles(examples[category]))
+
+		switch category {
+		case "":
+			add(TypeFeat, share*weightSourceBase, "%s", reason)
+		case TypeTest:
+			weight := share * weightFiles
+			if byCategory[""] > 0 {
+				weight /= 2
+			}
+			add(TypeTest, weight, "%s", reason)
+		default:
+			add(category, share*weightFiles, "%s", reason)
+		}
+	}
+}
+
+// classifyLines looks at the added and removed lines of source code.
+func (a *Analyzer) classifyLines(add func(CommitType, float64, string, ...any)) {
+	var added, removed []string
+	symbols := 0
+
+	current := ""
+	for _, line := range strings.Split(a.sourceDiff, "\n") {
+		if m := diffHeaderPattern.FindStringSubmatch(line); m != nil {
+			current = m[2]
+			continue
+		}
+		if fileCategory(current) != "" || strings.HasPrefix(line, "+++ ") || strings.HasPrefix(line, "--- ") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "+"):
+			added = append(added, line[1:])
+			if addedSymbolPattern.MatchString(line) {
+				symbols++
+			}
+		case strings.HasPrefix(line, "-"):
+			removed = append(removed, line[1:])
+		}
+	}
+
+	for _, k := range keywords {
+		nAdded, nRemoved := countMatches(k.pattern, added), countMatches(k.pattern, removed)
+		if nAdded+nRemoved == 0 {
+			continue
+		}
+		weight := weightAdded*float64(nAdded) + weightRemoved*float64(nRemoved)
+		if k.weak {
+			weight = weightWeak * float64(nAdded+nRemoved)
+		}
+		add(k.commitType, min(weight, maxKeyword), "%q in %d added and %d removed lines", k.name, nAdded, nRemoved)
+	}
+
+	if symbols > 0 {
+		add(TypeFeat, min(weightSymbol*float64(symbols), maxSymbols), "adds %d functions or types", symbols)
+	}
+
+	if len(added) > 0 && whitespaceOnly(added, removed) {
+		add(TypeStyle, weightFiles, "only whitespace changed in %d lines", len(added))
+	} else if symbols == 0 && len(added) > 0 && len(removed) > 0 {
+		ratio := float64(len(added)) / float64(len(removed))
+		if ratio >= 0.8 && ratio <= 1.25 {
+			add(TypeRefactor, weightRefactor, "rewrites code without adding functions or types (%d added, %d removed lines)", len(added), len(removed))
+		}
+	}
+}
+
+// classifyCommits counts each commit's type, from its Conventional Commit
+// header or, at half the weight, from its first word.
+func (a *Analyzer) classifyCommits(add func(CommitType, float64, string, ...any)) {
+	if len(a.commits) == 0 {
+		return
+	}
+	share := weightCommits / float64(len(a.commits))
+
+	for _, c := range a.commits {
+		if conv, err := ParseConventional(c.Subject); err == nil {
+			add(conv.Type, share, "commit %q", c.Subject)
+			continue
+		}
+		fields := strings.Fields(strings.ToLower(c.Subject))
+		if len(fields) == 0 {
+			continue
+		}
+		if t, ok := messageVerbs[strings.Trim(fields[0], ":,.")]; ok {
+			add(t, share/2, "commit %q starts with %q", c.Subject, fields[0])
+		}
+	}
+}
+
+func countMatches(re *regexp.Regexp, lines []string) int {
+	n := 0
+	for _, line := range lines {
+		if re.MatchString(line) {
+			n++
+		}
+	}
+	return n
+}
+
+// whitespaceOnly reports whether the added lines are the removed lines
+// with different whitespace.
+func whitespaceOnly(added, removed []string) bool {
+	strip := func(lines []string) []string {
+		var out []string
+		for _, line := range lines {
+			if s := strings.Join(strings.Fields(line), ""); s != "" {
+				out = append(out, s)
+			}
+		}
+		sort.Strings(out)
+		return out
+	}
+	a, r := strip(added), strip(removed)
+	return len(a) > 0 && slices.Equal(a, r)
+}
+
+func categoryName(t CommitType) string {
+	switch t {
+	case "":
+		return "source files"
+	case TypeDocs:
+		return "documentation"
+	case TypeTest:
+		return "test files"
+	case TypeBuild:
+		return "build files"
+	case TypeCI:
+		return "CI files"
+	}
+	return string(t) + " files"
+}
+
+func sampleFiles(files []string) string {
+	if len(files) <= 3 {
+		return strings.Join(files, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(files[:3], ", "), len(files)-3)
+}
@@ -0,0 +1,107 @@
+package commit_test
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+)
+
+// fileDiff returns a diff adding lines to path.
+func fileDiff(path string, lines ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\nindex abc123..def456 100644\n--- a/%s\n+++ b/%s\n@@ -1 +1,%d @@\n", path, path, path, path, len(lines))
+	for _, line := range lines {
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+var _ = Describe("Classify", func() {
+	goLines := func(n int) []string {
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = fmt.Sprintf("+\tx%d := compute(%d)", i, i)
+		}
+		return lines
+	}
+
+	It("should weigh file categories by changed lines", func() {
+		diff := fileDiff("internal/api/client.go", append(goLines(40), "+func NewClient() {}")...) +
+			fileDiff("README.md", "+One more line")
+		analyzer := commit.NewAnalyzer(diff, []string{"internal/api/client.go", "README.md"})
+
+		ranked := analyzer.Classify()
+		Expect(ranked[0].Type).To(Equal(commit.TypeFeat))
+		Expect(ranked).To(ContainElement(HaveField("Type", commit.TypeDocs)))
+	})
+
+	It("should not let a weak keyword decide", func() {
+		diff := fileDiff("internal/api/client.go", "+func NewClient() {}", "+\t// the cache lives elsewhere")
+		analyzer := commit.NewAnalyzer(diff, []string{"internal/api/client.go"})
+		Expect(analyzer.Classify()[0].Type).To(Equal(commit.TypeFeat))
+	})
+
+	It("should count keywords in added lines more than in removed ones", func() {
+		diff := fileDiff("main.go", "-\t// TODO: fix this hack", "+\treturn nil")
+		analyzer := commit.NewAnalyzer(diff, []string{"main.go"})
+
+		Expect(scoreFor(analyzer.Classify(), commit.TypeFix).Score).To(BeNumerically("~", 0.25))
+	})
+
+	It("should follow the commit messages", func() {
+		diff := fileDiff("internal/api/client.go", "+func NewClient() {}")
+		analyzer := commit.NewAnalyzer(diff, []string{"internal/api/client.go"})
+		analyzer.SetCommits([]commit.CommitInfo{
+			{Hash: "a1", Subject: "perf(api): pool connections"},
+			{Hash: "b2", Subject: "Optimize the retry loop"},
+		})
+
+		ranked := analyzer.Classify()
+		Expect(ranked[0].Type).To(Equal(commit.TypePerf))
+		Expect(ranked[0].Evidence).To(ContainElement(HaveField("Reason", `commit "perf(api): pool connections"`)))
+	})
+
+	It("should detect whitespace-only changes as style", func() {
+		diff := fileDiff("main.go", "-\tif x  {", "+\tif x {", "-return", "+\treturn")
+		analyzer := commit.NewAnalyzer(diff, []string{"main.go"})
+		Expect(analyzer.Classify()[0].Type).To(Equal(commit.TypeStyle))
+	})
+
+	It("should rank every type with confidences summing to one", func() {
+		diff := fileDiff("main.go", "+func Fix() {}", "+\t// fixed a crash") + fileDiff("main_test.go", "+func TestFix() {}")
+		analyzer := commit.NewAnalyzer(diff, []string{"main.go", "main_test.go"})
+
+		ranked := analyzer.Classify()
+		total := 0.0
+		for i, score := range ranked {
+			total += score.Confidence
+			Expect(score.Evidence).NotTo(BeEmpty())
+			if i > 0 {
+				Expect(score.Score).To(BeNumerically("<=", ranked[i-1].Score))
+			}
+		}
+		Expect(total).To(BeNumerically("~", 1.0, 1e-9))
+		Expect(ranked[0].Type).To(Equal(commit.TypeFix))
+	})
+
+	It("should default to feat without evidence", func() {
+		ranked := commit.NewAnalyzer("", nil).Classify()
+		Expect(ranked).To(HaveLen(1))
+		Expect(ranked[0].Type).To(Equal(commit.TypeFeat))
+		Expect(ranked[0].Confidence).To(Equal(1.0))
+	})
+})
+
+func scoreFor(ranked []commit.TypeScore, t commit.CommitType) commit.TypeScore {
+	for _, score := range ranked {
+		if score.Type == t {
+			return score
+		}
+	}
+	Fail(fmt.Sprintf("type %s not ranked", t))
+	return commit.TypeScore{}
+}
@@ -0,0 +1,66 @@
+package commit
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	headerPattern   = regexp.MustCompile(`^(\w+)(?:\(([^()]*)\))?(!)?: (.*)$`)
+	breakingPattern = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE: (.+)$`)
+)
+
+// Conventional is a commit message or PR title parsed according to the
+// Conventional Commits specification.
+type Conventional struct {
+	Type     CommitType
+	Scope    string
+	Breaking bool
+	Subject  string
+	Body     string
+
+	// BreakingNote is the text of a BREAKING CHANGE footer, if any.
+	BreakingNote string
+}
+
+// ParseConventional parses a message of the form "type(scope)!: subject",
+// followed by an optional body separated by a blank line.
+func ParseConventional(message string) (*Conventional, error) {
+	header, body, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	header = strings.TrimSpace(header)
+
+	matches := headerPattern.FindStringSubmatch(header)
+	if matches == nil {
+		return nil, fmt.Errorf("%q is not a Conventional Commit header (expected \"type(scope): subject\")", header)
+	}
+
+	c := &Conventional{
+		Type:     CommitType(matches[1]),
+		Scope:    matches[2],
+		Breaking: matches[3] == "!",
+		Subject:  matches[4],
+		Body:     strings.TrimSpace(body),
+	}
+
+	if note := breakingPattern.FindStringSubmatch(c.Body); note != nil {
+		c.Breaking = true
+		c.BreakingNote = strings.TrimSpace(note[1])
+	}
+
+	return c, nil
+}
+
+// Header renders the parsed message back into a single line.
+func (c *Conventional) Header() string {
+	var header strings.Builder
+	header.WriteString(string(c.Type))
+	if c.Scope != "" {
+		header.WriteString("(" + c.Scope + ")")
+	}
+	if c.Breaking {
+		header.WriteString("!")
+	}
+	header.WriteString(": " + c.Subject)
+	return header.String()
+}
@@ -0,0 +1,45 @@
+package commit_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+)
+
+var _ = Describe("ParseConventional", func() {
+	It("should parse type, scope and subject", func() {
+		c, err := commit.ParseConventional("fix(api): handle timeouts")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Type).To(Equal(commit.TypeFix))
+		Expect(c.Scope).To(Equal("api"))
+		Expect(c.Subject).To(Equal("handle timeouts"))
+		Expect(c.Breaking).To(BeFalse())
+	})
+
+	It("should parse headers without a scope", func() {
+		c, err := commit.ParseConventional("docs: update readme")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Scope).To(BeEmpty())
+		Expect(c.Header()).To(Equal("docs: update readme"))
+	})
+
+	It("should detect breaking changes marked with !", func() {
+		c, err := commit.ParseConventional("feat(auth)!: drop basic auth")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Breaking).To(BeTrue())
+		Expect(c.Header()).To(Equal("feat(auth)!: drop basic auth"))
+	})
+
+	It("should detect breaking change footers", func() {
+		c, err := commit.ParseConventional("feat: new config\n\nDetails.\n\nBREAKING CHANGE: config moved to .cpr/")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Breaking).To(BeTrue())
+		Expect(c.BreakingNote).To(Equal("config moved to .cpr/"))
+	})
+
+	It("should reject non-conventional messages", func() {
+		_, err := commit.ParseConventional("fixed stuff")
+		Expect(err).To(HaveOccurred())
+	})
+})
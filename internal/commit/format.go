@@ -0,0 +1,189 @@
+package commit
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Built-in title format names.
+const (
+	FormatAngular  = "angular"
+	FormatGitmoji  = "gitmoji"
+	FormatTicket   = "ticket"
+	FormatTemplate = "template"
+)
+
+var issueKeyPattern = regexp.MustCompile(`[A-Z][A-Z0-9]+-\d+`)
+
+// gitmojis maps commit types onto the emoji gitmoji.dev uses for them.
+var gitmojis = map[CommitType]string{
+	TypeFeat:     "✨",
+	TypeFix:      "🐛",
+	TypeDocs:     "📝",
+	TypeStyle:    "🎨",
+	TypeRefactor: "♻️",
+	TypePerf:     "⚡️",
+	TypeTest:     "✅",
+	TypeBuild:    "📦️",
+	TypeCI:       "👷",
+	TypeChore:    "🔧",
+}
+
+// TitleData is the information title formats can use.
+type TitleData struct {
+	Type        CommitType
+	Scope       string
+	Description string
+	Breaking    bool
+	// IssueKeys are ticket references such as "PROJ-123" found in the branch.
+	IssueKeys []string
+	Branch    string
+}
+
+// Issue returns the first issue key, or "" if there is none.
+func (d TitleData) Issue() string {
+	if len(d.IssueKeys) == 0 {
+		return ""
+	}
+	return d.IssueKeys[0]
+}
+
+// TitleFormatter renders a PR title.
+type TitleFormatter interface {
+	Format(data TitleData) (string, error)
+}
+
+// AngularFormat renders "type(scope): description".
+type AngularFormat struct{}
+
+func (AngularFormat) Format(d TitleData) (string, error) {
+	c := Conventional{Type: d.Type, Scope: d.Scope, Breaking: d.Breaking, Subject: d.Description}
+	return c.Header(), nil
+}
+
+// GitmojiFormat renders "✨ description", prefixed with 💥 for breaking
+// changes.
+type GitmojiFormat struct{}
+
+func (GitmojiFormat) Format(d TitleData) (string, error) {
+	emoji, ok := gitmojis[d.Type]
+	if !ok {
+		emoji = gitmojis[TypeChore]
+	}
+	if d.Breaking {
+		emoji = "💥 " + emoji
+	}
+	return fmt.Sprintf("%s %s", emoji, upperFirst(d.Description)), nil
+}
+
+// TicketFormat renders "[PROJ-123] Description", or just the description if
+// the branch names no ticket.
+type TicketFormat struct{}
+
+func (TicketFormat) Format(d TitleData) (string, error) {
+	if d.Issue() == "" {
+		return upperFirst(d.Description), nil
+	}
+	return fmt.Sprintf("[%s] %s", d.Issue(), upperFirst(d.Description)), nil
+}
+
+// TemplateFormat renders a text/template with TitleData as its data, e.g.
+// "{{.Issue}}: {{.Type}} {{.Description}}".
+type TemplateFormat struct {
+	tmpl *template.Template
+}
+
+// NewTemplateFormat parses a title template. Besides the TitleData fields,
+// templates can use the upper, lower, capitalize and join functions.
+func NewTemplateFormat(text string) (*TemplateFormat, error) {
+	tmpl, err := template.New("title").Funcs(template.FuncMap{
+		"upper":      func(v any) string { return strings.ToUpper(fmt.Sprint(v)) },
+		"lower":      func(v any) string { return strings.ToLower(fmt.Sprint(v)) },
+		"capitalize": func(v any) string { return upperFirst(fmt.Sprint(v)) },
+		"join":       strings.Join,
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid title template: %w", err)
+	}
+	return &TemplateFormat{tmpl: tmpl}, nil
+}
+
+func (f *TemplateFormat) Format(d TitleData) (string, error) {
+	var out strings.Builder
+	if err := f.tmpl.Execute(&out, d); err != nil {
+		return "", fmt.Errorf("failed to render title template: %w", err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// NewTitleFormatter returns the built-in format called name. The template
+// format requires text; the other formats ignore it. An empty name selects
+// the Angular format.
+func NewTitleFormatter(name, text string) (TitleFormatter, error) {
+	switch name {
+	case "", FormatAngular:
+		return AngularFormat{}, nil
+	case FormatGitmoji:
+		return GitmojiFormat{}, nil
+	case FormatTicket:
+		return TicketFormat{}, nil
+	case FormatTemplate:
+		if text == "" {
+			return nil, fmt.Errorf("the template title format needs a template")
+		}
+		return NewTemplateFormat(text)
+	default:
+		return nil, fmt.Errorf("unknown title format %q; use angular, gitmoji, ticket or template", name)
+	}
+}
+
+// IssueKeys extracts ticket references such as "PROJ-123" from s, in order
+// and without duplicates.
+func IssueKeys(s string) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, key := range issueKeyPattern.FindAllString(s, -1) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// TitleData returns the detected title parts for branch. The change is
+// breaking when any of the branch's commits says so, with "!" or a
+// BREAKING CHANGE footer.
+func (a *Analyzer) TitleData(branch string) TitleData {
+	return TitleData{
+		Type:        a.detectCommitType(),
+		Scope:       a.detectScope(),
+		Breaking:    a.isBreaking(),
+		Description: a.generateDescription(),
+		IssueKeys:   IssueKeys(branch),
+		Branch:      branch,
+	}
+}
+
+func (a *Analyzer) isBreaking() bool {
+	for _, commit := range a.commits {
+		if c, err := ParseConventional(commit.Subject + "\n\n" + commit.Body); err == nil && c.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateTitleWith renders the detected title parts for branch with f.
+func (a *Analyzer) GenerateTitleWith(f TitleFormatter, branch string) (string, error) {
+	return f.Format(a.TitleData(branch))
+}
+
+func upperFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
@@ -0,0 +1,93 @@
+package commit_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+)
+
+var _ = Describe("Title formats", func() {
+	data := commit.TitleData{
+		Type:        commit.TypeFix,
+		Scope:       "api",
+		Description: "handle timeouts",
+		IssueKeys:   []string{"PROJ-123"},
+		Branch:      "PROJ-123-handle-timeouts",
+	}
+
+	format := func(name, text string, d commit.TitleData) string {
+		f, err := commit.NewTitleFormatter(name, text)
+		Expect(err).NotTo(HaveOccurred())
+		title, err := f.Format(d)
+		Expect(err).NotTo(HaveOccurred())
+		return title
+	}
+
+	It("should default to the Angular format", func() {
+		Expect(format("", "", data)).To(Equal("fix(api): handle timeouts"))
+	})
+
+	It("should render gitmoji titles", func() {
+		Expect(format(commit.FormatGitmoji, "", data)).To(Equal("🐛 Handle timeouts"))
+
+		breaking := data
+		breaking.Type = commit.TypeFeat
+		breaking.Breaking = true
+		Expect(format(commit.FormatGitmoji, "", breaking)).To(Equal("💥 ✨ Handle timeouts"))
+	})
+
+	It("should prefix ticket titles with the issue key", func() {
+		Expect(format(commit.FormatTicket, "", data)).To(Equal("[PROJ-123] Handle timeouts"))
+
+		noTicket := data
+		noTicket.IssueKeys = nil
+		Expect(format(commit.FormatTicket, "", noTicket)).To(Equal("Handle timeouts"))
+	})
+
+	It("should render custom templates", func() {
+		text := `{{.Issue}} {{.Type | upper}}{{if .Scope}} [{{.Scope}}]{{end}}: {{capitalize .Description}}`
+		Expect(format(commit.FormatTemplate, text, data)).To(Equal("PROJ-123 FIX [api]: Handle timeouts"))
+	})
+
+	It("should mark titles breaking when a commit is", func() {
+		diff := "diff --git a/api.go b/api.go\n--- a/api.go\n+++ b/api.go\n+func NewHandler() {}\n"
+		analyzer := commit.NewAnalyzer(diff, []string{"api.go"})
+		analyzer.SetCommits([]commit.CommitInfo{{Hash: "a1", Subject: "feat(api): add NewHandler"}})
+		Expect(analyzer.TitleData("").Breaking).To(BeFalse())
+
+		analyzer.SetCommits([]commit.CommitInfo{
+			{Hash: "a1", Subject: "feat(api): add NewHandler"},
+			{Hash: "b2", Subject: "refactor(api): drop Handle", Body: "BREAKING CHANGE: use NewHandler instead"},
+		})
+		Expect(analyzer.TitleData("").Breaking).To(BeTrue())
+
+		analyzer.SetCommits([]commit.CommitInfo{{Hash: "c3", Subject: "feat(api)!: replace Handle"}})
+		Expect(analyzer.TitleData("").Breaking).To(BeTrue())
+		Expect(analyzer.GenerateTitle()).To(MatchRegexp(`^\w+(\(\w+\))?!: `))
+	})
+
+	It("should reject unknown formats and broken templates", func() {
+		_, err := commit.NewTitleFormatter("emoji", "")
+		Expect(err).To(HaveOccurred())
+
+		_, err = commit.NewTitleFormatter(commit.FormatTemplate, "{{.Type")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("IssueKeys", func() {
+		It("should find ticket keys in branch names", func() {
+			Expect(commit.IssueKeys("feature/PROJ-123-and-OPS-7")).To(Equal([]string{"PROJ-123", "OPS-7"}))
+			Expect(commit.IssueKeys("fix-v2-parsing")).To(BeEmpty())
+		})
+	})
+
+	Describe("Analyzer.GenerateTitleWith", func() {
+		It("should pass the branch's issue keys to the format", func() {
+			analyzer := commit.NewAnalyzer("+func NewHandler() {}\n", []string{"internal/auth/handler.go"})
+			title, err := analyzer.GenerateTitleWith(commit.TicketFormat{}, "PROJ-9-auth")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(title).To(HavePrefix("[PROJ-9] "))
+		})
+	})
+})
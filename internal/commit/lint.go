@@ -0,0 +1,121 @@
+package commit
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Subject cases accepted by TitleRules.SubjectCase.
+const (
+	CaseAny      = ""
+	CaseLower    = "lower"
+	CaseSentence = "sentence"
+)
+
+// DefaultTypes are the Angular commit types cpr generates.
+var DefaultTypes = []CommitType{
+	TypeFeat, TypeFix, TypeDocs, TypeStyle, TypeRefactor,
+	TypePerf, TypeTest, TypeBuild, TypeCI, TypeChore,
+}
+
+// TitleRules configures ValidateTitle, mirroring the commitlint rules teams
+// usually enforce in CI.
+type TitleRules struct {
+	// Types lists the allowed commit types.
+	Types []CommitType
+	// Scopes lists the allowed scopes; any scope is allowed if empty.
+	Scopes []string
+	// RequireScope rejects titles without a scope.
+	RequireScope bool
+	// SubjectCase is CaseAny, CaseLower or CaseSentence.
+	SubjectCase string
+	// MaxLength limits the whole title; zero means no limit.
+	MaxLength int
+	// AllowTrailingPeriod permits subjects ending in a period.
+	AllowTrailingPeriod bool
+}
+
+// DefaultTitleRules returns the rules used when a repository configures none.
+func DefaultTitleRules() TitleRules {
+	return TitleRules{
+		Types:       DefaultTypes,
+		SubjectCase: CaseLower,
+		MaxLength:   72,
+	}
+}
+
+// TitleError lists every rule a title breaks.
+type TitleError struct {
+	Title    string
+	Problems []string
+}
+
+func (e *TitleError) Error() string {
+	return fmt.Sprintf("invalid title %q:\n  - %s", e.Title, strings.Join(e.Problems, "\n  - "))
+}
+
+// ValidateTitle checks a PR title against the rules and returns a
+// *TitleError describing each violation, or nil if the title is valid.
+func ValidateTitle(title string, rules TitleRules) error {
+	c, err := ParseConventional(title)
+	if err != nil {
+		return &TitleError{Title: title, Problems: []string{
+			fmt.Sprintf("title must look like \"type(scope): subject\", e.g. \"fix(api): handle timeouts\"; allowed types: %s", joinTypes(rules.Types)),
+		}}
+	}
+
+	var problems []string
+
+	if len(rules.Types) > 0 && !slices.Contains(rules.Types, c.Type) {
+		problems = append(problems, fmt.Sprintf("type %q is not allowed; use one of: %s", c.Type, joinTypes(rules.Types)))
+	}
+
+	switch {
+	case c.Scope == "" && rules.RequireScope:
+		problems = append(problems, "a scope is required, e.g. \"fix(api): ...\"")
+	case c.Scope != "" && len(rules.Scopes) > 0 && !slices.Contains(rules.Scopes, c.Scope):
+		problems = append(problems, fmt.Sprintf("scope %q is not allowed; use one of: %s", c.Scope, strings.Join(rules.Scopes, ", ")))
+	}
+
+	subject := strings.TrimSpace(c.Subject)
+	if subject == "" {
+		problems = append(problems, "subject must not be empty")
+	} else {
+		first, _ := utf8.DecodeRuneInString(subject)
+		switch {
+		case rules.SubjectCase == CaseLower && unicode.IsUpper(first):
+			problems = append(problems, fmt.Sprintf("subject must start with a lowercase letter: %q", lowerFirst(subject)))
+		case rules.SubjectCase == CaseSentence && unicode.IsLower(first):
+			problems = append(problems, fmt.Sprintf("subject must start with an uppercase letter: %q", upperFirst(subject)))
+		}
+
+		if !rules.AllowTrailingPeriod && strings.HasSuffix(subject, ".") {
+			problems = append(problems, "subject must not end with a period")
+		}
+	}
+
+	if rules.MaxLength > 0 && utf8.RuneCountInString(title) > rules.MaxLength {
+		problems = append(problems, fmt.Sprintf("title is %d characters long; the maximum is %d", utf8.RuneCountInString(title), rules.MaxLength))
+	}
+
+	if len(problems) > 0 {
+		return &TitleError{Title: title, Problems: problems}
+	}
+	return nil
+}
+
+func joinTypes(types []CommitType) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	return strings.Join(names, ", ")
+}
+
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}
@@ -0,0 +1,67 @@
+package commit_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+)
+
+var _ = Describe("ValidateTitle", func() {
+	var rules commit.TitleRules
+
+	BeforeEach(func() {
+		rules = commit.DefaultTitleRules()
+	})
+
+	problems := func(title string) []string {
+		err := commit.ValidateTitle(title, rules)
+		if err == nil {
+			return nil
+		}
+		var titleErr *commit.TitleError
+		Expect(errors.As(err, &titleErr)).To(BeTrue())
+		return titleErr.Problems
+	}
+
+	It("should accept valid titles", func() {
+		Expect(commit.ValidateTitle("fix(api): handle timeouts", rules)).To(Succeed())
+		Expect(commit.ValidateTitle("feat!: drop legacy flags", rules)).To(Succeed())
+	})
+
+	It("should explain the expected format", func() {
+		Expect(problems("fixed stuff")).To(ConsistOf(ContainSubstring(`"type(scope): subject"`)))
+	})
+
+	It("should reject unknown types", func() {
+		Expect(problems("feature: add x")).To(ConsistOf(ContainSubstring(`type "feature" is not allowed`)))
+	})
+
+	It("should enforce the scope enum", func() {
+		rules.Scopes = []string{"api", "cli"}
+		Expect(problems("fix(db): handle timeouts")).To(ConsistOf(ContainSubstring("use one of: api, cli")))
+		Expect(problems("fix(api): handle timeouts")).To(BeEmpty())
+	})
+
+	It("should require a scope when configured", func() {
+		rules.RequireScope = true
+		Expect(problems("fix: handle timeouts")).To(ConsistOf(ContainSubstring("scope is required")))
+	})
+
+	It("should check subject case and suggest a fix", func() {
+		Expect(problems("fix: Handle timeouts")).To(ConsistOf(ContainSubstring(`"handle timeouts"`)))
+
+		rules.SubjectCase = commit.CaseSentence
+		Expect(problems("fix: handle timeouts")).To(ConsistOf(ContainSubstring(`"Handle timeouts"`)))
+	})
+
+	It("should reject trailing periods and long titles", func() {
+		rules.MaxLength = 20
+		Expect(problems("fix: handle all the timeouts.")).To(ConsistOf(
+			ContainSubstring("must not end with a period"),
+			ContainSubstring("maximum is 20"),
+		))
+	})
+})
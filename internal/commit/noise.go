@@ -0,0 +1,298 @@
+package commit
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// FileClass says whether a changed file is written by people or is noise
+// that should not steer the analysis.
+type FileClass string
+
+const (
+	ClassSource    FileClass = ""
+	ClassGenerated FileClass = "generated"
+	ClassVendored  FileClass = "vendored"
+	ClassLock      FileClass = "lock"
+	ClassMinified  FileClass = "minified"
+	ClassIgnored   FileClass = "ignored"
+)
+
+// generatedHeaderPattern matches the header Go tools put in generated files,
+// see https://go.dev/s/generatedcode.
+var generatedHeaderPattern = regexp.MustCompile(`^\+// Code generated .* DO NOT EDIT\.$`)
+
+// defaultNoise are the built-in patterns, in .gitattributes syntax.
+var defaultNoise = []struct {
+	pattern string
+	class   FileClass
+}{
+	{"vendor/", ClassVendored},
+	{"node_modules/", ClassVendored},
+	{"third_party/", ClassVendored},
+	{"*.pb.go", ClassGenerated},
+	{"*.pb.gw.go", ClassGenerated},
+	{"*_pb2.py", ClassGenerated},
+	{"*_pb2_grpc.py", ClassGenerated},
+	{"zz_generated*.go", ClassGenerated},
+	{"*_gen.go", ClassGenerated},
+	{"*.gen.go", ClassGenerated},
+	{"go.sum", ClassLock},
+	{"package-lock.json", ClassLock},
+	{"npm-shrinkwrap.json", ClassLock},
+	{"yarn.lock", ClassLock},
+	{"pnpm-lock.yaml", ClassLock},
+	{"Cargo.lock", ClassLock},
+	{"Gemfile.lock", ClassLock},
+	{"poetry.lock", ClassLock},
+	{"composer.lock", ClassLock},
+	{"*.min.js", ClassMinified},
+	{"*.min.css", ClassMinified},
+	{"*.min.js.map", ClassMinified},
+}
+
+type noiseRule struct {
+	re    *regexp.Regexp
+	class FileClass
+}
+
+// NoiseFilter classifies changed files with .gitattributes style patterns.
+// Later rules take precedence, so a rule with ClassSource can exempt files
+// an earlier rule matched.
+type NoiseFilter struct {
+	rules []noiseRule
+}
+
+// NewNoiseFilter returns a filter without any rules.
+func NewNoiseFilter() *NoiseFilter {
+	return &NoiseFilter{}
+}
+
+// DefaultNoiseFilter returns a filter with the built-in patterns for
+// vendored directories, generated code, lock files and minified bundles.
+func DefaultNoiseFilter() *NoiseFilter {
+	f := NewNoiseFilter()
+	for _, n := range defaultNoise {
+		// The built-in patterns are known to be valid
+		_ = f.Add(n.pattern, n.class)
+	}
+	return f
+}
+
+// Add classifies files matching pattern as class. Patterns follow
+// .gitattributes: without a slash they match a file or directory name at
+// any depth, with one they are relative to the repository root, and "*",
+// "?" and "**" are wildcards. A pattern matching a directory covers
+// everything below it.
+func (f *NoiseFilter) Add(pattern string, class FileClass) error {
+	re, err := globPattern(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	f.rules = append(f.rules, noiseRule{re: re, class: class})
+	return nil
+}
+
+// AddGitAttributes adds the linguist-generated and linguist-vendored
+// attributes from a .gitattributes file. Unsetting an attribute, as in
+// "-linguist-generated" or "linguist-generated=false", exempts the files.
+func (f *NoiseFilter) AddGitAttributes(content string) error {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		for _, attr := range fields[1:] {
+			name, set := attr, true
+			switch {
+			case strings.HasPrefix(attr, "-"), strings.HasPrefix(attr, "!"):
+				name, set = attr[1:], false
+			case strings.Contains(attr, "="):
+				var value string
+				name, value, _ = strings.Cut(attr, "=")
+				set = value != "false"
+			}
+
+			var class FileClass
+			switch name {
+			case "linguist-generated":
+				class = ClassGenerated
+			case "linguist-vendored":
+				class = ClassVendored
+			default:
+				continue
+			}
+			if !set {
+				class = ClassSource
+			}
+			if err := f.Add(fields[0], class); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Classify returns the class of the last rule matching file.
+func (f *NoiseFilter) Classify(file string) FileClass {
+	class := ClassSource
+	if f == nil {
+		return class
+	}
+	for _, rule := range f.rules {
+		if rule.re.MatchString(file) {
+			class = rule.class
+		}
+	}
+	return class
+}
+
+// globPattern translates a .gitattributes pattern into a regular
+// expression matching the paths it covers.
+func globPattern(pattern string) (*regexp.Regexp, error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.Trim(pattern, "/")
+
+	var re strings.Builder
+	if anchored {
+		re.WriteString("^")
+	} else {
+		re.WriteString("(^|/)")
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("(/.*)?$")
+
+	return regexp.Compile(re.String())
+}
+
+// SetNoiseFilter replaces the filter deciding which changed files are noise.
+// Noise is left out of type and scope detection and collapsed in the
+// summary. NewAnalyzer uses DefaultNoiseFilter.
+func (a *Analyzer) SetNoiseFilter(f *NoiseFilter) {
+	a.noise = f
+	a.classify()
+}
+
+// classify splits the changed files and the diff into source and noise.
+// Files whose diff adds a "Code generated ... DO NOT EDIT." header count as
+// generated whatever the rules say.
+func (a *Analyzer) classify() {
+	a.classes = make(map[string]FileClass)
+	for _, file := range a.changedFiles {
+		if class := a.noise.Classify(file); class != ClassSource {
+			a.classes[file] = class
+		}
+	}
+
+	var current string
+	for _, line := range strings.Split(a.diff, "\n") {
+		if m := diffHeaderPattern.FindStringSubmatch(line); m != nil {
+			current = m[2]
+			if class := a.noise.Classify(current); class != ClassSource {
+				a.classes[current] = class
+			}
+		} else if generatedHeaderPattern.MatchString(line) && a.classes[current] == ClassSource {
+			a.classes[current] = ClassGenerated
+		}
+	}
+
+	a.sourceFiles = nil
+	for _, file := range a.changedFiles {
+		if a.classes[file] == ClassSource {
+			a.sourceFiles = append(a.sourceFiles, file)
+		} else {
+			a.logger.Debug("leaving out noise", "file", file, "class", a.classes[file])
+		}
+	}
+
+	var source []string
+	current = ""
+	for _, line := range strings.Split(a.diff, "\n") {
+		if m := diffHeaderPattern.FindStringSubmatch(line); m != nil {
+			current = m[2]
+		}
+		if a.classes[current] == ClassSource {
+			source = append(source, line)
+		}
+	}
+	a.sourceDiff = strings.Join(source, "\n")
+
+	// A change made only of noise, such as a go.sum bump, is still analyzed
+	a.onlyNoise = len(a.sourceFiles) == 0 && len(a.changedFiles) > 0
+	if a.onlyNoise {
+		a.sourceFiles = a.changedFiles
+		a.sourceDiff = a.diff
+	}
+}
+
+// FileClass returns the class of a changed file.
+func (a *Analyzer) FileClass(file string) FileClass {
+	return a.classes[file]
+}
+
+// noiseSummary describes the noise files in one line, e.g. "3 more files
+// not shown: 2 lock, 1 generated", or returns "" if there are none.
+func (a *Analyzer) noiseSummary() string {
+	if a.onlyNoise {
+		return ""
+	}
+
+	counts := make(map[FileClass]int)
+	total := 0
+	for _, file := range a.changedFiles {
+		if class := a.classes[file]; class != ClassSource {
+			counts[class]++
+			total++
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+
+	classes := make([]string, 0, len(counts))
+	for class := range counts {
+		classes = append(classes, string(class))
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		ci, cj := counts[FileClass(classes[i])], counts[FileClass(classes[j])]
+		if ci != cj {
+			return ci > cj
+		}
+		return classes[i] < classes[j]
+	})
+
+	parts := make([]string, len(classes))
+	for i, class := range classes {
+		parts[i] = fmt.Sprintf("%d %s", counts[FileClass(class)], class)
+	}
+
+	noun := "files"
+	if total == 1 {
+		noun = "file"
+	}
+	return fmt.Sprintf("%d more %s not shown: %s", total, noun, strings.Join(parts, ", "))
+}
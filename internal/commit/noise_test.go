@@ -0,0 +1,108 @@
+package commit_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+)
+
+var _ = Describe("NoiseFilter", func() {
+	var filter *commit.NoiseFilter
+
+	BeforeEach(func() {
+		filter = commit.DefaultNoiseFilter()
+	})
+
+	DescribeTable("built-in patterns",
+		func(file string, class commit.FileClass) {
+			Expect(filter.Classify(file)).To(Equal(class))
+		},
+		Entry("vendored Go", "vendor/github.com/x/y/z.go", commit.ClassVendored),
+		Entry("nested node_modules", "web/node_modules/react/index.js", commit.ClassVendored),
+		Entry("protobuf", "api/v1/service.pb.go", commit.ClassGenerated),
+		Entry("go.sum", "go.sum", commit.ClassLock),
+		Entry("nested lock file", "web/package-lock.json", commit.ClassLock),
+		Entry("minified bundle", "static/app.min.js", commit.ClassMinified),
+		Entry("source", "internal/vendorx/client.go", commit.ClassSource),
+		Entry("go.mod", "go.mod", commit.ClassSource),
+	)
+
+	It("should anchor patterns containing a slash", func() {
+		Expect(filter.Add("gen/**/*.ts", commit.ClassIgnored)).To(Succeed())
+		Expect(filter.Classify("gen/a/b/c.ts")).To(Equal(commit.ClassIgnored))
+		Expect(filter.Classify("gen/c.ts")).To(Equal(commit.ClassIgnored))
+		Expect(filter.Classify("web/gen/c.ts")).To(Equal(commit.ClassSource))
+	})
+
+	It("should read linguist attributes and let later rules win", func() {
+		Expect(filter.AddGitAttributes(`
+# comment
+*.generated.ts linguist-generated=true
+assets/** linguist-vendored
+vendor/ourfork/** -linguist-vendored
+*.txt text eol=lf
+`)).To(Succeed())
+
+		Expect(filter.Classify("web/api.generated.ts")).To(Equal(commit.ClassGenerated))
+		Expect(filter.Classify("assets/lib/jquery.js")).To(Equal(commit.ClassVendored))
+		Expect(filter.Classify("vendor/ourfork/patch.go")).To(Equal(commit.ClassSource))
+		Expect(filter.Classify("notes.txt")).To(Equal(commit.ClassSource))
+	})
+})
+
+var _ = Describe("Analyzer noise handling", func() {
+	diff := `diff --git a/go.sum b/go.sum
+index abc123..def456 100644
+--- a/go.sum
++++ b/go.sum
+@@ -1 +1,2 @@
++github.com/x/y v1.0.0 h1:abc
+diff --git a/internal/api/client.go b/internal/api/client.go
+index abc123..def456 100644
+--- a/internal/api/client.go
++++ b/internal/api/client.go
+@@ -1,3 +1,4 @@
+ package api
++// handle nil pointer when the response is empty
+diff --git a/internal/store/zz_types.go b/internal/store/zz_types.go
+new file mode 100644
+--- /dev/null
++++ b/internal/store/zz_types.go
+@@ -0,0 +1,3 @@
++// Code generated by stringer. DO NOT EDIT.
++package store
++func NewStore() {}
+`
+	files := []string{"go.sum", "internal/api/client.go", "internal/store/zz_types.go"}
+
+	It("should leave noise out of type and scope detection", func() {
+		analyzer := commit.NewAnalyzer(diff, files)
+		Expect(analyzer.GenerateTitle()).To(Equal("fix(api): resolve issues"))
+	})
+
+	It("should classify files with a generated header", func() {
+		analyzer := commit.NewAnalyzer(diff, files)
+		Expect(analyzer.FileClass("internal/store/zz_types.go")).To(Equal(commit.ClassGenerated))
+		Expect(analyzer.FileClass("go.sum")).To(Equal(commit.ClassLock))
+		Expect(analyzer.FileClass("internal/api/client.go")).To(Equal(commit.ClassSource))
+	})
+
+	It("should collapse noise in the summary", func() {
+		summary := commit.NewAnalyzer(diff, files).GenerateSummary()
+		Expect(summary).To(ContainSubstring("- `internal/api/client.go`\n- 2 more files not shown: 1 generated, 1 lock\n"))
+		Expect(summary).NotTo(ContainSubstring("`go.sum`"))
+	})
+
+	It("should still analyze a change made only of noise", func() {
+		analyzer := commit.NewAnalyzer(diff, []string{"go.sum"})
+		Expect(analyzer.GenerateTitle()).To(HavePrefix("build"))
+		Expect(analyzer.GenerateSummary()).To(ContainSubstring("- `go.sum`\n"))
+	})
+
+	It("should analyze everything without a filter", func() {
+		analyzer := commit.NewAnalyzer(diff, files)
+		analyzer.SetNoiseFilter(nil)
+		Expect(analyzer.GenerateTitle()).To(HavePrefix("build"))
+	})
+})
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+	"github.com/fraser-isbester/cpr/internal/render"
+	"gopkg.in/yaml.v3"
+)
+
+// Path is where the configuration lives, relative to the repository root.
+const Path = ".cpr/config.yaml"
+
+// Config is the per-repository cpr configuration.
+type Config struct {
+	Title    TitleConfig    `yaml:"title"`
+	Template TemplateConfig `yaml:"template"`
+	Noise    NoiseConfig    `yaml:"noise"`
+}
+
+// TitleConfig selects how PR titles are generated and holds the rules they
+// are validated against. Unset rules fall back to commit.DefaultTitleRules.
+type TitleConfig struct {
+	// Format is angular (the default), gitmoji, ticket or template.
+	Format string `yaml:"format"`
+	// Template is the text/template used by the template format.
+	Template string `yaml:"template"`
+
+	Types               []string `yaml:"types"`
+	Scopes              []string `yaml:"scopes"`
+	RequireScope        bool     `yaml:"require_scope"`
+	SubjectCase         *string  `yaml:"subject_case"`
+	MaxLength           *int     `yaml:"max_length"`
+	AllowTrailingPeriod bool     `yaml:"allow_trailing_period"`
+}
+
+// TemplateConfig controls how PR templates are chosen and filled in. Its
+// sections and checklist rules are added to render.DefaultFillRules; map a
+// default to "" to disable it.
+type TemplateConfig struct {
+	// Sections maps section headings to the source that fills them.
+	Sections map[string]string `yaml:"sections"`
+	// Checklist maps checklist item text to the condition that ticks it.
+	Checklist map[string]string `yaml:"checklist"`
+	// KeepComments leaves <!-- --> guidance comments in place.
+	KeepComments bool `yaml:"keep_comments"`
+	// Types maps commit types to the name of the PR template to use for
+	// them, e.g. fix: bugfix.
+	Types map[string]string `yaml:"types"`
+}
+
+// NoiseConfig adds to the built-in patterns for generated, vendored and
+// lock files that the analysis leaves out. Patterns use .gitattributes
+// syntax.
+type NoiseConfig struct {
+	// Ignore lists more files to leave out.
+	Ignore []string `yaml:"ignore"`
+	// Include lists files to analyze even if a built-in pattern or
+	// .gitattributes marks them as noise.
+	Include []string `yaml:"include"`
+}
+
+// Load reads the configuration of the repository at root. A missing file
+// yields the defaults; a file with an unknown subject_case is rejected.
+func Load(root string) (*Config, error) {
+	cfg := &Config{}
+
+	data, err := os.ReadFile(filepath.Join(root, Path))
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", Path, err)
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", Path, err)
+	}
+
+	if err := cfg.Title.validate(); err != nil {
+		return nil, fmt.Errorf("invalid title config in %s: %w", Path, err)
+	}
+
+	return cfg, nil
+}
+
+func (t TitleConfig) validate() error {
+	if t.SubjectCase == nil {
+		return nil
+	}
+	switch *t.SubjectCase {
+	case commit.CaseAny, commit.CaseLower, commit.CaseSentence:
+		return nil
+	}
+	return fmt.Errorf("unknown subject_case %q; use %s, %s or \"\"", *t.SubjectCase, commit.CaseLower, commit.CaseSentence)
+}
+
+// Rules converts the title configuration into validation rules.
+func (t TitleConfig) Rules() commit.TitleRules {
+	rules := commit.DefaultTitleRules()
+
+	if len(t.Types) > 0 {
+		rules.Types = make([]commit.CommitType, len(t.Types))
+		for i, name := range t.Types {
+			rules.Types[i] = commit.CommitType(name)
+		}
+	}
+	rules.Scopes = t.Scopes
+	rules.RequireScope = t.RequireScope
+	if t.SubjectCase != nil {
+		rules.SubjectCase = *t.SubjectCase
+	}
+	if t.MaxLength != nil {
+		rules.MaxLength = *t.MaxLength
+	}
+	rules.AllowTrailingPeriod = t.AllowTrailingPeriod
+
+	return rules
+}
+
+// Formatter returns the configured title format.
+func (t TitleConfig) Formatter() (commit.TitleFormatter, error) {
+	return commit.NewTitleFormatter(t.Format, t.Template)
+}
+
+// IsConventional reports whether titles follow Conventional Commits, and so
+// can be validated against the rules.
+func (t TitleConfig) IsConventional() bool {
+	return t.Format == "" || t.Format == commit.FormatAngular
+}
+
+// FillRules merges the template configuration into the default fill rules.
+func (t TemplateConfig) FillRules() (render.FillRules, error) {
+	rules := render.DefaultFillRules()
+	for heading, source := range t.Sections {
+		rules.Sections[render.SectionKey(heading)] = source
+	}
+	for item, condition := range t.Checklist {
+		rules.Checklist[strings.ToLower(item)] = condition
+	}
+	rules.KeepComments = t.KeepComments
+
+	if err := rules.Validate(); err != nil {
+		return render.FillRules{}, fmt.Errorf("invalid template config in %s: %w", Path, err)
+	}
+	return rules, nil
+}
+
+// Filter builds the noise filter from the built-in patterns, the
+// linguist attributes in gitattributes and the configured patterns, in that
+// order of precedence from lowest to highest.
+func (n NoiseConfig) Filter(gitattributes string) (*commit.NoiseFilter, error) {
+	filter := commit.DefaultNoiseFilter()
+	if err := filter.AddGitAttributes(gitattributes); err != nil {
+		return nil, fmt.Errorf("invalid .gitattributes: %w", err)
+	}
+	for _, pattern := range n.Ignore {
+		if err := filter.Add(pattern, commit.ClassIgnored); err != nil {
+			return nil, fmt.Errorf("invalid noise config in %s: %w", Path, err)
+		}
+	}
+	for _, pattern := range n.Include {
+		if err := filter.Add(pattern, commit.ClassSource); err != nil {
+			return nil, fmt.Errorf("invalid noise config in %s: %w", Path, err)
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,116 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+	"github.com/fraser-isbester/cpr/internal/config"
+	"github.com/fraser-isbester/cpr/internal/render"
+)
+
+var _ = Describe("Config", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "cpr-config-*")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	write := func(content string) {
+		path := filepath.Join(tmpDir, config.Path)
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+	}
+
+	It("should use the default rules without a config file", func() {
+		cfg, err := config.Load(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Title.Rules()).To(Equal(commit.DefaultTitleRules()))
+	})
+
+	It("should override title rules", func() {
+		write(`
+title:
+  types: [feat, fix]
+  scopes: [api]
+  subject_case: ""
+  max_length: 50
+`)
+		cfg, err := config.Load(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		rules := cfg.Title.Rules()
+		Expect(rules.Types).To(Equal([]commit.CommitType{commit.TypeFeat, commit.TypeFix}))
+		Expect(rules.Scopes).To(Equal([]string{"api"}))
+		Expect(rules.SubjectCase).To(Equal(commit.CaseAny))
+		Expect(rules.MaxLength).To(Equal(50))
+	})
+
+	It("should reject unknown subject cases", func() {
+		write("title:\n  subject_case: upper\n")
+		_, err := config.Load(tmpDir)
+		Expect(err).To(MatchError(ContainSubstring(`unknown subject_case "upper"`)))
+	})
+
+	It("should merge template rules into the defaults", func() {
+		write(`
+template:
+  sections:
+    "How was this tested?": tests
+    Testing: ""
+  checklist:
+    changelog: docs_changed
+`)
+		cfg, err := config.Load(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		rules, err := cfg.Template.FillRules()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rules.Sections).To(HaveKeyWithValue("how was this tested?", render.SourceTests))
+		Expect(rules.Sections).To(HaveKeyWithValue("testing", ""))
+		Expect(rules.Sections).To(HaveKeyWithValue("summary", render.SourceSummary))
+		Expect(rules.Checklist).To(HaveKeyWithValue("changelog", render.ConditionDocsChanged))
+	})
+
+	It("should reject unknown template sources", func() {
+		write("template:\n  sections:\n    Notes: vibes\n")
+		cfg, err := config.Load(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = cfg.Template.FillRules()
+		Expect(err).To(MatchError(ContainSubstring("vibes")))
+	})
+
+	It("should layer noise patterns over .gitattributes", func() {
+		write(`
+noise:
+  ignore: ["docs/generated/"]
+  include: ["vendor/ourfork/"]
+`)
+		cfg, err := config.Load(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		filter, err := cfg.Noise.Filter("api/*.json linguist-generated\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(filter.Classify("go.sum")).To(Equal(commit.ClassLock))
+		Expect(filter.Classify("api/openapi.json")).To(Equal(commit.ClassGenerated))
+		Expect(filter.Classify("docs/generated/cli.md")).To(Equal(commit.ClassIgnored))
+		Expect(filter.Classify("vendor/ourfork/x.go")).To(Equal(commit.ClassSource))
+		Expect(filter.Classify("vendor/other/x.go")).To(Equal(commit.ClassVendored))
+	})
+
+	It("should report invalid YAML", func() {
+		write("title: [")
+		_, err := config.Load(tmpDir)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,125 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// CherryPickConflictError reports a commit that could not be applied cleanly.
+type CherryPickConflictError struct {
+	Commit string
+	Target string
+	Files  []string
+}
+
+func (e *CherryPickConflictError) Error() string {
+	return fmt.Sprintf("cherry-pick of %s onto %s conflicts in: %s", e.Commit, e.Target, strings.Join(e.Files, ", "))
+}
+
+// BranchExistsError reports a backport branch left by an earlier run.
+type BranchExistsError struct {
+	Branch string
+}
+
+func (e *BranchExistsError) Error() string {
+	return fmt.Sprintf("branch %s already exists", e.Branch)
+}
+
+// Fetch fetches refspecs from origin using the git CLI, so the user's
+// credential setup applies.
+func (r *Repository) Fetch(refspecs ...string) error {
+	args := append([]string{"fetch", "--quiet", "origin"}, refspecs...)
+	if _, err := r.runGit(r.path, args...); err != nil {
+		return fmt.Errorf("failed to fetch: %w", err)
+	}
+	return nil
+}
+
+// Backport creates branch from origin's copy of target, cherry-picks commits
+// onto it in order and pushes it to origin. The work happens in a temporary
+// worktree so the user's checkout is left alone. On conflict, or when the
+// push fails, the branch is removed; a conflict is returned as a
+// *CherryPickConflictError. An existing branch is left alone and reported
+// as a *BranchExistsError.
+func (r *Repository) Backport(commits []string, target, branch string) error {
+	if _, err := r.runGit(r.path, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+		return &BranchExistsError{Branch: branch}
+	}
+
+	dir, err := os.MkdirTemp("", "cpr-backport-*")
+	if err != nil {
+		return fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := r.runGit(r.path, "worktree", "add", "--quiet", "-b", branch, dir, "origin/"+target); err != nil {
+		return fmt.Errorf("failed to create branch %s: %w", branch, err)
+	}
+	defer r.runGit(r.path, "worktree", "remove", "--force", dir)
+
+	for _, c := range commits {
+		args := []string{"cherry-pick", "-x"}
+		if r.isMergeCommit(c) {
+			args = append(args, "-m", "1")
+		}
+		args = append(args, c)
+
+		if _, err := r.runGit(dir, args...); err != nil {
+			out, _ := r.runGit(dir, "diff", "--name-only", "--diff-filter=U")
+			files := strings.Fields(out)
+
+			r.runGit(dir, "cherry-pick", "--abort")
+			r.runGit(r.path, "worktree", "remove", "--force", dir)
+			r.runGit(r.path, "branch", "-D", branch)
+
+			if len(files) == 0 {
+				return fmt.Errorf("failed to cherry-pick %s: %w", c, err)
+			}
+			return &CherryPickConflictError{Commit: c, Target: target, Files: files}
+		}
+	}
+
+	// Pushed with the CLI as well, since go-git may not see the fetched objects
+	if _, err := r.runGit(dir, "push", "--quiet", "origin", branch); err != nil {
+		// Without the branch a rerun cherry-picks again
+		r.runGit(r.path, "worktree", "remove", "--force", dir)
+		r.runGit(r.path, "branch", "-D", branch)
+		return fmt.Errorf("failed to push branch: %w", err)
+	}
+
+	return nil
+}
+
+// CommitSubject returns the first line of a commit's message.
+func (r *Repository) CommitSubject(rev string) (string, error) {
+	out, err := r.runGit(r.path, "log", "-1", "--format=%s", rev)
+	if err != nil {
+		return "", fmt.Errorf("failed to read commit %s: %w", rev, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// isMergeCommit goes through the CLI because commits fetched by it may not be
+// visible to an already opened go-git repository.
+func (r *Repository) isMergeCommit(rev string) bool {
+	out, err := r.runGit(r.path, "rev-list", "--parents", "-n", "1", rev)
+	return err == nil && len(strings.Fields(out)) > 2
+}
+
+func (r *Repository) runGit(dir string, args ...string) (string, error) {
+	r.logger.Debug("running git", "args", args, "dir", dir)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return string(out), fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
@@ -0,0 +1,99 @@
+package git_test
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/git"
+)
+
+var _ = Describe("Backport", func() {
+	var (
+		tmpDir    string
+		originDir string
+		repo      *git.Repository
+	)
+
+	commit := func(name, content string) string {
+		GinkgoHelper()
+		return commitFile(tmpDir, name, content, "Change "+name)
+	}
+
+	BeforeEach(func() {
+		originDir = GinkgoT().TempDir()
+		runGit(originDir, "init", "-q", "--bare", "-b", "main")
+		tmpDir = initRepo("main")
+		runGit(tmpDir, "remote", "add", "origin", originDir)
+
+		commit("app.txt", "v1\n")
+		runGit(tmpDir, "branch", "release/1.0")
+		runGit(tmpDir, "push", "--quiet", "origin", "main", "release/1.0")
+
+		repo = git.NewRepository(tmpDir)
+	})
+
+	It("should cherry-pick onto a new branch and push it", func() {
+		fix := commit("fix.txt", "fix\n")
+
+		Expect(repo.Fetch("release/1.0")).To(Succeed())
+		Expect(repo.Backport([]string{fix}, "release/1.0", "backport/fix")).To(Succeed())
+
+		files := runGit(originDir, "ls-tree", "--name-only", "backport/fix")
+		Expect(strings.Fields(files)).To(ConsistOf("app.txt", "fix.txt"))
+		Expect(runGit(tmpDir, "rev-parse", "--abbrev-ref", "HEAD")).To(Equal("main"))
+	})
+
+	It("should report conflicting files and clean up the branch", func() {
+		runGit(tmpDir, "checkout", "--quiet", "release/1.0")
+		commit("app.txt", "release\n")
+		runGit(tmpDir, "push", "--quiet", "origin", "release/1.0")
+		runGit(tmpDir, "checkout", "--quiet", "main")
+		fix := commit("app.txt", "main\n")
+
+		Expect(repo.Fetch("release/1.0")).To(Succeed())
+		err := repo.Backport([]string{fix}, "release/1.0", "backport/fix")
+
+		var conflict *git.CherryPickConflictError
+		Expect(errors.As(err, &conflict)).To(BeTrue())
+		Expect(conflict.Files).To(ConsistOf("app.txt"))
+
+		cmd := exec.Command("git", "rev-parse", "--verify", "backport/fix")
+		cmd.Dir = tmpDir
+		Expect(cmd.Run()).To(HaveOccurred())
+	})
+
+	It("should remove the branch when the push fails", func() {
+		fix := commit("fix.txt", "fix\n")
+
+		Expect(repo.Fetch("release/1.0")).To(Succeed())
+		runGit(tmpDir, "remote", "set-url", "--push", "origin", filepath.Join(tmpDir, "missing.git"))
+		Expect(repo.Backport([]string{fix}, "release/1.0", "backport/fix")).To(MatchError(ContainSubstring("failed to push branch")))
+
+		cmd := exec.Command("git", "rev-parse", "--verify", "backport/fix")
+		cmd.Dir = tmpDir
+		Expect(cmd.Run()).To(HaveOccurred())
+	})
+
+	It("should leave an existing branch alone", func() {
+		fix := commit("fix.txt", "fix\n")
+		runGit(tmpDir, "branch", "backport/fix", "release/1.0")
+
+		Expect(repo.Fetch("release/1.0")).To(Succeed())
+		err := repo.Backport([]string{fix}, "release/1.0", "backport/fix")
+
+		var exists *git.BranchExistsError
+		Expect(errors.As(err, &exists)).To(BeTrue())
+		Expect(exists.Branch).To(Equal("backport/fix"))
+		Expect(runGit(tmpDir, "rev-parse", "backport/fix")).To(Equal(runGit(tmpDir, "rev-parse", "release/1.0")))
+	})
+
+	It("should read commit subjects", func() {
+		fix := commit("fix.txt", "fix\n")
+		Expect(repo.CommitSubject(fix)).To(Equal("Change fix.txt"))
+	})
+})
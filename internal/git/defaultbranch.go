@@ -0,0 +1,171 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fraser-isbester/cpr/internal/cache"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// DefaultBranchTTL is how long a default branch learned over the network
+// is cached on disk, unless SetCache says otherwise.
+const DefaultBranchTTL = 24 * time.Hour
+
+// ConfigDefaultBranch is the git config key naming the default branch,
+// for repositories whose origin cannot tell, e.g. `git config
+// cpr.defaultBranch main`.
+const ConfigDefaultBranch = "cpr.defaultBranch"
+
+// ErrNoDefaultBranch is returned when no source names the default branch.
+var ErrNoDefaultBranch = errors.New("failed to determine default branch: origin/HEAD is not set and no main or master branch exists, run 'git remote set-head origin --auto' or set " + ConfigDefaultBranch)
+
+// SetDefaultBranchLookup makes DefaultBranch ask lookup, e.g. the GitHub
+// API, before listing origin's refs.
+func (r *Repository) SetDefaultBranchLookup(lookup func() (string, error)) {
+	r.defaultBranchLookup = lookup
+}
+
+// SetCache caches the default branch DefaultBranch learns over the network
+// in store for ttl, or DefaultBranchTTL if ttl is 0.
+func (r *Repository) SetCache(store *cache.Store, ttl time.Duration) {
+	if ttl == 0 {
+		ttl = DefaultBranchTTL
+	}
+	r.cache = store
+	r.cacheTTL = ttl
+}
+
+// defaultBranchEntry is the disk cache entry for origin's default branch.
+type defaultBranchEntry struct {
+	Branch  string    `json:"branch"`
+	Expires time.Time `json:"expires"`
+}
+
+// DefaultBranch returns origin's default branch. It is resolved from, in
+// order: the local origin/HEAD symbolic ref, the cpr.defaultBranch git
+// config, the disk cache, the lookup given with SetDefaultBranchLookup,
+// listing origin's refs, and last a local main or master branch. The
+// result is memoized, and cached on disk when it took a network call.
+func (r *Repository) DefaultBranch() (string, error) {
+	if r.defaultBranch != "" {
+		return r.defaultBranch, nil
+	}
+	if err := r.open(); err != nil {
+		return "", err
+	}
+
+	branch, from, err := r.resolveDefaultBranch()
+	if err != nil {
+		return "", err
+	}
+	r.logger.Debug("resolved default branch", "branch", branch, "from", from)
+	r.defaultBranch = branch
+	return branch, nil
+}
+
+func (r *Repository) resolveDefaultBranch() (branch, from string, err error) {
+	if branch := r.originHead(); branch != "" {
+		return branch, "origin/HEAD", nil
+	}
+
+	if cfg, err := r.repo.Config(); err == nil {
+		section, key, _ := strings.Cut(ConfigDefaultBranch, ".")
+		if branch := cfg.Raw.Section(section).Option(key); branch != "" {
+			return branch, ConfigDefaultBranch, nil
+		}
+	}
+
+	key := r.defaultBranchCacheKey()
+	var entry defaultBranchEntry
+	if key != "" && r.cache.Get(key, &entry) && entry.Branch != "" && time.Now().Before(entry.Expires) {
+		return entry.Branch, "cache", nil
+	}
+
+	from = "lookup"
+	if r.defaultBranchLookup != nil {
+		branch, err = r.defaultBranchLookup()
+		if err != nil {
+			r.logger.Debug("failed to look up default branch", "err", err)
+		}
+	}
+	if branch == "" {
+		from = "origin's refs"
+		branch = r.listOriginHead()
+	}
+	if branch != "" {
+		if key != "" {
+			entry := defaultBranchEntry{Branch: branch, Expires: time.Now().Add(r.cacheTTL)}
+			if err := r.cache.Put(key, entry); err != nil {
+				r.logger.Debug("failed to cache default branch", "err", err)
+			}
+		}
+		return branch, from, nil
+	}
+
+	if branch := r.findLocalDefaultBranch(); branch != "" {
+		return branch, "local branches", nil
+	}
+	return "", "", ErrNoDefaultBranch
+}
+
+// originHead reads the local refs/remotes/origin/HEAD symbolic ref, as set
+// by clone or `git remote set-head`.
+func (r *Repository) originHead() string {
+	ref, err := r.repo.Reference(plumbing.NewRemoteHEADReferenceName("origin"), false)
+	if err != nil || ref.Type() != plumbing.SymbolicReference {
+		return ""
+	}
+	return strings.TrimPrefix(ref.Target().String(), "refs/remotes/origin/")
+}
+
+// listOriginHead asks origin which branch its HEAD points at, a network
+// round-trip.
+func (r *Repository) listOriginHead() string {
+	remote, err := r.repo.Remote("origin")
+	if err != nil {
+		return ""
+	}
+	refs, err := remote.List(&git.ListOptions{})
+	if err != nil {
+		r.logger.Debug("failed to list origin's refs", "err", err)
+		return ""
+	}
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference {
+			if branch, ok := strings.CutPrefix(ref.Target().String(), "refs/heads/"); ok {
+				return branch
+			}
+		}
+	}
+	return ""
+}
+
+// findLocalDefaultBranch returns main or master if one exists locally.
+func (r *Repository) findLocalDefaultBranch() string {
+	for _, branch := range []string{"main", "master"} {
+		if _, err := r.repo.Reference(plumbing.NewBranchReferenceName(branch), false); err == nil {
+			return branch
+		}
+	}
+	return ""
+}
+
+// defaultBranchCacheKey keys the cache by origin's URL, or is "" without
+// a cache or origin.
+func (r *Repository) defaultBranchCacheKey() string {
+	if r.cache == nil {
+		return ""
+	}
+	url, err := r.RemoteURL("origin")
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(url))
+	return fmt.Sprintf("default-branch/%s", hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,98 @@
+package git_test
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/cache"
+	"github.com/fraser-isbester/cpr/internal/git"
+)
+
+var _ = Describe("DefaultBranch", func() {
+	var (
+		tmpDir string
+		origin string
+		store  *cache.Store
+	)
+
+	BeforeEach(func() {
+		origin = filepath.Join(GinkgoT().TempDir(), "origin.git")
+		store = cache.NewAt(GinkgoT().TempDir())
+
+		tmpDir = initRepo("feature")
+		commitFile(tmpDir, "test.txt", "content", "Initial commit")
+
+		// origin's HEAD is develop, which only origin has
+		runGit(tmpDir, "init", "-q", "--bare", "-b", "develop", origin)
+		runGit(tmpDir, "remote", "add", "origin", origin)
+		runGit(tmpDir, "push", "-q", "origin", "feature:develop")
+	})
+
+	It("should read the local origin/HEAD first", func() {
+		runGit(tmpDir, "update-ref", "refs/remotes/origin/release", "HEAD")
+		runGit(tmpDir, "symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/release")
+		runGit(tmpDir, "config", git.ConfigDefaultBranch, "trunk")
+
+		Expect(git.NewRepository(tmpDir).DefaultBranch()).To(Equal("release"))
+	})
+
+	It("should read cpr.defaultBranch from git config", func() {
+		runGit(tmpDir, "config", git.ConfigDefaultBranch, "trunk")
+
+		Expect(git.NewRepository(tmpDir).DefaultBranch()).To(Equal("trunk"))
+	})
+
+	It("should ask the lookup before listing origin's refs, once, and cache the answer", func() {
+		calls := 0
+		repo := git.NewRepository(tmpDir)
+		repo.SetCache(store, 0)
+		repo.SetDefaultBranchLookup(func() (string, error) {
+			calls++
+			return "stable", nil
+		})
+
+		Expect(repo.DefaultBranch()).To(Equal("stable"))
+		Expect(repo.DefaultBranch()).To(Equal("stable"))
+		Expect(calls).To(Equal(1))
+
+		// Another run finds it on disk
+		next := git.NewRepository(tmpDir)
+		next.SetCache(store, 0)
+		next.SetDefaultBranchLookup(func() (string, error) {
+			return "", errors.New("unreachable")
+		})
+		Expect(next.DefaultBranch()).To(Equal("stable"))
+	})
+
+	It("should list origin's refs when the lookup fails, and not trust expired entries", func() {
+		stale := git.NewRepository(tmpDir)
+		stale.SetCache(store, -time.Hour)
+		stale.SetDefaultBranchLookup(func() (string, error) { return "stable", nil })
+		Expect(stale.DefaultBranch()).To(Equal("stable"))
+
+		repo := git.NewRepository(tmpDir)
+		repo.SetCache(store, 0)
+		repo.SetDefaultBranchLookup(func() (string, error) {
+			return "", errors.New("unreachable")
+		})
+		Expect(repo.DefaultBranch()).To(Equal("develop"))
+	})
+
+	It("should fall back to a local main or master branch", func() {
+		runGit(tmpDir, "remote", "remove", "origin")
+		runGit(tmpDir, "branch", "master")
+
+		Expect(git.NewRepository(tmpDir).DefaultBranch()).To(Equal("master"))
+	})
+
+	It("should fail rather than return the current branch", func() {
+		runGit(tmpDir, "remote", "remove", "origin")
+
+		_, err := git.NewRepository(tmpDir).DefaultBranch()
+		Expect(err).To(MatchError(git.ErrNoDefaultBranch))
+	})
+})
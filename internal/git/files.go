@@ -0,0 +1,112 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Files reads files from the working tree or from a commit's tree. Paths
+// are slash separated and relative to the repository root.
+type Files struct {
+	root string
+	tree *object.Tree
+}
+
+// WorkingTree returns the files checked out in the working tree.
+func (r *Repository) WorkingTree() (*Files, error) {
+	root, err := r.Root()
+	if err != nil {
+		return nil, err
+	}
+	return &Files{root: root}, nil
+}
+
+// TreeAt returns the files in a branch's tree, preferring its
+// remote-tracking branch on origin, or in any other revision.
+func (r *Repository) TreeAt(rev string) (*Files, error) {
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	c, err := r.resolveBase(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree for %s: %w", rev, err)
+	}
+	return &Files{tree: tree}, nil
+}
+
+// ReadFile returns a file's content and whether it exists.
+func (f *Files) ReadFile(name string) (string, bool, error) {
+	if f.tree == nil {
+		data, err := os.ReadFile(filepath.Join(f.root, filepath.FromSlash(name)))
+		if errors.Is(err, os.ErrNotExist) {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		return string(data), true, nil
+	}
+
+	file, err := f.tree.File(name)
+	if errors.Is(err, object.ErrFileNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	content, err := file.Contents()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return content, true, nil
+}
+
+// ListDir returns the names of the regular files in a directory, sorted. A
+// missing directory has no files.
+func (f *Files) ListDir(dir string) ([]string, error) {
+	var names []string
+
+	if f.tree == nil {
+		entries, err := os.ReadDir(filepath.Join(f.root, filepath.FromSlash(dir)))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				names = append(names, entry.Name())
+			}
+		}
+		return names, nil
+	}
+
+	sub, err := f.tree.Tree(path.Clean(dir))
+	if errors.Is(err, object.ErrDirectoryNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	for _, entry := range sub.Entries {
+		if entry.Mode.IsFile() {
+			names = append(names, entry.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
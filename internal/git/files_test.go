@@ -0,0 +1,69 @@
+package git_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/git"
+)
+
+var _ = Describe("Files", func() {
+	var (
+		tmpDir string
+		repo   *git.Repository
+	)
+
+	BeforeEach(func() {
+		tmpDir = initRepo("main")
+		commitFile(tmpDir, ".github/PULL_REQUEST_TEMPLATE/bugfix.md", "committed", "Add template")
+
+		runGit(tmpDir, "checkout", "-q", "-b", "feature")
+		writeFile(tmpDir, ".github/PULL_REQUEST_TEMPLATE/bugfix.md", "edited")
+		writeFile(tmpDir, ".github/PULL_REQUEST_TEMPLATE/feature.md", "new")
+
+		repo = git.NewRepository(tmpDir)
+	})
+
+	It("should read the working tree", func() {
+		files, err := repo.WorkingTree()
+		Expect(err).NotTo(HaveOccurred())
+
+		content, ok, err := files.ReadFile(".github/PULL_REQUEST_TEMPLATE/bugfix.md")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(content).To(Equal("edited"))
+
+		names, err := files.ListDir(".github/PULL_REQUEST_TEMPLATE")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names).To(Equal([]string{"bugfix.md", "feature.md"}))
+	})
+
+	It("should read a branch's tree", func() {
+		files, err := repo.TreeAt("main")
+		Expect(err).NotTo(HaveOccurred())
+
+		content, ok, err := files.ReadFile(".github/PULL_REQUEST_TEMPLATE/bugfix.md")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(content).To(Equal("committed"))
+
+		names, err := files.ListDir(".github/PULL_REQUEST_TEMPLATE")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names).To(Equal([]string{"bugfix.md"}))
+	})
+
+	It("should report missing files and directories", func() {
+		for _, open := range []func() (*git.Files, error){repo.WorkingTree, func() (*git.Files, error) { return repo.TreeAt("main") }} {
+			files, err := open()
+			Expect(err).NotTo(HaveOccurred())
+
+			_, ok, err := files.ReadFile("missing.md")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+
+			names, err := files.ListDir("docs")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names).To(BeEmpty())
+		}
+	})
+})
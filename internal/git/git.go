@@ -1,26 +1,45 @@
 package git
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/fraser-isbester/cpr/internal/cache"
+	"github.com/fraser-isbester/cpr/internal/logging"
 	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/go-git/go-git/v5/plumbing/transport"
 )
 
 type Repository struct {
-	repo *git.Repository
-	path string
+	repo   *git.Repository
+	path   string
+	logger *slog.Logger
+
+	// defaultBranch memoizes DefaultBranch, which the settings below
+	// tune.
+	defaultBranch       string
+	defaultBranchLookup func() (string, error)
+	cache               *cache.Store
+	cacheTTL            time.Duration
 }
 
 func NewRepository(path string) *Repository {
 	if path == "" {
 		path = "."
 	}
-	return &Repository{path: path}
+	return &Repository{path: path, logger: logging.Discard()}
+}
+
+// SetLogger logs what the repository resolves and runs to logger.
+func (r *Repository) SetLogger(logger *slog.Logger) {
+	r.logger = logging.OrDiscard(logger)
 }
 
 func (r *Repository) open() error {
@@ -52,124 +71,117 @@ func (r *Repository) CurrentBranch() (string, error) {
 	return "HEAD", nil
 }
 
-func (r *Repository) DefaultBranch() (string, error) {
-	if err := r.open(); err != nil {
+// Diff returns the patch between the merge base of base and head, and head.
+// Base is a branch, preferring its origin copy, or any other revision such as
+// a tag; head is any revision, with "" meaning HEAD.
+func (r *Repository) Diff(base, head string) (string, error) {
+	baseTree, headTree, err := r.diffTrees(base, head)
+	if err != nil {
 		return "", err
 	}
 
-	// Try to get the default branch from origin
-	remote, err := r.repo.Remote("origin")
+	patch, err := baseTree.Patch(headTree)
 	if err != nil {
-		// If no remote, check common default branches
-		return r.findLocalDefaultBranch()
+		return "", fmt.Errorf("failed to generate patch: %w", err)
 	}
 
-	// Get remote refs
-	refs, err := remote.List(&git.ListOptions{})
-	if err != nil {
-		return r.findLocalDefaultBranch()
-	}
+	return patch.String(), nil
+}
 
-	// Look for HEAD reference
-	for _, ref := range refs {
-		if ref.Name().String() == "HEAD" && ref.Target() != "" {
-			target := ref.Target().String()
-			if strings.HasPrefix(target, "refs/heads/") {
-				return strings.TrimPrefix(target, "refs/heads/"), nil
-			}
-		}
+// diffTrees returns the trees of the merge base of base and head, and of head.
+func (r *Repository) diffTrees(base, head string) (*object.Tree, *object.Tree, error) {
+	if err := r.open(); err != nil {
+		return nil, nil, err
 	}
 
-	// Check for symbolic-ref
-	for _, ref := range refs {
-		if ref.Name().String() == "refs/remotes/origin/HEAD" {
-			target := ref.Target().String()
-			if strings.HasPrefix(target, "refs/remotes/origin/") {
-				return strings.TrimPrefix(target, "refs/remotes/origin/"), nil
-			}
-		}
+	headCommit, err := r.resolveCommit(head)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return r.findLocalDefaultBranch()
-}
+	// Find merge base
+	var baseCommit *object.Commit
 
-func (r *Repository) findLocalDefaultBranch() (string, error) {
-	// Check common default branch names
-	branches := []string{"main", "master"}
-	for _, branch := range branches {
-		ref, err := r.repo.Reference(plumbing.NewBranchReferenceName(branch), false)
-		if err == nil && ref != nil {
-			return branch, nil
-		}
+	// Try origin/base first
+	originRef, err := r.repo.Reference(plumbing.NewRemoteReferenceName("origin", base), true)
+	if err == nil {
+		baseCommit, err = r.findMergeBase(headCommit, originRef.Hash())
 	}
 
-	// If no common branches found, return current branch
-	current, err := r.CurrentBranch()
-	if err != nil {
-		return "", fmt.Errorf("failed to determine default branch")
+	// If that fails, try local base, then any other revision
+	if err != nil || baseCommit == nil {
+		var baseHash plumbing.Hash
+		localRef, err := r.repo.Reference(plumbing.NewBranchReferenceName(base), true)
+		if err == nil {
+			baseHash = localRef.Hash()
+		} else {
+			hash, revErr := r.repo.ResolveRevision(plumbing.Revision(base))
+			if revErr != nil {
+				return nil, nil, fmt.Errorf("failed to find reference for %s: %w", base, err)
+			}
+			baseHash = *hash
+		}
+		baseCommit, err = r.findMergeBase(headCommit, baseHash)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to find merge base: %w", err)
+		}
 	}
-	return current, nil
-}
 
-func (r *Repository) DiffAgainstDefault() (string, error) {
-	if err := r.open(); err != nil {
-		return "", err
-	}
+	r.logger.Debug("found merge base", "base", base, "head", head, "commit", baseCommit.Hash.String())
 
-	defaultBranch, err := r.DefaultBranch()
+	baseTree, err := baseCommit.Tree()
 	if err != nil {
-		return "", err
+		return nil, nil, fmt.Errorf("failed to get base tree: %w", err)
 	}
 
-	// Get HEAD commit
-	head, err := r.repo.Head()
+	headTree, err := headCommit.Tree()
 	if err != nil {
-		return "", fmt.Errorf("failed to get HEAD: %w", err)
+		return nil, nil, fmt.Errorf("failed to get head tree: %w", err)
 	}
 
-	headCommit, err := r.repo.CommitObject(head.Hash())
-	if err != nil {
-		return "", fmt.Errorf("failed to get HEAD commit: %w", err)
-	}
+	return baseTree, headTree, nil
+}
 
-	// Find merge base
-	var baseCommit *object.Commit
-	
-	// Try origin/defaultBranch first
-	originRef, err := r.repo.Reference(plumbing.NewRemoteReferenceName("origin", defaultBranch), true)
-	if err == nil {
-		baseCommit, err = r.findMergeBase(headCommit, originRef.Hash())
-	}
-	
-	// If that fails, try local defaultBranch
-	if err != nil || baseCommit == nil {
-		localRef, err := r.repo.Reference(plumbing.NewBranchReferenceName(defaultBranch), true)
+// resolveCommit returns the commit for a revision, or for HEAD when rev is
+// empty or "HEAD".
+func (r *Repository) resolveCommit(rev string) (*object.Commit, error) {
+	if rev == "" || rev == "HEAD" {
+		head, err := r.repo.Head()
 		if err != nil {
-			return "", fmt.Errorf("failed to find reference for %s: %w", defaultBranch, err)
+			return nil, fmt.Errorf("failed to get HEAD: %w", err)
 		}
-		baseCommit, err = r.findMergeBase(headCommit, localRef.Hash())
+
+		headCommit, err := r.repo.CommitObject(head.Hash())
 		if err != nil {
-			return "", fmt.Errorf("failed to find merge base: %w", err)
+			return nil, fmt.Errorf("failed to get HEAD commit: %w", err)
 		}
+		return headCommit, nil
 	}
 
-	// Generate diff between merge base and HEAD
-	baseTree, err := baseCommit.Tree()
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(rev))
 	if err != nil {
-		return "", fmt.Errorf("failed to get base tree: %w", err)
+		return nil, fmt.Errorf("failed to resolve %s: %w", rev, err)
 	}
 
-	headTree, err := headCommit.Tree()
+	c, err := r.repo.CommitObject(*hash)
 	if err != nil {
-		return "", fmt.Errorf("failed to get head tree: %w", err)
+		return nil, fmt.Errorf("failed to get commit for %s: %w", rev, err)
 	}
+	return c, nil
+}
 
-	patch, err := baseTree.Patch(headTree)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate patch: %w", err)
+// BranchCommit returns the hash of the commit branch points at, preferring
+// its remote-tracking branch on origin.
+func (r *Repository) BranchCommit(branch string) (string, error) {
+	if err := r.open(); err != nil {
+		return "", err
 	}
 
-	return patch.String(), nil
+	c, err := r.resolveBase(branch)
+	if err != nil {
+		return "", err
+	}
+	return c.Hash.String(), nil
 }
 
 func (r *Repository) findMergeBase(commit *object.Commit, targetHash plumbing.Hash) (*object.Commit, error) {
@@ -212,74 +224,62 @@ func (r *Repository) findMergeBase(commit *object.Commit, targetHash plumbing.Ha
 }
 
 func (r *Repository) GetRemoteURL() (string, error) {
+	return r.RemoteURL("origin")
+}
+
+// RemoteURL returns the URL of the named remote.
+func (r *Repository) RemoteURL(name string) (string, error) {
 	if err := r.open(); err != nil {
 		return "", err
 	}
 
-	remote, err := r.repo.Remote("origin")
+	remote, err := r.repo.Remote(name)
 	if err != nil {
 		return "", fmt.Errorf("failed to get remote: %w", err)
 	}
 
+	// The URL as configured, before url.<base>.insteadOf rewrites it to a
+	// mirror or another protocol, names the repository on GitHub
+	cfg, err := r.repo.Config()
+	if err == nil {
+		if configured := cfg.Raw.Section("remote").Subsection(name).Option("url"); configured != "" {
+			return configured, nil
+		}
+	}
+
 	urls := remote.Config().URLs
 	if len(urls) == 0 {
-		return "", fmt.Errorf("no URLs configured for remote origin")
+		return "", fmt.Errorf("no URLs configured for remote %s", name)
 	}
 
 	return urls[0], nil
 }
 
-func (r *Repository) GetChangedFiles() ([]string, error) {
+// Remotes lists the names of the configured remotes, sorted.
+func (r *Repository) Remotes() ([]string, error) {
 	if err := r.open(); err != nil {
 		return nil, err
 	}
 
-	defaultBranch, err := r.DefaultBranch()
-	if err != nil {
-		return nil, err
-	}
-
-	// Get HEAD commit
-	head, err := r.repo.Head()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get HEAD: %w", err)
-	}
-
-	headCommit, err := r.repo.CommitObject(head.Hash())
+	remotes, err := r.repo.Remotes()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get HEAD commit: %w", err)
+		return nil, fmt.Errorf("failed to list remotes: %w", err)
 	}
 
-	// Find merge base
-	var baseCommit *object.Commit
-	
-	// Try origin/defaultBranch first
-	originRef, err := r.repo.Reference(plumbing.NewRemoteReferenceName("origin", defaultBranch), true)
-	if err == nil {
-		baseCommit, err = r.findMergeBase(headCommit, originRef.Hash())
-	}
-	
-	// If that fails, try local defaultBranch
-	if err != nil || baseCommit == nil {
-		localRef, err := r.repo.Reference(plumbing.NewBranchReferenceName(defaultBranch), true)
-		if err != nil {
-			return nil, fmt.Errorf("failed to find reference for %s: %w", defaultBranch, err)
-		}
-		baseCommit, err = r.findMergeBase(headCommit, localRef.Hash())
-		if err != nil {
-			return nil, fmt.Errorf("failed to find merge base: %w", err)
-		}
-	}
-
-	// Get trees
-	baseTree, err := baseCommit.Tree()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get base tree: %w", err)
+	names := make([]string, 0, len(remotes))
+	for _, remote := range remotes {
+		names = append(names, remote.Config().Name)
 	}
+	sort.Strings(names)
+	return names, nil
+}
 
-	headTree, err := headCommit.Tree()
+// GetChangedFiles lists files changed on head since its merge base with base.
+// Refs are resolved as in Diff.
+func (r *Repository) GetChangedFiles(base, head string) ([]string, error) {
+	baseTree, headTree, err := r.diffTrees(base, head)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get head tree: %w", err)
+		return nil, err
 	}
 
 	// Get changes between trees
@@ -303,40 +303,64 @@ func (r *Repository) GetChangedFiles() ([]string, error) {
 	return files, nil
 }
 
-func (r *Repository) PushCurrentBranch() error {
-	if err := r.open(); err != nil {
-		return err
+// PushBranch pushes a local branch to the branch of the same name on origin.
+// It runs git push, so the credential helpers, SSH keys and URL rewrites git
+// is configured with apply. Git fails rather than prompt for credentials.
+func (r *Repository) PushBranch(branch string) error {
+	r.logger.Info("pushing branch", "branch", branch, "remote", "origin")
+	if err := r.push(branchRefSpec(branch), false); err != nil {
+		return fmt.Errorf("failed to push branch: %w", err)
 	}
+	return nil
+}
 
-	currentBranch, err := r.CurrentBranch()
-	if err != nil {
-		return fmt.Errorf("failed to get current branch: %w", err)
+// PushDryRun checks that branch could be pushed to origin as PushBranch
+// pushes it, credentials included, without updating anything.
+func (r *Repository) PushDryRun(branch string) error {
+	if err := r.push(branchRefSpec(branch), true); err != nil {
+		return fmt.Errorf("failed to push branch: %w", err)
 	}
+	return nil
+}
+
+func branchRefSpec(branch string) string {
+	return fmt.Sprintf("refs/heads/%s:refs/heads/%s", branch, branch)
+}
 
-	// Get authentication
-	auth, err := r.getAuth()
+// push runs git push of refSpec to origin, failing with git's output.
+func (r *Repository) push(refSpec string, dryRun bool) error {
+	root, err := r.Root()
 	if err != nil {
-		return fmt.Errorf("failed to get authentication: %w", err)
+		return err
 	}
 
-	// Push to origin
-	refSpec := config.RefSpec(fmt.Sprintf("refs/heads/%s:refs/heads/%s", currentBranch, currentBranch))
-	err = r.repo.Push(&git.PushOptions{
-		RemoteName: "origin",
-		RefSpecs:   []config.RefSpec{refSpec},
-		Auth:       auth,
-		Progress:   nil,
-	})
-
-	if err != nil && err != git.NoErrAlreadyUpToDate {
-		return fmt.Errorf("failed to push branch: %w", err)
+	args := []string{"push", "--porcelain"}
+	if dryRun {
+		args = append(args, "--dry-run")
 	}
+	args = append(args, "origin", refSpec)
+	r.logger.Debug("running git", "args", args, "dir", root)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
 
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return errors.New(strings.TrimSpace(string(out)))
+	}
 	return nil
 }
 
-func (r *Repository) getAuth() (transport.AuthMethod, error) {
-	// For now, rely on system git configuration (SSH keys, etc.)
-	// In a production environment, you might want to support various auth methods
-	return nil, nil
-}
\ No newline at end of file
+// Root returns the top-level directory of the working tree.
+func (r *Repository) Root() (string, error) {
+	if err := r.open(); err != nil {
+		return "", err
+	}
+
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	return wt.Filesystem.Root(), nil
+}
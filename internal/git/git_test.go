@@ -99,6 +99,121 @@ var _ = Describe("Git Repository", func() {
 		})
 	})
 
+	Describe("GetRemoteURL", func() {
+		It("should return origin's URL before insteadOf rewrites", func() {
+			for _, args := range [][]string{
+				{"remote", "add", "origin", "https://github.com/octo/hello.git"},
+				{"config", "url./srv/mirror/.insteadOf", "https://github.com/"},
+			} {
+				cmd := exec.Command("git", args...)
+				cmd.Dir = tmpDir
+				Expect(cmd.Run()).To(Succeed())
+			}
+
+			url, err := repo.GetRemoteURL()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(url).To(Equal("https://github.com/octo/hello.git"))
+		})
+	})
+
+	Describe("PushDryRun", func() {
+		It("should check the push without updating origin", func() {
+			origin := filepath.Join(tmpDir, "origin.git")
+			for _, args := range [][]string{
+				{"init", "-q", "--bare", origin},
+				{"remote", "add", "origin", origin},
+			} {
+				cmd := exec.Command("git", args...)
+				cmd.Dir = tmpDir
+				Expect(cmd.Run()).To(Succeed())
+			}
+
+			branch, err := repo.CurrentBranch()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(repo.PushDryRun(branch)).To(Succeed())
+
+			cmd := exec.Command("git", "branch", "--list")
+			cmd.Dir = origin
+			Expect(cmd.Output()).To(BeEmpty())
+		})
+
+		It("should fail when origin cannot be pushed to", func() {
+			cmd := exec.Command("git", "remote", "add", "origin", filepath.Join(tmpDir, "missing.git"))
+			cmd.Dir = tmpDir
+			Expect(cmd.Run()).To(Succeed())
+
+			branch, err := repo.CurrentBranch()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(repo.PushDryRun(branch)).To(MatchError(ContainSubstring("failed to push branch")))
+		})
+	})
+
+	Describe("PushBranch", func() {
+		It("should push with git, through the URL rewrites it is configured with", func() {
+			origin := filepath.Join(tmpDir, "origin.git")
+			for _, args := range [][]string{
+				{"init", "-q", "--bare", origin},
+				{"remote", "add", "origin", "https://github.com/octo/hello.git"},
+				{"config", "url." + origin + ".insteadOf", "https://github.com/octo/hello.git"},
+			} {
+				cmd := exec.Command("git", args...)
+				cmd.Dir = tmpDir
+				Expect(cmd.Run()).To(Succeed())
+			}
+
+			branch, err := repo.CurrentBranch()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(repo.PushDryRun(branch)).To(Succeed())
+			Expect(repo.PushBranch(branch)).To(Succeed())
+
+			cmd := exec.Command("git", "branch", "--list", branch)
+			cmd.Dir = origin
+			Expect(cmd.Output()).To(ContainSubstring(branch))
+		})
+	})
+
+	Describe("Remotes", func() {
+		It("should list remotes by name", func() {
+			for _, args := range [][]string{
+				{"remote", "add", "origin", "https://github.com/alice/hello.git"},
+				{"remote", "add", "upstream", "https://github.com/octo/hello.git"},
+			} {
+				cmd := exec.Command("git", args...)
+				cmd.Dir = tmpDir
+				Expect(cmd.Run()).To(Succeed())
+			}
+
+			remotes, err := repo.Remotes()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(remotes).To(Equal([]string{"origin", "upstream"}))
+
+			url, err := repo.RemoteURL("upstream")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(url).To(Equal("https://github.com/octo/hello.git"))
+		})
+	})
+
+	Describe("BranchCommit", func() {
+		It("should return the commit a branch points at", func() {
+			branch, err := repo.CurrentBranch()
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := exec.Command("git", "rev-parse", "HEAD")
+			cmd.Dir = tmpDir
+			out, err := cmd.Output()
+			Expect(err).NotTo(HaveOccurred())
+
+			hash, err := repo.BranchCommit(branch)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hash + "\n").To(Equal(string(out)))
+		})
+
+		It("should fail for unknown branches", func() {
+			_, err := repo.BranchCommit("missing")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
 	Describe("GetChangedFiles", func() {
 		Context("when files are changed", func() {
 			BeforeEach(func() {
@@ -123,10 +238,70 @@ var _ = Describe("Git Repository", func() {
 			})
 
 			It("should return the list of changed files", func() {
-				files, err := repo.GetChangedFiles()
+				base, err := repo.DefaultBranch()
+				Expect(err).NotTo(HaveOccurred())
+
+				files, err := repo.GetChangedFiles(base, "HEAD")
 				Expect(err).NotTo(HaveOccurred())
 				Expect(files).To(ContainElement("new-file.txt"))
 			})
 		})
+
+		Context("when diffing against another base", func() {
+			BeforeEach(func() {
+				cmd := exec.Command("git", "checkout", "-b", "develop")
+				cmd.Dir = tmpDir
+				err := cmd.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				err = os.WriteFile(filepath.Join(tmpDir, "develop.txt"), []byte("develop"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+
+				cmd = exec.Command("git", "add", ".")
+				cmd.Dir = tmpDir
+				err = cmd.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				cmd = exec.Command("git", "commit", "-m", "Add develop file")
+				cmd.Dir = tmpDir
+				err = cmd.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				cmd = exec.Command("git", "tag", "v1.0.0")
+				cmd.Dir = tmpDir
+				err = cmd.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				cmd = exec.Command("git", "checkout", "-b", "feature-branch")
+				cmd.Dir = tmpDir
+				err = cmd.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				err = os.WriteFile(filepath.Join(tmpDir, "feature.txt"), []byte("feature"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+
+				cmd = exec.Command("git", "add", ".")
+				cmd.Dir = tmpDir
+				err = cmd.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				cmd = exec.Command("git", "commit", "-m", "Add feature file")
+				cmd.Dir = tmpDir
+				err = cmd.Run()
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should only return files changed since that branch", func() {
+				files, err := repo.GetChangedFiles("develop", "HEAD")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(files).To(ConsistOf("feature.txt"))
+			})
+
+			It("should accept a tag as the base", func() {
+				files, err := repo.GetChangedFiles("v1.0.0", "feature-branch")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(files).To(ConsistOf("feature.txt"))
+			})
+		})
 	})
 })
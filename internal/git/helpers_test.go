@@ -0,0 +1,51 @@
+package git_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// runGit runs git in dir and returns its trimmed output, failing the spec
+// if git fails.
+func runGit(dir string, args ...string) string {
+	GinkgoHelper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	Expect(err).NotTo(HaveOccurred(), string(out))
+	return strings.TrimSpace(string(out))
+}
+
+// initRepo creates a repository on branch with a committer configured, in
+// a directory removed after the spec.
+func initRepo(branch string) string {
+	GinkgoHelper()
+	dir := GinkgoT().TempDir()
+	runGit(dir, "init", "-q", "-b", branch)
+	runGit(dir, "config", "user.email", "test@example.com")
+	runGit(dir, "config", "user.name", "Test User")
+	return dir
+}
+
+// writeFile writes content to name in dir, creating its directories.
+func writeFile(dir, name, content string) {
+	GinkgoHelper()
+	path := filepath.Join(dir, name)
+	Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+	Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+}
+
+// commitFile writes content to name in dir and commits it with message,
+// returning the new commit's hash.
+func commitFile(dir, name, content, message string) string {
+	GinkgoHelper()
+	writeFile(dir, name, content)
+	runGit(dir, "add", ".")
+	runGit(dir, "commit", "-q", "-m", message)
+	return runGit(dir, "rev-parse", "HEAD")
+}
@@ -0,0 +1,58 @@
+package git
+
+import (
+	"fmt"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Commit is the subset of commit data cpr works with.
+type Commit struct {
+	Hash    string
+	Message string
+}
+
+// CommitsBetween returns the commits reachable from to but not from from,
+// newest first, like "git log from..to". From is resolved like a base
+// branch, preferring its origin copy; an empty from lists the whole history
+// of to.
+func (r *Repository) CommitsBetween(from, to string) ([]Commit, error) {
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	toCommit, err := r.resolveCommit(to)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := make(map[plumbing.Hash]bool)
+	if from != "" {
+		fromCommit, err := r.resolveBase(from)
+		if err != nil {
+			return nil, err
+		}
+		err = object.NewCommitPreorderIter(fromCommit, nil, nil).ForEach(func(c *object.Commit) error {
+			excluded[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk %s: %w", from, err)
+		}
+	}
+
+	// Excluded commits prune the walk, so it stops at the range boundary
+	var commits []Commit
+	err = object.NewCommitIterCTime(toCommit, excluded, nil).ForEach(func(c *object.Commit) error {
+		if !excluded[c.Hash] {
+			commits = append(commits, Commit{Hash: c.Hash.String(), Message: c.Message})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", to, err)
+	}
+
+	return commits, nil
+}
@@ -0,0 +1,78 @@
+package git_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/git"
+)
+
+var _ = Describe("CommitsBetween", func() {
+	var (
+		tmpDir string
+		repo   *git.Repository
+	)
+
+	run := func(args ...string) {
+		GinkgoHelper()
+		runGit(tmpDir, args...)
+	}
+
+	BeforeEach(func() {
+		tmpDir = initRepo("main")
+		commitFile(tmpDir, "a.txt", "a.txt", "feat: first")
+		run("tag", "v1.0.0")
+		commitFile(tmpDir, "b.txt", "b.txt", "fix: second")
+		commitFile(tmpDir, "c.txt", "c.txt", "feat: third")
+
+		repo = git.NewRepository(tmpDir)
+	})
+
+	subjects := func(commits []git.Commit) []string {
+		var out []string
+		for _, c := range commits {
+			out = append(out, strings.TrimSpace(c.Message))
+		}
+		return out
+	}
+
+	It("should list commits after a tag, newest first", func() {
+		commits, err := repo.CommitsBetween("v1.0.0", "HEAD")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subjects(commits)).To(Equal([]string{"feat: third", "fix: second"}))
+	})
+
+	It("should list the whole history without a start", func() {
+		commits, err := repo.CommitsBetween("", "HEAD")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(commits).To(HaveLen(3))
+	})
+
+	It("should list tags", func() {
+		tags, err := repo.Tags()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tags).To(ConsistOf("v1.0.0"))
+	})
+
+	It("should list only the tags reachable from a revision", func() {
+		run("tag", "-a", "v1.1.0", "-m", "Release 1.1.0", "HEAD~1")
+		run("checkout", "-q", "-b", "next")
+		run("commit", "-q", "--allow-empty", "-m", "feat!: fourth")
+		run("tag", "v2.0.0")
+		run("checkout", "-q", "-")
+
+		tags, err := repo.ReachableTags("HEAD")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tags).To(ConsistOf("v1.0.0", "v1.1.0"))
+	})
+
+	It("should resolve annotated tags as range boundaries", func() {
+		run("tag", "-a", "v1.1.0", "-m", "Release 1.1.0", "HEAD~1")
+
+		commits, err := repo.CommitsBetween("v1.1.0", "HEAD")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subjects(commits)).To(Equal([]string{"feat: third"}))
+	})
+})
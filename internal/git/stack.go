@@ -0,0 +1,176 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// StackBranch is a branch in a stack together with the branch it is based on.
+type StackBranch struct {
+	Name   string
+	Parent string
+}
+
+// Stack returns the chain of local branches that contains branch, ordered
+// from the one closest to base up to the tip. Parents are derived from
+// ancestry: a branch's parent is the nearest other branch whose tip is an
+// ancestor of it. Branches already merged into base and those listed in
+// exclude are skipped, so their children are based on the next branch down.
+// The chain stops growing upwards where a branch has more than one child.
+func (r *Repository) Stack(base, branch string, exclude ...string) ([]StackBranch, error) {
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	baseCommit, err := r.resolveBase(base)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := make(map[string]bool)
+	for _, name := range exclude {
+		skip[name] = true
+	}
+
+	tips := make(map[string]*object.Commit)
+	iter, err := r.repo.Branches()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().Short()
+		if name == base || (skip[name] && name != branch) {
+			return nil
+		}
+
+		c, err := r.repo.CommitObject(ref.Hash())
+		if err != nil {
+			return err
+		}
+
+		// Merged branches no longer belong to the stack
+		merged, err := c.IsAncestor(baseCommit)
+		if err != nil {
+			return err
+		}
+		if !merged {
+			tips[name] = c
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read branches: %w", err)
+	}
+
+	if _, ok := tips[branch]; !ok {
+		return nil, fmt.Errorf("branch '%s' has no commits ahead of %s", branch, base)
+	}
+
+	parents := make(map[string]string)
+	for name := range tips {
+		parent, err := nearestAncestor(name, tips)
+		if err != nil {
+			return nil, err
+		}
+		if parent == "" {
+			parent = base
+		}
+		parents[name] = parent
+	}
+
+	// Walk down to the bottom of the stack
+	var chain []string
+	for name := branch; name != base; name = parents[name] {
+		chain = append([]string{name}, chain...)
+	}
+
+	// Walk up while there is exactly one child
+	for name := branch; ; {
+		var children []string
+		for child, parent := range parents {
+			if parent == name {
+				children = append(children, child)
+			}
+		}
+		if len(children) != 1 {
+			break
+		}
+		name = children[0]
+		chain = append(chain, name)
+	}
+
+	stack := make([]StackBranch, len(chain))
+	for i, name := range chain {
+		stack[i] = StackBranch{Name: name, Parent: parents[name]}
+	}
+
+	return stack, nil
+}
+
+// nearestAncestor returns the branch whose tip is the closest strict ancestor
+// of the tip of name, or "" if there is none.
+func nearestAncestor(name string, tips map[string]*object.Commit) (string, error) {
+	tip := tips[name]
+
+	var candidates []string
+	for other, c := range tips {
+		if other == name || c.Hash == tip.Hash {
+			continue
+		}
+		ok, err := c.IsAncestor(tip)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			candidates = append(candidates, other)
+		}
+	}
+
+	// The nearest candidate is not an ancestor of any other candidate
+	sort.Strings(candidates)
+	for _, candidate := range candidates {
+		nearest := true
+		for _, other := range candidates {
+			if other == candidate || tips[other].Hash == tips[candidate].Hash {
+				continue
+			}
+			ok, err := tips[candidate].IsAncestor(tips[other])
+			if err != nil {
+				return "", err
+			}
+			if ok {
+				nearest = false
+				break
+			}
+		}
+		if nearest {
+			return candidate, nil
+		}
+	}
+
+	return "", nil
+}
+
+// resolveBase returns the tip of a base branch, preferring its origin copy,
+// or the commit of any other revision such as a tag.
+func (r *Repository) resolveBase(base string) (*object.Commit, error) {
+	ref, err := r.repo.Reference(plumbing.NewRemoteReferenceName("origin", base), true)
+	if err != nil {
+		ref, err = r.repo.Reference(plumbing.NewBranchReferenceName(base), true)
+		if err != nil {
+			if c, revErr := r.resolveCommit(base); revErr == nil {
+				return c, nil
+			}
+			return nil, fmt.Errorf("failed to find reference for %s: %w", base, err)
+		}
+	}
+
+	c, err := r.repo.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit for %s: %w", base, err)
+	}
+	return c, nil
+}
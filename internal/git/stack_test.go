@@ -0,0 +1,57 @@
+package git_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/git"
+)
+
+var _ = Describe("Stack", func() {
+	var (
+		tmpDir string
+		repo   *git.Repository
+	)
+
+	commit := func(name string) {
+		GinkgoHelper()
+		commitFile(tmpDir, name, name, "Add "+name)
+	}
+
+	BeforeEach(func() {
+		tmpDir = initRepo("main")
+		commit("base.txt")
+
+		runGit(tmpDir, "checkout", "-q", "-b", "feat-a")
+		commit("a.txt")
+		runGit(tmpDir, "checkout", "-q", "-b", "feat-b")
+		commit("b.txt")
+		runGit(tmpDir, "checkout", "-q", "-b", "feat-c")
+		commit("c.txt")
+		runGit(tmpDir, "checkout", "-q", "feat-b")
+
+		repo = git.NewRepository(tmpDir)
+	})
+
+	It("should order the whole stack from the bottom up", func() {
+		stack, err := repo.Stack("main", "feat-b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stack).To(Equal([]git.StackBranch{
+			{Name: "feat-a", Parent: "main"},
+			{Name: "feat-b", Parent: "feat-a"},
+			{Name: "feat-c", Parent: "feat-b"},
+		}))
+	})
+
+	It("should rebase children of excluded branches onto the next branch down", func() {
+		stack, err := repo.Stack("main", "feat-b", "feat-a")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stack[0]).To(Equal(git.StackBranch{Name: "feat-b", Parent: "main"}))
+	})
+
+	It("should diff a branch against its parent", func() {
+		files, err := repo.GetChangedFiles("feat-a", "feat-c")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(ConsistOf("b.txt", "c.txt"))
+	})
+})
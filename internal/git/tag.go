@@ -0,0 +1,99 @@
+package git
+
+import (
+	"fmt"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Tags returns the names of all tags in the repository.
+func (r *Repository) Tags() ([]string, error) {
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	iter, err := r.repo.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	var tags []string
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		tags = append(tags, ref.Name().Short())
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+// ReachableTags returns the names of the tags on rev or its ancestors, so
+// the releases of the branch rev is on, not those of other branches.
+func (r *Repository) ReachableTags(rev string) ([]string, error) {
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	start, err := r.resolveCommit(rev)
+	if err != nil {
+		return nil, err
+	}
+	reachable := make(map[plumbing.Hash]bool)
+	err = object.NewCommitPreorderIter(start, nil, nil).ForEach(func(c *object.Commit) error {
+		reachable[c.Hash] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", rev, err)
+	}
+
+	iter, err := r.repo.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	var tags []string
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		hash := ref.Hash()
+		// Annotated tags point at a tag object rather than the commit
+		if tag, err := r.repo.TagObject(hash); err == nil {
+			hash = tag.Target
+		}
+		if reachable[hash] {
+			tags = append(tags, ref.Name().Short())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+	return tags, nil
+}
+
+// CreateTag creates a lightweight tag pointing at HEAD.
+func (r *Repository) CreateTag(name string) error {
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	head, err := r.repo.Head()
+	if err != nil {
+		return fmt.Errorf("failed to get HEAD: %w", err)
+	}
+
+	if _, err := r.repo.CreateTag(name, head.Hash(), nil); err != nil {
+		return fmt.Errorf("failed to create tag %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// PushTag pushes a tag to origin, as PushBranch pushes branches.
+func (r *Repository) PushTag(name string) error {
+	if err := r.push(fmt.Sprintf("refs/tags/%s:refs/tags/%s", name, name), false); err != nil {
+		return fmt.Errorf("failed to push tag: %w", err)
+	}
+	return nil
+}
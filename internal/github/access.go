@@ -0,0 +1,185 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// Token kinds, as far as the API tells them apart.
+const (
+	// TokenClassic is a classic personal access or OAuth token, which has
+	// scopes.
+	TokenClassic = "classic"
+	// TokenFineGrained covers fine-grained personal access tokens, GitHub
+	// App tokens and the GITHUB_TOKEN of Actions, which have permissions.
+	TokenFineGrained = "fine-grained"
+)
+
+// AccessError reports a token that lacks a scope or permission cpr needs
+// on a repository.
+type AccessError struct {
+	Repo string
+	// Missing is the scope or permission to grant, e.g. "repo scope" or
+	// "Pull requests: write permission".
+	Missing string
+	// Detail explains how GitHub told, e.g. the scopes the token has.
+	Detail string
+}
+
+func (e *AccessError) Error() string {
+	msg := fmt.Sprintf("token lacks the %s needed to open pull requests on %s", e.Missing, e.Repo)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+// RepositoryAccess is what the client's token may do on a repository.
+type RepositoryAccess struct {
+	Repo      string
+	Private   bool
+	TokenKind string
+	// Scopes are a classic token's scopes, from X-OAuth-Scopes.
+	Scopes []string
+	// Permissions are the user's or app's permissions on the repository,
+	// such as "push". A fine-grained token may be granted less.
+	Permissions map[string]bool
+	// PullRequestsWritable is set when the token is known to be allowed to
+	// open pull requests. Only classic tokens tell beforehand.
+	PullRequestsWritable bool
+}
+
+// CheckPullRequestAccess confirms, as far as GitHub tells without changing
+// anything, that the token may open and edit pull requests on owner/repo,
+// and returns an *AccessError naming the scope or permission it lacks
+// otherwise, so that cpr can fail before pushing.
+//
+// Only classic tokens can be checked, by their scopes. Fine-grained tokens
+// have no way to list their permissions, so for them PullRequestsWritable
+// stays false: a token without Pull requests: write is only found out when
+// creating the pull request, which then fails with an *AccessError as well.
+func (c *Client) CheckPullRequestAccess(ctx context.Context, owner, repo string) (*RepositoryAccess, error) {
+	access, err := c.RepositoryAccess(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	if access.TokenKind == TokenClassic {
+		scope := "public_repo"
+		if access.Private {
+			scope = "repo"
+		}
+		if !slices.Contains(access.Scopes, "repo") && !slices.Contains(access.Scopes, scope) {
+			return access, &AccessError{Repo: access.Repo, Missing: scope + " scope", Detail: "its scopes are " + FormatScopes(access.Scopes)}
+		}
+		access.PullRequestsWritable = true
+	}
+	return access, nil
+}
+
+// RepositoryAccess reads the token's scopes and the repository's
+// permissions. A repository the token cannot see is an *AccessError.
+func (c *Client) RepositoryAccess(ctx context.Context, owner, repo string) (*RepositoryAccess, error) {
+	fullName := owner + "/" + repo
+	r, resp, err := c.client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		if isStatus(err, http.StatusNotFound) {
+			// GitHub hides repositories a token may not read
+			return nil, &AccessError{Repo: fullName, Missing: "access to the repository",
+				Detail: "GitHub answers 404, a fine-grained token needs the repository selected and a classic token the repo scope for private repositories"}
+		}
+		return nil, apiError(ctx, "get repository", err)
+	}
+
+	access := &RepositoryAccess{
+		Repo:        fullName,
+		Private:     r.GetPrivate(),
+		TokenKind:   TokenFineGrained,
+		Permissions: r.GetPermissions(),
+	}
+	// Classic tokens get the header even without scopes
+	if header, ok := resp.Header[http.CanonicalHeaderKey("X-OAuth-Scopes")]; ok {
+		access.TokenKind = TokenClassic
+		access.Scopes = parseScopes(strings.Join(header, ","))
+	}
+	return access, nil
+}
+
+// pullRequestAccessError returns an *AccessError for GitHub refusing with
+// 403 to change a pull request on owner/repo, naming the permissions it
+// asks for in X-Accepted-GitHub-Permissions, or nil for other errors.
+// Rate limits are not *github.ErrorResponse and stay as they are.
+func pullRequestAccessError(owner, repo string, err error) error {
+	var errResp *github.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil || errResp.Response.StatusCode != http.StatusForbidden {
+		return nil
+	}
+	missing := acceptedPermissions(errResp.Response.Header.Get("X-Accepted-GitHub-Permissions"))
+	if missing == "" {
+		missing = "Pull requests: write permission"
+	}
+	return &AccessError{Repo: owner + "/" + repo, Missing: missing,
+		Detail: "GitHub refuses to change pull requests with it: " + errorMessage(err)}
+}
+
+// acceptedPermissions names the permissions in an X-Accepted-GitHub-
+// Permissions header as GitHub's settings do, e.g. "pull_requests=write" as
+// "Pull requests: write permission". Of alternative sets, separated by ";",
+// the first is named.
+func acceptedPermissions(header string) string {
+	set, _, _ := strings.Cut(header, ";")
+	var names []string
+	for _, permission := range strings.Split(set, ",") {
+		name, level, ok := strings.Cut(strings.TrimSpace(permission), "=")
+		if !ok || name == "" {
+			continue
+		}
+		name = strings.ReplaceAll(name, "_", " ")
+		names = append(names, strings.ToUpper(name[:1])+name[1:]+": "+level)
+	}
+	switch len(names) {
+	case 0:
+		return ""
+	case 1:
+		return names[0] + " permission"
+	}
+	return strings.Join(names, " and ") + " permissions"
+}
+
+func isStatus(err error, status int) bool {
+	var errResp *github.ErrorResponse
+	return errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == status
+}
+
+func errorMessage(err error) string {
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Message != "" {
+		return errResp.Message
+	}
+	return err.Error()
+}
+
+func parseScopes(header string) []string {
+	var scopes []string
+	for _, scope := range strings.Split(header, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// FormatScopes lists a classic token's scopes for people, "none" if it has
+// none.
+func FormatScopes(scopes []string) string {
+	if len(scopes) == 0 {
+		return "none"
+	}
+	return strings.Join(scopes, ", ")
+}
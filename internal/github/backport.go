@@ -0,0 +1,67 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+)
+
+var backportSuffixPattern = regexp.MustCompile(`\s*\[backport [^\]]*\]$`)
+
+// GetPullRequest fetches a single pull request by number.
+func (c *Client) GetPullRequest(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+	pr, _, err := c.client.PullRequests.Get(ctx, owner, repo, number)
+	if err != nil {
+		return nil, apiError(ctx, "get pull request", err)
+	}
+	return pr, nil
+}
+
+// GetPullRequestCommits returns the SHAs of a pull request's commits, oldest
+// first. Merge commits are left out: merging the base branch into the pull
+// request brings in changes that are not the pull request's own.
+func (c *Client) GetPullRequestCommits(ctx context.Context, owner, repo string, number int) ([]string, error) {
+	opts := &github.ListOptions{PerPage: 100}
+	var shas []string
+	for {
+		commits, resp, err := c.client.PullRequests.ListCommits(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, apiError(ctx, "list pull request commits", err)
+		}
+		for _, commit := range commits {
+			if len(commit.Parents) > 1 {
+				continue
+			}
+			shas = append(shas, commit.GetSHA())
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return shas, nil
+}
+
+// AddLabels adds labels to a pull request.
+func (c *Client) AddLabels(ctx context.Context, owner, repo string, number int, labels ...string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+
+	if _, _, err := c.client.Issues.AddLabelsToIssue(withIdempotent(ctx), owner, repo, number, labels); err != nil {
+		return apiError(ctx, "add labels", err)
+	}
+	return nil
+}
+
+// BackportTitle marks a title as a backport to target, using the version part
+// of release branches, e.g. "fix: x" onto release/1.2 becomes
+// "fix: x [backport 1.2]".
+func BackportTitle(title, target string) string {
+	title = backportSuffixPattern.ReplaceAllString(title, "")
+	version := strings.TrimPrefix(target, "release/")
+	return fmt.Sprintf("%s [backport %s]", title, version)
+}
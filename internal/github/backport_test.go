@@ -0,0 +1,24 @@
+package github_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/github"
+)
+
+var _ = Describe("BackportTitle", func() {
+	It("should use the version of release branches", func() {
+		Expect(github.BackportTitle("fix(api): handle timeouts", "release/1.2")).
+			To(Equal("fix(api): handle timeouts [backport 1.2]"))
+	})
+
+	It("should use other branch names as they are", func() {
+		Expect(github.BackportTitle("fix: typo", "stable")).To(Equal("fix: typo [backport stable]"))
+	})
+
+	It("should replace an existing backport marker", func() {
+		Expect(github.BackportTitle("fix: typo [backport 1.3]", "release/1.2")).
+			To(Equal("fix: typo [backport 1.2]"))
+	})
+})
@@ -3,78 +3,163 @@ package github
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"net/url"
-	"os"
-	"os/exec"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/v66/github"
 	"golang.org/x/oauth2"
 )
 
+// DefaultRequestTimeout bounds a single API request unless overridden
+// with WithRequestTimeout.
+const DefaultRequestTimeout = 30 * time.Second
+
 type Client struct {
-	client *github.Client
-	ctx    context.Context
+	client    *github.Client
+	transport *RetryTransport
+}
+
+// Option configures a Client.
+type Option func(*RetryTransport)
+
+// WithRequestTimeout bounds every attempt at an API request, including
+// reading its response. Zero means no limit beyond the caller's context.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(t *RetryTransport) {
+		t.RequestTimeout = timeout
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(t *RetryTransport) {
+		t.Policy = policy
+	}
 }
 
-func NewClient(token string) *Client {
-	ctx := context.Background()
+// WithRetryHook calls hook before every retry, e.g. to report it.
+func WithRetryHook(hook RetryHook) Option {
+	return func(t *RetryTransport) {
+		t.OnRetry = hook
+	}
+}
+
+// WithLogger logs API requests and what the client does about failures to
+// logger; at logging.LevelTrace requests and responses are logged in full.
+func WithLogger(logger *slog.Logger) Option {
+	return func(t *RetryTransport) {
+		t.Logger = logger
+	}
+}
+
+func NewClient(token string, opts ...Option) *Client {
+	transport := &RetryTransport{
+		Base:           http.DefaultTransport,
+		Policy:         DefaultRetryPolicy,
+		RequestTimeout: DefaultRequestTimeout,
+	}
+	for _, opt := range opts {
+		opt(transport)
+	}
+
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
 	)
-	tc := oauth2.NewClient(ctx, ts)
+	tc := &http.Client{Transport: &oauth2.Transport{Source: ts, Base: transport}}
 
 	return &Client{
-		client: github.NewClient(tc),
-		ctx:    ctx,
+		client:    github.NewClient(tc),
+		transport: transport,
+	}
+}
+
+// NewClientWithBaseURL returns a client for the REST API at baseURL, such
+// as https://ghe.example.com/api/v3/ for GitHub Enterprise Server or a fake
+// server in tests. GraphQL requests go to the graphql endpoint beside it.
+func NewClientWithBaseURL(baseURL, token string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse API URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid API URL %q: must be http or https", baseURL)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	c := NewClient(token, opts...)
+	c.client.BaseURL = u
+	return c, nil
+}
+
+// RateLimit returns the rate limit quota GitHub reported last, or false
+// before any response reported one.
+func (c *Client) RateLimit() (github.Rate, bool) {
+	return c.transport.Rate()
+}
+
+func (c *Client) logger() *slog.Logger {
+	return c.transport.logger()
+}
+
+// DefaultBranch returns the repository's default branch.
+func (c *Client) DefaultBranch(ctx context.Context, owner, repo string) (string, error) {
+	r, _, err := c.client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		return "", apiError(ctx, "get repository", err)
 	}
+	return r.GetDefaultBranch(), nil
 }
 
-func (c *Client) CreateOrUpdatePullRequest(owner, repo, title, body, head, base string, draft bool) (*github.PullRequest, bool, error) {
+func (c *Client) CreateOrUpdatePullRequest(ctx context.Context, owner, repo, title, body, head, base string, draft bool) (*github.PullRequest, bool, error) {
 	// First, check if a PR already exists for this branch
-	existingPR, err := c.GetPullRequestForBranch(owner, repo, head)
+	existingPR, err := c.GetPullRequestForBranch(ctx, owner, repo, head)
 	if err != nil {
 		return nil, false, err
 	}
 
 	if existingPR != nil {
+		// Retarget the existing PR if the requested base changed
+		newBase := ""
+		if existingPR.GetBase().GetRef() != base {
+			newBase = base
+		}
+
 		// Update existing PR
-		updatedPR, err := c.UpdatePullRequest(owner, repo, existingPR.GetNumber(), title, body)
+		updatedPR, err := c.UpdatePullRequest(ctx, owner, repo, existingPR.GetNumber(), title, body, newBase)
 		if err != nil {
-			return nil, false, fmt.Errorf("failed to update pull request: %w", err)
+			return nil, false, err
 		}
 		return updatedPR, true, nil
 	}
 
-	// Create new PR
-	pr := &github.NewPullRequest{
-		Title: github.String(title),
-		Head:  github.String(head),
-		Base:  github.String(base),
-		Body:  github.String(body),
-		Draft: github.Bool(draft),
-	}
-
-	pullRequest, _, err := c.client.PullRequests.Create(c.ctx, owner, repo, pr)
+	pullRequest, err := c.CreatePullRequest(ctx, owner, repo, title, body, head, base, draft)
 	if err != nil {
-		return nil, false, fmt.Errorf("failed to create pull request: %w", err)
+		return nil, false, err
 	}
 
 	return pullRequest, false, nil
 }
 
-func (c *Client) GetPullRequestForBranch(owner, repo, branch string) (*github.PullRequest, error) {
+// GetPullRequestForBranch returns the newest open pull request from branch,
+// given as owner:branch for a branch in a fork, or nil if there is none.
+func (c *Client) GetPullRequestForBranch(ctx context.Context, owner, repo, branch string) (*github.PullRequest, error) {
 	opts := &github.PullRequestListOptions{
-		Head:  owner + ":" + branch,
+		Head:  HeadLabel(owner, branch),
 		State: "open",
 		ListOptions: github.ListOptions{
 			PerPage: 100,
 		},
 	}
 
-	pulls, _, err := c.client.PullRequests.List(c.ctx, owner, repo, opts)
+	pulls, _, err := c.client.PullRequests.List(ctx, owner, repo, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list pull requests: %w", err)
+		return nil, apiError(ctx, "list pull requests", err)
 	}
 
 	if len(pulls) > 0 {
@@ -84,194 +169,237 @@ func (c *Client) GetPullRequestForBranch(owner, repo, branch string) (*github.Pu
 	return nil, nil
 }
 
-func (c *Client) UpdatePullRequest(owner, repo string, number int, title, body string) (*github.PullRequest, error) {
-	update := &github.PullRequest{
-		Title: github.String(title),
-		Body:  github.String(body),
-	}
-
-	pr, _, err := c.client.PullRequests.Edit(c.ctx, owner, repo, number, update)
-	if err != nil {
-		return nil, fmt.Errorf("failed to update pull request: %w", err)
+// FindPullRequests returns the pull requests in any state opened from any
+// of heads, each a branch or owner:branch for a branch in a fork, newest
+// first.
+func (c *Client) FindPullRequests(ctx context.Context, owner, repo string, heads ...string) ([]*github.PullRequest, error) {
+	seen := make(map[int]bool)
+	var found []*github.PullRequest
+	for _, head := range heads {
+		opts := &github.PullRequestListOptions{
+			Head:  HeadLabel(owner, head),
+			State: "all",
+			ListOptions: github.ListOptions{
+				PerPage: 100,
+			},
+		}
+		for {
+			pulls, resp, err := c.client.PullRequests.List(ctx, owner, repo, opts)
+			if err != nil {
+				return nil, apiError(ctx, "list pull requests", err)
+			}
+			for _, pr := range pulls {
+				if !seen[pr.GetNumber()] {
+					seen[pr.GetNumber()] = true
+					found = append(found, pr)
+				}
+			}
+			if resp.NextPage == 0 {
+				break
+			}
+			opts.Page = resp.NextPage
+		}
 	}
 
-	return pr, nil
+	sort.Slice(found, func(i, j int) bool { return found[i].GetNumber() > found[j].GetNumber() })
+	c.logger().DebugContext(ctx, "found pull requests", "repo", owner+"/"+repo, "heads", heads, "count", len(found))
+	return found, nil
 }
 
-func (c *Client) GetPullRequestTemplate(owner, repo string) (string, error) {
-	// Common PR template locations
-	templatePaths := []string{
-		".github/pull_request_template.md",
-		".github/PULL_REQUEST_TEMPLATE.md",
-		"pull_request_template.md",
-		"PULL_REQUEST_TEMPLATE.md",
-		".github/PULL_REQUEST_TEMPLATE/pull_request_template.md",
-		"docs/pull_request_template.md",
-		"docs/PULL_REQUEST_TEMPLATE.md",
-	}
-
-	for _, path := range templatePaths {
-		content, _, _, err := c.client.Repositories.GetContents(c.ctx, owner, repo, path, &github.RepositoryContentGetOptions{})
-		if err == nil && content != nil {
-			decoded, err := content.GetContent()
-			if err == nil {
-				return decoded, nil
-			}
+// ReopenPullRequest reopens a closed pull request. GitHub refuses to reopen
+// merged ones and ones whose head branch is gone.
+func (c *Client) ReopenPullRequest(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+	update := &github.PullRequest{State: github.String("open")}
+	pr, _, err := c.client.PullRequests.Edit(withIdempotent(ctx), owner, repo, number, update)
+	if err != nil {
+		if accessErr := pullRequestAccessError(owner, repo, err); accessErr != nil {
+			return nil, accessErr
 		}
+		return nil, apiError(ctx, "reopen pull request", err)
 	}
+	c.logger().InfoContext(ctx, "reopened pull request", "number", number)
+	return pr, nil
+}
 
-	// Check for multiple templates in .github/PULL_REQUEST_TEMPLATE/
-	_, dirs, _, err := c.client.Repositories.GetContents(c.ctx, owner, repo, ".github/PULL_REQUEST_TEMPLATE", &github.RepositoryContentGetOptions{})
-	if err == nil && len(dirs) > 0 {
-		// If multiple templates exist, use the first one
-		content, _, _, err := c.client.Repositories.GetContents(c.ctx, owner, repo, dirs[0].GetPath(), &github.RepositoryContentGetOptions{})
-		if err == nil && content != nil {
-			decoded, err := content.GetContent()
-			if err == nil {
-				return decoded, nil
-			}
-		}
+// HeadLabel returns head as owner:branch, the form the API filters heads
+// by. Heads in forks already carry their owner.
+func HeadLabel(owner, head string) string {
+	if strings.Contains(head, ":") {
+		return head
 	}
-
-	return "", nil
+	return owner + ":" + head
 }
 
-func (c *Client) CreatePullRequest(owner, repo, title, body, head, base string, draft bool) (*github.PullRequest, error) {
-	pr := &github.NewPullRequest{
+// UpdatePullRequest edits the title and body of a pull request. A non-empty
+// base also retargets it onto that branch.
+func (c *Client) UpdatePullRequest(ctx context.Context, owner, repo string, number int, title, body, base string) (*github.PullRequest, error) {
+	update := &github.PullRequest{
 		Title: github.String(title),
-		Head:  github.String(head),
-		Base:  github.String(base),
 		Body:  github.String(body),
-		Draft: github.Bool(draft),
+	}
+	if base != "" {
+		update.Base = &github.PullRequestBranch{Ref: github.String(base)}
 	}
 
-	pullRequest, _, err := c.client.PullRequests.Create(c.ctx, owner, repo, pr)
+	// Setting the same title, body and base again does no harm
+	pr, _, err := c.client.PullRequests.Edit(withIdempotent(ctx), owner, repo, number, update)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create pull request: %w", err)
+		if accessErr := pullRequestAccessError(owner, repo, err); accessErr != nil {
+			return nil, accessErr
+		}
+		return nil, apiError(ctx, "update pull request", err)
 	}
 
-	return pullRequest, nil
+	return pr, nil
 }
 
-func GetToken() (string, error) {
-	token := os.Getenv("GITHUB_TOKEN")
-	if token != "" {
-		return token, nil
+// IsBranchMerged reports whether a pull request from branch, given as
+// owner:branch for a branch in a fork, has been merged with sha as its head.
+// A merged pull request from an older branch of the same name doesn't count.
+func (c *Client) IsBranchMerged(ctx context.Context, owner, repo, branch, sha string) (bool, error) {
+	opts := &github.PullRequestListOptions{
+		Head:  HeadLabel(owner, branch),
+		State: "closed",
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
 	}
 
-	token = os.Getenv("GH_TOKEN")
-	if token != "" {
-		return token, nil
+	pulls, _, err := c.client.PullRequests.List(ctx, owner, repo, opts)
+	if err != nil {
+		return false, apiError(ctx, "list pull requests", err)
 	}
 
-	cmd := exec.Command("gh", "auth", "status")
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("not authenticated. Please run 'gh auth login' or set GITHUB_TOKEN environment variable")
+	for _, pr := range pulls {
+		if pr.MergedAt != nil && pr.GetHead().GetSHA() == sha {
+			return true, nil
+		}
 	}
 
-	cmd = exec.Command("gh", "auth", "token")
-	output, err := cmd.Output()
+	return false, nil
+}
+
+// GetMergedPullRequestsForCommit returns the merged pull requests that
+// contain a commit.
+func (c *Client) GetMergedPullRequestsForCommit(ctx context.Context, owner, repo, sha string) ([]*github.PullRequest, error) {
+	pulls, _, err := c.client.PullRequests.ListPullRequestsWithCommit(ctx, owner, repo, sha, &github.ListOptions{PerPage: 100})
 	if err != nil {
-		return "", fmt.Errorf("failed to get token from gh CLI: %w", err)
+		return nil, apiError(ctx, "list pull requests for commit", err)
 	}
 
-	token = strings.TrimSpace(string(output))
-	if token == "" {
-		return "", fmt.Errorf("gh CLI returned empty token")
+	var merged []*github.PullRequest
+	for _, pr := range pulls {
+		if pr.MergedAt != nil {
+			merged = append(merged, pr)
+		}
 	}
-
-	return token, nil
+	return merged, nil
 }
 
-func ParseGitRemoteURL(remoteURL string) (owner string, repo string, err error) {
-	remoteURL = strings.TrimSpace(remoteURL)
+// CreatePullRequest opens a pull request from head onto base. A create that
+// failed in transit may still have gone through, so before trying again it
+// looks for the pull request it might have opened.
+func (c *Client) CreatePullRequest(ctx context.Context, owner, repo, title, body, head, base string, draft bool) (*github.PullRequest, error) {
+	pr := &github.NewPullRequest{
+		Title: github.String(title),
+		Head:  github.String(head),
+		Base:  github.String(base),
+		Body:  github.String(body),
+		Draft: github.Bool(draft),
+	}
+
+	policy := c.transport.Policy
+	for attempt := 1; ; attempt++ {
+		pullRequest, _, err := c.client.PullRequests.Create(ctx, owner, repo, pr)
+		if err == nil {
+			return pullRequest, nil
+		}
+		if accessErr := pullRequestAccessError(owner, repo, err); accessErr != nil {
+			return nil, accessErr
+		}
+		if ctx.Err() != nil || !retryableError(err) && !alreadyExists(err) {
+			return nil, apiError(ctx, "create pull request", err)
+		}
 
-	if strings.HasPrefix(remoteURL, "git@github.com:") {
-		path := strings.TrimPrefix(remoteURL, "git@github.com:")
-		path = strings.TrimSuffix(path, ".git")
-		parts := strings.Split(path, "/")
-		if len(parts) != 2 {
-			return "", "", fmt.Errorf("invalid SSH remote URL format")
+		existing, lookupErr := c.GetPullRequestForBranch(ctx, owner, repo, head)
+		if lookupErr == nil && existing != nil && existing.GetBase().GetRef() == base {
+			c.logger().InfoContext(ctx, "found pull request opened by a failed create", "number", existing.GetNumber(), "err", err)
+			return existing, nil
+		}
+		if alreadyExists(err) || attempt > policy.MaxRetries {
+			return nil, apiError(ctx, "create pull request", err)
+		}
+
+		wait := policy.Backoff(attempt)
+		c.logger().WarnContext(ctx, "retrying create pull request", "attempt", attempt, "wait", wait.Round(time.Millisecond), "reason", err.Error())
+		if c.transport.OnRetry != nil {
+			c.transport.OnRetry("create pull request", attempt, wait, err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return nil, apiError(ctx, "create pull request", ctx.Err())
+		case <-time.After(wait):
 		}
-		return parts[0], parts[1], nil
 	}
+}
+
+// ParseGitRemoteURL returns the owner and name of the repository a git
+// remote URL points at, given as https://, ssh:// or scp-like
+// user@host:owner/repo on any host.
+func ParseGitRemoteURL(remoteURL string) (owner string, repo string, err error) {
+	remoteURL = strings.TrimSpace(remoteURL)
 
-	if strings.HasPrefix(remoteURL, "https://") || strings.HasPrefix(remoteURL, "http://") {
+	var path string
+	if strings.Contains(remoteURL, "://") {
 		u, err := url.Parse(remoteURL)
 		if err != nil {
 			return "", "", fmt.Errorf("failed to parse remote URL: %w", err)
 		}
-
-		path := strings.TrimPrefix(u.Path, "/")
-		path = strings.TrimSuffix(path, ".git")
-		parts := strings.Split(path, "/")
-		if len(parts) != 2 {
-			return "", "", fmt.Errorf("invalid HTTPS remote URL format")
+		switch u.Scheme {
+		case "https", "http", "ssh", "git+ssh", "git":
+		default:
+			return "", "", fmt.Errorf("unsupported remote URL scheme %q", u.Scheme)
+		}
+		if u.Hostname() == "" {
+			return "", "", fmt.Errorf("no host in remote URL %q", remoteURL)
+		}
+		path = u.Path
+	} else {
+		// scp-like syntax, e.g. git@github.com:owner/repo.git
+		host, rest, ok := strings.Cut(remoteURL, ":")
+		if !ok || host == "" || strings.Contains(host, "/") {
+			return "", "", fmt.Errorf("unsupported remote URL format")
 		}
-		return parts[0], parts[1], nil
+		path = rest
 	}
 
-	return "", "", fmt.Errorf("unsupported remote URL format")
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("remote URL %q does not name an <owner>/<repo> repository", remoteURL)
+	}
+	return parts[0], parts[1], nil
 }
 
-// ApplyTemplate fills in a PR template with the provided content
-func ApplyTemplate(template, title, summary string) string {
-	// If no template, just return the summary
-	if template == "" {
-		return summary
-	}
-
-	// Replace common template placeholders
-	result := template
-	
-	// Replace title placeholders
-	result = strings.ReplaceAll(result, "{{title}}", title)
-	result = strings.ReplaceAll(result, "{{TITLE}}", title)
-	result = strings.ReplaceAll(result, "[Title]", title)
-	result = strings.ReplaceAll(result, "[TITLE]", title)
-	
-	// Replace description/summary placeholders
-	result = strings.ReplaceAll(result, "{{description}}", summary)
-	result = strings.ReplaceAll(result, "{{DESCRIPTION}}", summary)
-	result = strings.ReplaceAll(result, "{{summary}}", summary)
-	result = strings.ReplaceAll(result, "{{SUMMARY}}", summary)
-	result = strings.ReplaceAll(result, "[Description]", summary)
-	result = strings.ReplaceAll(result, "[DESCRIPTION]", summary)
-	result = strings.ReplaceAll(result, "[Summary]", summary)
-	result = strings.ReplaceAll(result, "[SUMMARY]", summary)
-
-	// Look for sections to fill in
-	// Common patterns: ## Summary, ## Description, ## What, ## Why, ## Changes
-	patterns := []string{
-		"## Summary",
-		"## Description", 
-		"## What",
-		"## Changes",
-		"### Summary",
-		"### Description",
-		"### What",
-		"### Changes",
-	}
-
-	for _, pattern := range patterns {
-		idx := strings.Index(result, pattern)
-		if idx >= 0 {
-			// Find the next section header or end of string
-			endIdx := len(result)
-			for _, p := range []string{"##", "###", "---"} {
-				if next := strings.Index(result[idx+len(pattern):], p); next >= 0 {
-					endIdx = idx + len(pattern) + next
-					break
-				}
-			}
-			
-			// Insert the summary after the header
-			before := result[:idx+len(pattern)]
-			after := result[endIdx:]
-			result = before + "\n\n" + summary + "\n" + after
-			break // Only fill the first matching section
+// RemoteHost returns the host of a git remote URL, given as https://,
+// ssh:// or scp-like user@host:path.
+func RemoteHost(remoteURL string) (string, error) {
+	remoteURL = strings.TrimSpace(remoteURL)
+	if strings.Contains(remoteURL, "://") {
+		u, err := url.Parse(remoteURL)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse remote URL: %w", err)
+		}
+		if u.Hostname() == "" {
+			return "", fmt.Errorf("no host in remote URL %q", remoteURL)
 		}
+		return u.Hostname(), nil
 	}
 
-	return result
-}
\ No newline at end of file
+	if at := strings.Index(remoteURL, "@"); at >= 0 {
+		if host, _, ok := strings.Cut(remoteURL[at+1:], ":"); ok && host != "" {
+			return host, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported remote URL format")
+}
@@ -1,8 +1,6 @@
 package github_test
 
 import (
-	"os"
-
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
@@ -19,6 +17,20 @@ var _ = Describe("GitHub Client", func() {
 				Expect(repo).To(Equal("Hello-World"))
 			})
 
+			It("should parse SSH URLs on any host", func() {
+				for _, remote := range []string{
+					"git@ghe.corp:octocat/Hello-World.git",
+					"ssh://git@ghe.corp:2222/octocat/Hello-World.git",
+					"ssh://github.com/octocat/Hello-World",
+					"ghe.corp:octocat/Hello-World.git",
+				} {
+					owner, repo, err := github.ParseGitRemoteURL(remote)
+					Expect(err).NotTo(HaveOccurred(), remote)
+					Expect(owner).To(Equal("octocat"), remote)
+					Expect(repo).To(Equal("Hello-World"), remote)
+				}
+			})
+
 			It("should parse SSH URL without .git extension", func() {
 				owner, repo, err := github.ParseGitRemoteURL("git@github.com:octocat/Hello-World")
 				Expect(err).NotTo(HaveOccurred())
@@ -52,8 +64,10 @@ var _ = Describe("GitHub Client", func() {
 
 		Context("with invalid URLs", func() {
 			It("should return error for invalid format", func() {
-				_, _, err := github.ParseGitRemoteURL("not-a-valid-url")
-				Expect(err).To(HaveOccurred())
+				for _, remote := range []string{"not-a-valid-url", "/srv/git/hello.git", "file:///srv/git/o/r.git", "git@github.com:hello.git"} {
+					_, _, err := github.ParseGitRemoteURL(remote)
+					Expect(err).To(HaveOccurred(), remote)
+				}
 			})
 
 			It("should parse non-GitHub URLs", func() {
@@ -65,50 +79,16 @@ var _ = Describe("GitHub Client", func() {
 		})
 	})
 
-	Describe("GetToken", func() {
-		var originalGitHubToken string
-		var originalGHToken string
-
-		BeforeEach(func() {
-			originalGitHubToken = os.Getenv("GITHUB_TOKEN")
-			originalGHToken = os.Getenv("GH_TOKEN")
-			os.Unsetenv("GITHUB_TOKEN")
-			os.Unsetenv("GH_TOKEN")
+	Describe("RemoteHost", func() {
+		It("should return the host of HTTPS, SSH and scp-like URLs", func() {
+			Expect(github.RemoteHost("https://github.com/octo/hello.git")).To(Equal("github.com"))
+			Expect(github.RemoteHost("ssh://git@ghe.example.com:2222/octo/hello.git")).To(Equal("ghe.example.com"))
+			Expect(github.RemoteHost("git@ghe.example.com:octo/hello.git")).To(Equal("ghe.example.com"))
 		})
 
-		AfterEach(func() {
-			if originalGitHubToken != "" {
-				os.Setenv("GITHUB_TOKEN", originalGitHubToken)
-			}
-			if originalGHToken != "" {
-				os.Setenv("GH_TOKEN", originalGHToken)
-			}
-		})
-
-		Context("when GITHUB_TOKEN is set", func() {
-			It("should return the token", func() {
-				os.Setenv("GITHUB_TOKEN", "test-token")
-				token, err := github.GetToken()
-				Expect(err).NotTo(HaveOccurred())
-				Expect(token).To(Equal("test-token"))
-			})
-		})
-
-		Context("when GH_TOKEN is set", func() {
-			It("should return the token", func() {
-				os.Setenv("GH_TOKEN", "gh-test-token")
-				token, err := github.GetToken()
-				Expect(err).NotTo(HaveOccurred())
-				Expect(token).To(Equal("gh-test-token"))
-			})
-		})
-
-		Context("when no token is set", func() {
-			It("should return an error", func() {
-				_, err := github.GetToken()
-				Expect(err).To(HaveOccurred())
-				Expect(err.Error()).To(ContainSubstring("GitHub token not found"))
-			})
+		It("should reject URLs without a host", func() {
+			_, err := github.RemoteHost("/srv/git/hello.git")
+			Expect(err).To(HaveOccurred())
 		})
 	})
 })
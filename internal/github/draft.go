@@ -0,0 +1,83 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+)
+
+const (
+	markReadyMutation = `mutation($id: ID!) {
+  markPullRequestReadyForReview(input: {pullRequestId: $id}) { clientMutationId }
+}`
+	convertToDraftMutation = `mutation($id: ID!) {
+  convertPullRequestToDraft(input: {pullRequestId: $id}) { clientMutationId }
+}`
+)
+
+type graphQLRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables,omitempty"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+type graphQLResponse struct {
+	Errors []graphQLError `json:"errors,omitempty"`
+}
+
+// SetDraft converts a pull request to a draft or marks it ready for review.
+// The REST API cannot change draft state, so this goes through GraphQL.
+func (c *Client) SetDraft(ctx context.Context, owner, repo string, number int, draft bool) (*github.PullRequest, error) {
+	pr, _, err := c.client.PullRequests.Get(ctx, owner, repo, number)
+	if err != nil {
+		return nil, apiError(ctx, "get pull request", err)
+	}
+
+	if pr.GetDraft() == draft {
+		return pr, nil
+	}
+
+	mutation := markReadyMutation
+	if draft {
+		mutation = convertToDraftMutation
+	}
+
+	if err := c.graphQL(ctx, mutation, map[string]any{"id": pr.GetNodeID()}); err != nil {
+		return nil, apiError(ctx, "change draft state", err)
+	}
+
+	pr.Draft = github.Bool(draft)
+	return pr, nil
+}
+
+func (c *Client) graphQL(ctx context.Context, query string, variables map[string]any) error {
+	// Resolves to /graphql on github.com and /api/graphql on GHES
+	req, err := c.client.NewRequest("POST", "../graphql", &graphQLRequest{
+		Query:     query,
+		Variables: variables,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The mutations set an absolute state, so they are safe to retry
+	var resp graphQLResponse
+	if _, err := c.client.Do(withIdempotent(ctx), req, &resp); err != nil {
+		return err
+	}
+
+	if len(resp.Errors) > 0 {
+		var messages []string
+		for _, e := range resp.Errors {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("graphql: %s", strings.Join(messages, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// TimeoutError reports an API call that ran out of time, as opposed to one
+// GitHub answered with an error.
+type TimeoutError struct {
+	Op string
+	// Overall is set when the caller's deadline passed, rather than the
+	// client's per-request timeout.
+	Overall bool
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Overall {
+		return fmt.Sprintf("timed out trying to %s: deadline exceeded", e.Op)
+	}
+	return fmt.Sprintf("timed out trying to %s: GitHub did not respond in time", e.Op)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// CanceledError reports an API call abandoned because its context was
+// canceled, e.g. on Ctrl-C.
+type CanceledError struct {
+	Op  string
+	Err error
+}
+
+func (e *CanceledError) Error() string {
+	return fmt.Sprintf("canceled while trying to %s", e.Op)
+}
+
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// IsTimeout reports whether err is a timeout of either kind.
+func IsTimeout(err error) bool {
+	var timeout *TimeoutError
+	return errors.As(err, &timeout)
+}
+
+// apiError wraps the error of the API call op, telling timeouts and
+// cancellation apart from errors GitHub returned.
+func apiError(ctx context.Context, op string, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return &TimeoutError{Op: op, Overall: true, Err: err}
+	case errors.Is(ctx.Err(), context.Canceled):
+		return &CanceledError{Op: op, Err: err}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() || errors.Is(err, context.DeadlineExceeded) {
+		return &TimeoutError{Op: op, Err: err}
+	}
+	return fmt.Errorf("failed to %s: %w", op, err)
+}
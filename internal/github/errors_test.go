@@ -0,0 +1,53 @@
+package github_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/github"
+)
+
+var _ = Describe("API errors", func() {
+	var client *github.Client
+
+	BeforeEach(func() {
+		client = github.NewClient("token", github.WithRequestTimeout(time.Second))
+	})
+
+	It("should report an expired deadline as an overall timeout", func() {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		_, err := client.GetPullRequest(ctx, "octocat", "hello-world", 1)
+		Expect(err).To(HaveOccurred())
+		Expect(github.IsTimeout(err)).To(BeTrue())
+
+		var timeout *github.TimeoutError
+		Expect(errors.As(err, &timeout)).To(BeTrue())
+		Expect(timeout.Overall).To(BeTrue())
+		Expect(timeout.Op).To(Equal("get pull request"))
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+	})
+
+	It("should report cancellation apart from timeouts", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := client.AddLabels(ctx, "octocat", "hello-world", 1, "backport")
+		Expect(err).To(HaveOccurred())
+		Expect(github.IsTimeout(err)).To(BeFalse())
+
+		var canceled *github.CanceledError
+		Expect(errors.As(err, &canceled)).To(BeTrue())
+		Expect(err.Error()).To(Equal("canceled while trying to add labels"))
+	})
+
+	It("should describe both kinds of timeout", func() {
+		Expect((&github.TimeoutError{Op: "list reviews"}).Error()).To(ContainSubstring("GitHub did not respond in time"))
+		Expect((&github.TimeoutError{Op: "list reviews", Overall: true}).Error()).To(ContainSubstring("deadline exceeded"))
+	})
+})
@@ -0,0 +1,13 @@
+package githubtest_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestGitHubTest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "GitHub Test Server Suite")
+}
@@ -0,0 +1,755 @@
+// Package githubtest provides a fake GitHub API server for tests. It keeps
+// repositories, pull requests, reviews, checks and files in memory and
+// implements the REST and GraphQL endpoints cpr uses.
+package githubtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// Server is a fake GitHub API. Its URL serves as the client's base URL.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	repos    map[string]*repository
+	failures []*failure
+	requests []string
+	rate     int
+	login    string
+	token    token
+}
+
+// token is what the fake's token may do. Classic tokens are reported with
+// their scopes, fine-grained ones by whether they may write pull requests.
+type token struct {
+	fineGrained bool
+	scopes      []string
+	pullWrite   bool
+}
+
+type repository struct {
+	defaultBranch string
+	private       bool
+	permissions   map[string]bool
+	pulls         []*github.PullRequest
+	commits       map[int][]string
+	merges        map[string]bool
+	reviews       map[int][]*github.PullRequestReview
+	checkRuns     map[string][]*github.CheckRun
+	statuses      map[string][]*github.RepoStatus
+	files         map[string]string
+}
+
+// failure makes matching requests fail with a status.
+type failure struct {
+	method, path string
+	status       int
+	times        int
+	// handled requests are processed before failing, like a request that
+	// went through but whose response was lost.
+	handled bool
+}
+
+// NewServer starts a fake GitHub API. Close it when done.
+func NewServer() *Server {
+	s := &Server{repos: make(map[string]*repository), rate: 5000, login: "octocat",
+		token: token{scopes: []string{"repo"}, pullWrite: true}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /user", s.getUser)
+	mux.HandleFunc("GET /repos/{owner}/{repo}", s.getRepo)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/pulls", s.listPulls)
+	mux.HandleFunc("POST /repos/{owner}/{repo}/pulls", s.createPull)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/pulls/{number}", s.getPull)
+	mux.HandleFunc("PATCH /repos/{owner}/{repo}/pulls/{number}", s.editPull)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/pulls/{number}/commits", s.listPullCommits)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/pulls/{number}/reviews", s.listReviews)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/commits/{sha}/pulls", s.listCommitPulls)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/commits/{ref}/check-runs", s.listCheckRuns)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/commits/{ref}/status", s.combinedStatus)
+	mux.HandleFunc("POST /repos/{owner}/{repo}/issues/{number}/labels", s.addLabels)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/contents/{path...}", s.getContents)
+	mux.HandleFunc("POST /graphql", s.graphQL)
+
+	s.Server = httptest.NewServer(s.intercept(mux))
+	return s
+}
+
+// URL returns the base URL to point a client at.
+func (s *Server) URL() string {
+	return s.Server.URL + "/"
+}
+
+// SetUser sets the login of the authenticated user, octocat by default.
+func (s *Server) SetUser(login string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.login = login
+}
+
+// SetTokenScopes makes the token a classic one with scopes, by default
+// repo. Without repo or public_repo, creating pull requests is forbidden.
+func (s *Server) SetTokenScopes(scopes ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pullWrite := slices.Contains(scopes, "repo") || slices.Contains(scopes, "public_repo")
+	s.token = token{scopes: scopes, pullWrite: pullWrite}
+}
+
+// SetFineGrainedToken makes the token a fine-grained one, which may create
+// and edit pull requests if pullWrite is set. Otherwise GitHub refuses with
+// 403 and names the permission in X-Accepted-GitHub-Permissions.
+func (s *Server) SetFineGrainedToken(pullWrite bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = token{fineGrained: true, pullWrite: pullWrite}
+}
+
+// SetPrivate makes a repository private or public, as repositories are
+// created.
+func (s *Server) SetPrivate(owner, name string, private bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repo(owner, name).private = private
+}
+
+// AddRepo creates an empty repository.
+func (s *Server) AddRepo(owner, name, defaultBranch string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addRepo(owner, name, defaultBranch)
+}
+
+func (s *Server) addRepo(owner, name, defaultBranch string) *repository {
+	r := &repository{
+		defaultBranch: defaultBranch,
+		permissions:   map[string]bool{"admin": true, "maintain": true, "push": true, "triage": true, "pull": true},
+		commits:       make(map[int][]string),
+		merges:        make(map[string]bool),
+		reviews:       make(map[int][]*github.PullRequestReview),
+		checkRuns:     make(map[string][]*github.CheckRun),
+		statuses:      make(map[string][]*github.RepoStatus),
+		files:         make(map[string]string),
+	}
+	s.repos[owner+"/"+name] = r
+	return r
+}
+
+// AddFile adds a file to a repository. Files are the same on every ref.
+func (s *Server) AddFile(owner, name, filePath, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repo(owner, name).files[filePath] = content
+}
+
+// AddPullRequest adds a pull request as if opened from head onto base, and
+// returns it numbered.
+func (s *Server) AddPullRequest(owner, name, head, base, title string) *github.PullRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.openPull(owner, name, head, base, title, "", false)
+}
+
+// MergePullRequest marks a pull request merged with mergeCommit, which is
+// also listed among its commits.
+func (s *Server) MergePullRequest(owner, name string, number int, mergeCommit string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.repo(owner, name)
+	pr := r.pull(number)
+	pr.State = github.String("closed")
+	pr.Merged = github.Bool(true)
+	pr.MergedAt = &github.Timestamp{Time: time.Now()}
+	pr.MergeCommitSHA = github.String(mergeCommit)
+	r.commits[number] = append(r.commits[number], mergeCommit)
+}
+
+// SetHeadSHA sets the commit a pull request's head points at.
+func (s *Server) SetHeadSHA(owner, name string, number int, sha string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repo(owner, name).pull(number).Head.SHA = github.String(sha)
+}
+
+// ClosePullRequest closes a pull request without merging it.
+func (s *Server) ClosePullRequest(owner, name string, number int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repo(owner, name).pull(number).State = github.String("closed")
+}
+
+// SetCommits sets the commits of a pull request, oldest first.
+func (s *Server) SetCommits(owner, name string, number int, shas ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repo(owner, name).commits[number] = shas
+}
+
+// SetMergeCommits marks commits as merges, listed with two parents.
+func (s *Server) SetMergeCommits(owner, name string, shas ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.repo(owner, name)
+	for _, sha := range shas {
+		r.merges[sha] = true
+	}
+}
+
+// AddReview adds a review by login in state, e.g. APPROVED.
+func (s *Server) AddReview(owner, name string, number int, login, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.repo(owner, name)
+	r.reviews[number] = append(r.reviews[number], &github.PullRequestReview{
+		User:  &github.User{Login: github.String(login)},
+		State: github.String(state),
+	})
+}
+
+// AddCheckRun adds a check run on a commit. An empty conclusion leaves the
+// run in progress.
+func (s *Server) AddCheckRun(owner, name, sha, checkName, conclusion string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run := &github.CheckRun{Name: github.String(checkName), Status: github.String("in_progress")}
+	if conclusion != "" {
+		run.Status = github.String("completed")
+		run.Conclusion = github.String(conclusion)
+	}
+	r := s.repo(owner, name)
+	r.checkRuns[sha] = append(r.checkRuns[sha], run)
+}
+
+// PullRequests returns a repository's pull requests, oldest first.
+func (s *Server) PullRequests(owner, name string) []*github.PullRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.repo(owner, name).pulls)
+}
+
+// Fail makes the next times requests to method and a path starting with
+// prefix fail with status, before they are processed.
+func (s *Server) Fail(method, prefix string, status, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &failure{method: method, path: prefix, status: status, times: times})
+}
+
+// FailAfter is like Fail, but processes the requests before failing them,
+// as if only the response was lost.
+func (s *Server) FailAfter(method, prefix string, status, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &failure{method: method, path: prefix, status: status, times: times, handled: true})
+}
+
+// Requests returns the requests served so far as "METHOD /path".
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+// intercept logs requests, sets rate limit headers and injects failures.
+func (s *Server) intercept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, req.Method+" "+req.URL.Path)
+		s.rate--
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(s.rate))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		if !s.token.fineGrained {
+			w.Header().Set("X-OAuth-Scopes", strings.Join(s.token.scopes, ", "))
+		}
+
+		var fail *failure
+		for _, f := range s.failures {
+			if f.times > 0 && f.method == req.Method && strings.HasPrefix(req.URL.Path, f.path) {
+				f.times--
+				fail = f
+				break
+			}
+		}
+		s.mu.Unlock()
+
+		if fail == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+		if fail.handled {
+			next.ServeHTTP(httptest.NewRecorder(), req)
+		}
+		writeError(w, fail.status, http.StatusText(fail.status))
+	})
+}
+
+func (s *Server) getUser(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, &github.User{Login: github.String(s.login)})
+}
+
+func (s *Server) getRepo(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.lookup(w, req)
+	if !ok {
+		return
+	}
+	owner, name := req.PathValue("owner"), req.PathValue("repo")
+	writeJSON(w, http.StatusOK, &github.Repository{
+		Name:          github.String(name),
+		FullName:      github.String(owner + "/" + name),
+		Owner:         &github.User{Login: github.String(owner)},
+		DefaultBranch: github.String(r.defaultBranch),
+		Private:       github.Bool(r.private),
+		Permissions:   r.permissions,
+	})
+}
+
+func (s *Server) listPulls(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.lookup(w, req)
+	if !ok {
+		return
+	}
+
+	state := req.URL.Query().Get("state")
+	if state == "" {
+		state = "open"
+	}
+	head := req.URL.Query().Get("head")
+	base := req.URL.Query().Get("base")
+
+	pulls := []*github.PullRequest{}
+	for _, pr := range r.pulls {
+		if state != "all" && pr.GetState() != state {
+			continue
+		}
+		if head != "" && pr.GetHead().GetLabel() != head {
+			continue
+		}
+		if base != "" && pr.GetBase().GetRef() != base {
+			continue
+		}
+		pulls = append(pulls, pr)
+	}
+	// GitHub lists the newest first
+	slices.Reverse(pulls)
+	writeJSON(w, http.StatusOK, pulls)
+}
+
+func (s *Server) createPull(w http.ResponseWriter, req *http.Request) {
+	var body github.NewPullRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.lookup(w, req)
+	if !ok {
+		return
+	}
+
+	owner, name := req.PathValue("owner"), req.PathValue("repo")
+	if !s.writePullAllowed(w) {
+		return
+	}
+	if body.GetHead() == "" || body.GetBase() == "" || body.GetTitle() == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+		return
+	}
+	if body.GetHead() == body.GetBase() {
+		writeError(w, http.StatusUnprocessableEntity, "No commits between "+body.GetBase()+" and "+body.GetHead())
+		return
+	}
+
+	head := body.GetHead()
+	if !strings.Contains(head, ":") {
+		head = owner + ":" + head
+	}
+	for _, pr := range r.pulls {
+		if pr.GetState() == "open" && pr.GetHead().GetLabel() == head && pr.GetBase().GetRef() == body.GetBase() {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+				"message": "Validation Failed",
+				"errors": []map[string]string{{
+					"resource": "PullRequest",
+					"code":     "custom",
+					"message":  "A pull request already exists for " + head + ".",
+				}},
+			})
+			return
+		}
+	}
+
+	pr := s.openPull(owner, name, body.GetHead(), body.GetBase(), body.GetTitle(), body.GetBody(), body.GetDraft())
+	writeJSON(w, http.StatusCreated, pr)
+}
+
+// writePullAllowed refuses requests that create or edit pull requests if
+// the token may not. The caller holds s.mu.
+func (s *Server) writePullAllowed(w http.ResponseWriter) bool {
+	if s.token.pullWrite {
+		return true
+	}
+	if s.token.fineGrained {
+		w.Header().Set("X-Accepted-GitHub-Permissions", "pull_requests=write")
+	}
+	writeError(w, http.StatusForbidden, "Resource not accessible by personal access token")
+	return false
+}
+
+// openPull adds an open pull request. The caller holds s.mu.
+func (s *Server) openPull(owner, name, head, base, title, body string, draft bool) *github.PullRequest {
+	r := s.repo(owner, name)
+	number := len(r.pulls) + 1
+
+	headOwner, headRef, found := strings.Cut(head, ":")
+	if !found {
+		headOwner, headRef = owner, head
+	}
+
+	pr := &github.PullRequest{
+		Number:  github.Int(number),
+		NodeID:  github.String(fmt.Sprintf("PR_%s_%s_%d", owner, name, number)),
+		State:   github.String("open"),
+		Title:   github.String(title),
+		Body:    github.String(body),
+		Draft:   github.Bool(draft),
+		HTMLURL: github.String(fmt.Sprintf("https://github.com/%s/%s/pull/%d", owner, name, number)),
+		User:    &github.User{Login: github.String(headOwner)},
+		Head: &github.PullRequestBranch{
+			Label: github.String(headOwner + ":" + headRef),
+			Ref:   github.String(headRef),
+			SHA:   github.String(fmt.Sprintf("%040d", number)),
+		},
+		Base:      &github.PullRequestBranch{Ref: github.String(base)},
+		Mergeable: github.Bool(true),
+		CreatedAt: &github.Timestamp{Time: time.Now()},
+	}
+	r.pulls = append(r.pulls, pr)
+	return pr
+}
+
+func (s *Server) getPull(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pr, ok := s.lookupPull(w, req); ok {
+		writeJSON(w, http.StatusOK, pr)
+	}
+}
+
+func (s *Server) editPull(w http.ResponseWriter, req *http.Request) {
+	var update struct {
+		Title *string `json:"title"`
+		Body  *string `json:"body"`
+		State *string `json:"state"`
+		Base  *string `json:"base"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&update); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr, ok := s.lookupPull(w, req)
+	if !ok || !s.writePullAllowed(w) {
+		return
+	}
+	if pr.GetMerged() && update.State != nil && *update.State == "open" {
+		writeError(w, http.StatusUnprocessableEntity, "Cannot reopen a merged pull request")
+		return
+	}
+	if update.Title != nil {
+		pr.Title = update.Title
+	}
+	if update.Body != nil {
+		pr.Body = update.Body
+	}
+	if update.State != nil {
+		pr.State = update.State
+	}
+	if update.Base != nil {
+		pr.Base = &github.PullRequestBranch{Ref: update.Base}
+	}
+	writeJSON(w, http.StatusOK, pr)
+}
+
+func (s *Server) listPullCommits(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr, ok := s.lookupPull(w, req)
+	if !ok {
+		return
+	}
+	r := s.repo(req.PathValue("owner"), req.PathValue("repo"))
+	commits := []*github.RepositoryCommit{}
+	for _, sha := range r.commits[pr.GetNumber()] {
+		parents := []*github.Commit{{SHA: github.String(sha + "^1")}}
+		if r.merges[sha] {
+			parents = append(parents, &github.Commit{SHA: github.String(sha + "^2")})
+		}
+		commits = append(commits, &github.RepositoryCommit{SHA: github.String(sha), Parents: parents})
+	}
+	writeJSON(w, http.StatusOK, paginate(w, req, commits))
+}
+
+func (s *Server) listReviews(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr, ok := s.lookupPull(w, req)
+	if !ok {
+		return
+	}
+	reviews := s.repo(req.PathValue("owner"), req.PathValue("repo")).reviews[pr.GetNumber()]
+	if reviews == nil {
+		reviews = []*github.PullRequestReview{}
+	}
+	writeJSON(w, http.StatusOK, paginate(w, req, reviews))
+}
+
+func (s *Server) listCommitPulls(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.lookup(w, req)
+	if !ok {
+		return
+	}
+	pulls := []*github.PullRequest{}
+	for _, pr := range r.pulls {
+		if slices.Contains(r.commits[pr.GetNumber()], req.PathValue("sha")) {
+			pulls = append(pulls, pr)
+		}
+	}
+	writeJSON(w, http.StatusOK, pulls)
+}
+
+func (s *Server) listCheckRuns(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.lookup(w, req)
+	if !ok {
+		return
+	}
+	runs := r.checkRuns[req.PathValue("ref")]
+	writeJSON(w, http.StatusOK, &github.ListCheckRunsResults{Total: github.Int(len(runs)), CheckRuns: paginate(w, req, runs)})
+}
+
+func (s *Server) combinedStatus(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.lookup(w, req)
+	if !ok {
+		return
+	}
+	statuses := r.statuses[req.PathValue("ref")]
+	writeJSON(w, http.StatusOK, &github.CombinedStatus{
+		State:      github.String("pending"),
+		TotalCount: github.Int(len(statuses)),
+		Statuses:   paginate(w, req, statuses),
+	})
+}
+
+func (s *Server) addLabels(w http.ResponseWriter, req *http.Request) {
+	var names []string
+	if err := json.NewDecoder(req.Body).Decode(&names); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr, ok := s.lookupPull(w, req)
+	if !ok {
+		return
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(pr.Labels, func(l *github.Label) bool { return l.GetName() == name }) {
+			pr.Labels = append(pr.Labels, &github.Label{Name: github.String(name)})
+		}
+	}
+	writeJSON(w, http.StatusOK, pr.Labels)
+}
+
+func (s *Server) getContents(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.lookup(w, req)
+	if !ok {
+		return
+	}
+	filePath := strings.Trim(req.PathValue("path"), "/")
+
+	if content, ok := r.files[filePath]; ok {
+		writeJSON(w, http.StatusOK, fileContent(filePath, content))
+		return
+	}
+
+	var entries []*github.RepositoryContent
+	for name, content := range r.files {
+		if path.Dir(name) == filePath {
+			entries = append(entries, fileContent(name, content))
+		}
+	}
+	if len(entries) == 0 {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].GetName() < entries[j].GetName() })
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func fileContent(filePath, content string) *github.RepositoryContent {
+	return &github.RepositoryContent{
+		Type:     github.String("file"),
+		Name:     github.String(path.Base(filePath)),
+		Path:     github.String(filePath),
+		Encoding: github.String("base64"),
+		Size:     github.Int(len(content)),
+		Content:  github.String(base64.StdEncoding.EncodeToString([]byte(content))),
+	}
+}
+
+// graphQL supports the draft state mutations.
+func (s *Server) graphQL(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Query     string         `json:"query"`
+		Variables map[string]any `json:"variables"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	var draft bool
+	switch {
+	case strings.Contains(body.Query, "convertPullRequestToDraft"):
+		draft = true
+	case strings.Contains(body.Query, "markPullRequestReadyForReview"):
+		draft = false
+	default:
+		writeJSON(w, http.StatusOK, map[string]any{"errors": []map[string]string{{"message": "unsupported query"}}})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, _ := body.Variables["id"].(string)
+	for _, r := range s.repos {
+		for _, pr := range r.pulls {
+			if pr.GetNodeID() == id {
+				pr.Draft = github.Bool(draft)
+				writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{}})
+				return
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"errors": []map[string]string{{"message": "Could not resolve to a node with the global id of '" + id + "'"}}})
+}
+
+// repo returns a repository, creating it with a main default branch if
+// needed. The caller holds s.mu.
+func (s *Server) repo(owner, name string) *repository {
+	if r, ok := s.repos[owner+"/"+name]; ok {
+		return r
+	}
+	return s.addRepo(owner, name, "main")
+}
+
+func (s *Server) lookup(w http.ResponseWriter, req *http.Request) (*repository, bool) {
+	r, ok := s.repos[req.PathValue("owner")+"/"+req.PathValue("repo")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+	return r, ok
+}
+
+func (s *Server) lookupPull(w http.ResponseWriter, req *http.Request) (*github.PullRequest, bool) {
+	r, ok := s.lookup(w, req)
+	if !ok {
+		return nil, false
+	}
+	number, err := strconv.Atoi(req.PathValue("number"))
+	if pr := r.pull(number); err == nil && pr != nil {
+		return pr, true
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+	return nil, false
+}
+
+func (r *repository) pull(number int) *github.PullRequest {
+	if number < 1 || number > len(r.pulls) {
+		return nil
+	}
+	return r.pulls[number-1]
+}
+
+// paginate returns the page of items the request asks for, 30 per page
+// and at most 100 as on GitHub, linking to the next page if there is one.
+func paginate[T any](w http.ResponseWriter, req *http.Request, items []T) []T {
+	perPage, err := strconv.Atoi(req.URL.Query().Get("per_page"))
+	if err != nil || perPage <= 0 {
+		perPage = 30
+	}
+	perPage = min(perPage, 100)
+	page, err := strconv.Atoi(req.URL.Query().Get("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+
+	start := min((page-1)*perPage, len(items))
+	end := min(start+perPage, len(items))
+	if end < len(items) {
+		next := *req.URL
+		query := next.Query()
+		query.Set("page", strconv.Itoa(page+1))
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s%s>; rel="next"`, req.Host, next.RequestURI()))
+	}
+	return items[start:end]
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
@@ -0,0 +1,331 @@
+package githubtest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/github"
+	"github.com/fraser-isbester/cpr/internal/github/githubtest"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		server *githubtest.Server
+		client *github.Client
+		ctx    context.Context
+	)
+
+	BeforeEach(func() {
+		server = githubtest.NewServer()
+		server.AddRepo("octo", "hello", "main")
+
+		var err error
+		client, err = github.NewClientWithBaseURL(server.URL(), "token",
+			github.WithRetryPolicy(github.RetryPolicy{MaxRetries: 2, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond, MaxWait: time.Second}))
+		Expect(err).NotTo(HaveOccurred())
+		ctx = context.Background()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("CreateOrUpdatePullRequest", func() {
+		It("should create a pull request and then update it", func() {
+			pr, updated, err := client.CreateOrUpdatePullRequest(ctx, "octo", "hello", "feat: add x", "body", "feature", "main", true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+			Expect(pr.GetNumber()).To(Equal(1))
+			Expect(pr.GetDraft()).To(BeTrue())
+			Expect(pr.GetHTMLURL()).To(Equal("https://github.com/octo/hello/pull/1"))
+
+			pr, updated, err = client.CreateOrUpdatePullRequest(ctx, "octo", "hello", "feat: add y", "new body", "feature", "develop", false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			Expect(pr.GetNumber()).To(Equal(1))
+
+			pulls := server.PullRequests("octo", "hello")
+			Expect(pulls).To(HaveLen(1))
+			Expect(pulls[0].GetTitle()).To(Equal("feat: add y"))
+			Expect(pulls[0].GetBody()).To(Equal("new body"))
+			Expect(pulls[0].GetBase().GetRef()).To(Equal("develop"))
+		})
+
+		It("should not open a duplicate when the create's response was lost", func() {
+			server.FailAfter(http.MethodPost, "/repos/octo/hello/pulls", http.StatusBadGateway, 1)
+
+			pr, err := client.CreatePullRequest(ctx, "octo", "hello", "fix: y", "", "bugfix", "main", false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pr.GetNumber()).To(Equal(1))
+			Expect(server.PullRequests("octo", "hello")).To(HaveLen(1))
+		})
+
+		It("should create again when a failed create did not go through", func() {
+			server.Fail(http.MethodPost, "/repos/octo/hello/pulls", http.StatusBadGateway, 1)
+
+			pr, err := client.CreatePullRequest(ctx, "octo", "hello", "fix: y", "", "bugfix", "main", false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pr.GetNumber()).To(Equal(1))
+			Expect(server.Requests()).To(ContainElements(
+				"POST /repos/octo/hello/pulls",
+				"GET /repos/octo/hello/pulls",
+			))
+		})
+
+		It("should leave rate limits to the transport", func() {
+			server.Fail(http.MethodPost, "/repos/octo/hello/pulls", http.StatusTooManyRequests, 10)
+
+			_, err := client.CreatePullRequest(ctx, "octo", "hello", "fix: y", "", "bugfix", "main", false)
+			Expect(err).To(MatchError(ContainSubstring("create pull request")))
+			// The first attempt and the transport's two retries
+			Expect(server.Requests()).To(Equal([]string{
+				"POST /repos/octo/hello/pulls",
+				"POST /repos/octo/hello/pulls",
+				"POST /repos/octo/hello/pulls",
+			}))
+		})
+	})
+
+	Describe("FindPullRequests", func() {
+		It("should find pull requests in every state from any of the heads, newest first", func() {
+			server.AddPullRequest("octo", "hello", "feature", "main", "feat: x")
+			server.AddPullRequest("octo", "hello", "other", "main", "fix: y")
+			server.AddPullRequest("octo", "hello", "alice:feature", "main", "feat: x again")
+			server.MergePullRequest("octo", "hello", 1, "abc123")
+
+			pulls, err := client.FindPullRequests(ctx, "octo", "hello", "octo:feature", "alice:feature")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pulls).To(HaveLen(2))
+			Expect(pulls[0].GetNumber()).To(Equal(3))
+			Expect(pulls[0].GetHead().GetLabel()).To(Equal("alice:feature"))
+			Expect(pulls[1].GetNumber()).To(Equal(1))
+			Expect(pulls[1].MergedAt).NotTo(BeNil())
+		})
+
+		It("should qualify heads with their owner", func() {
+			Expect(github.HeadLabel("octo", "feature")).To(Equal("octo:feature"))
+			Expect(github.HeadLabel("octo", "alice:feature")).To(Equal("alice:feature"))
+		})
+	})
+
+	Describe("ReopenPullRequest", func() {
+		It("should reopen a closed pull request", func() {
+			server.AddPullRequest("octo", "hello", "feature", "main", "feat: x")
+			server.ClosePullRequest("octo", "hello", 1)
+
+			pr, err := client.ReopenPullRequest(ctx, "octo", "hello", 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pr.GetState()).To(Equal("open"))
+			Expect(server.PullRequests("octo", "hello")[0].GetState()).To(Equal("open"))
+		})
+
+		It("should fail for a merged pull request", func() {
+			server.AddPullRequest("octo", "hello", "feature", "main", "feat: x")
+			server.MergePullRequest("octo", "hello", 1, "abc123")
+
+			_, err := client.ReopenPullRequest(ctx, "octo", "hello", 1)
+			Expect(err).To(HaveOccurred())
+			Expect(server.PullRequests("octo", "hello")[0].GetState()).To(Equal("closed"))
+		})
+	})
+
+	It("should retry reads after server errors", func() {
+		server.AddPullRequest("octo", "hello", "feature", "main", "feat: x")
+		server.Fail(http.MethodGet, "/repos/octo/hello/pulls/1", http.StatusServiceUnavailable, 2)
+
+		pr, err := client.GetPullRequest(ctx, "octo", "hello", 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pr.GetTitle()).To(Equal("feat: x"))
+	})
+
+	It("should change draft state through GraphQL", func() {
+		server.AddPullRequest("octo", "hello", "feature", "main", "feat: x")
+
+		pr, err := client.SetDraft(ctx, "octo", "hello", 1, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pr.GetDraft()).To(BeTrue())
+		Expect(server.PullRequests("octo", "hello")[0].GetDraft()).To(BeTrue())
+	})
+
+	It("should add labels", func() {
+		server.AddPullRequest("octo", "hello", "feature", "main", "feat: x")
+
+		Expect(client.AddLabels(ctx, "octo", "hello", 1, "backport", "backport")).To(Succeed())
+		Expect(server.PullRequests("octo", "hello")[0].Labels).To(HaveLen(1))
+	})
+
+	It("should list every page of a pull request's commits", func() {
+		server.AddPullRequest("octo", "hello", "feature", "main", "feat: x")
+		shas := make([]string, 0, 230)
+		for i := range 230 {
+			shas = append(shas, fmt.Sprintf("%040x", i))
+		}
+		server.SetCommits("octo", "hello", 1, shas...)
+
+		commits, err := client.GetPullRequestCommits(ctx, "octo", "hello", 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(commits).To(Equal(shas))
+		Expect(server.Requests()).To(HaveLen(3))
+	})
+
+	It("should leave merge commits out of a pull request's commits", func() {
+		server.AddPullRequest("octo", "hello", "feature", "main", "feat: x")
+		server.SetCommits("octo", "hello", 1, "a1", "m2", "b3")
+		server.SetMergeCommits("octo", "hello", "m2")
+
+		commits, err := client.GetPullRequestCommits(ctx, "octo", "hello", 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(commits).To(Equal([]string{"a1", "b3"}))
+	})
+
+	It("should report reviews and checks", func() {
+		pr := server.AddPullRequest("octo", "hello", "feature", "main", "feat: x")
+		server.AddReview("octo", "hello", 1, "alice", "APPROVED")
+		server.AddCheckRun("octo", "hello", pr.GetHead().GetSHA(), "build", "success")
+		server.AddCheckRun("octo", "hello", pr.GetHead().GetSHA(), "lint", "")
+
+		status, err := client.GetPullRequestStatus(ctx, "octo", "hello", 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.ReviewDecision).To(Equal(github.ReviewApproved))
+		Expect(status.Checks.State()).To(Equal(github.CheckStatePending))
+	})
+
+	It("should read every page of reviews and checks", func() {
+		pr := server.AddPullRequest("octo", "hello", "feature", "main", "feat: x")
+		for range 120 {
+			server.AddReview("octo", "hello", 1, "alice", "APPROVED")
+		}
+		server.AddReview("octo", "hello", 1, "alice", "CHANGES_REQUESTED")
+		for i := range 150 {
+			server.AddCheckRun("octo", "hello", pr.GetHead().GetSHA(), fmt.Sprintf("build-%d", i), "success")
+		}
+		server.AddCheckRun("octo", "hello", pr.GetHead().GetSHA(), "lint", "failure")
+
+		status, err := client.GetPullRequestStatus(ctx, "octo", "hello", 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.ReviewDecision).To(Equal(github.ReviewChangesRequested))
+		Expect(status.Checks.Results).To(HaveLen(151))
+		Expect(status.Checks.State()).To(Equal(github.CheckStateFailure))
+	})
+
+	It("should find merged pull requests by commit", func() {
+		server.AddPullRequest("octo", "hello", "feature", "main", "feat: x")
+		server.AddPullRequest("octo", "hello", "other", "main", "fix: y")
+		server.MergePullRequest("octo", "hello", 1, "abc123")
+
+		pulls, err := client.GetMergedPullRequestsForCommit(ctx, "octo", "hello", "abc123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pulls).To(HaveLen(1))
+		Expect(pulls[0].GetNumber()).To(Equal(1))
+
+		merged, err := client.IsBranchMerged(ctx, "octo", "hello", "feature", pulls[0].GetHead().GetSHA())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(merged).To(BeTrue())
+
+		merged, err = client.IsBranchMerged(ctx, "octo", "hello", "octo:feature", pulls[0].GetHead().GetSHA())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(merged).To(BeTrue())
+	})
+
+	It("should not count a merged pull request from an older branch of the same name", func() {
+		pr := server.AddPullRequest("octo", "hello", "feature", "main", "feat: x")
+		server.MergePullRequest("octo", "hello", pr.GetNumber(), "abc123")
+
+		merged, err := client.IsBranchMerged(ctx, "octo", "hello", "feature", "0123456789abcdef0123456789abcdef01234567")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(merged).To(BeFalse())
+
+		merged, err = client.IsBranchMerged(ctx, "octo", "hello", "fork:feature", pr.GetHead().GetSHA())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(merged).To(BeFalse())
+	})
+
+	It("should serve PR templates through the contents API", func() {
+		server.AddFile("octo", "hello", ".github/PULL_REQUEST_TEMPLATE/bugfix.md", "## Bug\n")
+		server.AddFile("octo", "hello", ".github/PULL_REQUEST_TEMPLATE/feature.md", "## Feature\n")
+
+		templates, err := client.ListPullRequestTemplates(ctx, "octo", "hello", "main")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(github.TemplateNames(templates)).To(Equal([]string{"bugfix", "feature"}))
+		Expect(templates[0].Content).To(Equal("## Bug\n"))
+	})
+
+	It("should fall back to the owner's .github repository for templates", func() {
+		server.AddRepo("octo", ".github", "main")
+		server.AddFile("octo", ".github", ".github/pull_request_template.md", "## Org\n")
+
+		templates, err := client.ListPullRequestTemplates(ctx, "octo", "hello", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(templates).To(HaveLen(1))
+		Expect(templates[0].Content).To(Equal("## Org\n"))
+	})
+
+	It("should return the authenticated user", func() {
+		server.SetUser("hubot")
+
+		user, err := client.CurrentUser(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(user.GetLogin()).To(Equal("hubot"))
+	})
+
+	Describe("CheckPullRequestAccess", func() {
+		It("should accept a classic token with the repo scope", func() {
+			access, err := client.CheckPullRequestAccess(ctx, "octo", "hello")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(access.TokenKind).To(Equal(github.TokenClassic))
+			Expect(access.Scopes).To(Equal([]string{"repo"}))
+			Expect(access.Permissions).To(HaveKeyWithValue("push", true))
+			Expect(server.Requests()).NotTo(ContainElement("POST /repos/octo/hello/pulls"))
+		})
+
+		It("should name the scope a classic token lacks", func() {
+			server.SetPrivate("octo", "hello", true)
+			server.SetTokenScopes("public_repo", "read:org")
+
+			_, err := client.CheckPullRequestAccess(ctx, "octo", "hello")
+			var access *github.AccessError
+			Expect(errors.As(err, &access)).To(BeTrue())
+			Expect(access.Missing).To(Equal("repo scope"))
+			Expect(err).To(MatchError("token lacks the repo scope needed to open pull requests on octo/hello: its scopes are public_repo, read:org"))
+		})
+
+		It("should leave a fine-grained token's pull request permission unverified without writing anything", func() {
+			server.SetFineGrainedToken(false)
+
+			access, err := client.CheckPullRequestAccess(ctx, "octo", "hello")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(access.TokenKind).To(Equal(github.TokenFineGrained))
+			Expect(access.PullRequestsWritable).To(BeFalse())
+			for _, request := range server.Requests() {
+				Expect(request).To(HavePrefix("GET "))
+			}
+		})
+
+		It("should name the permission GitHub asks for when it refuses a pull request", func() {
+			server.SetFineGrainedToken(false)
+
+			_, err := client.CreatePullRequest(ctx, "octo", "hello", "feat: add x", "", "feature", "main", false)
+			var access *github.AccessError
+			Expect(errors.As(err, &access)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring("token lacks the Pull requests: write permission needed to open pull requests on octo/hello")))
+			Expect(server.Requests()).To(Equal([]string{"POST /repos/octo/hello/pulls"}))
+
+			pr := server.AddPullRequest("octo", "hello", "feature", "main", "feat: add x")
+			_, err = client.UpdatePullRequest(ctx, "octo", "hello", pr.GetNumber(), "feat: add y", "", "")
+			Expect(errors.As(err, &access)).To(BeTrue())
+		})
+
+		It("should report repositories the token cannot see", func() {
+			_, err := client.CheckPullRequestAccess(ctx, "octo", "missing")
+			var access *github.AccessError
+			Expect(errors.As(err, &access)).To(BeTrue())
+			Expect(access.Missing).To(Equal("access to the repository"))
+		})
+	})
+})
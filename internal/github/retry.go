@@ -0,0 +1,333 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fraser-isbester/cpr/internal/logging"
+	"github.com/google/go-github/v66/github"
+)
+
+// RetryPolicy bounds how often and how long failed API requests are retried.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the exponential backoff between
+	// attempts, before jitter.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// MaxWait is the longest cpr waits for a rate limit to reset. Limits
+	// resetting later fail right away.
+	MaxWait time.Duration
+}
+
+// DefaultRetryPolicy retries three times, backing off from one second.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	MinBackoff: time.Second,
+	MaxBackoff: 30 * time.Second,
+	MaxWait:    time.Minute,
+}
+
+// Backoff returns the jittered delay before retry attempt, counted from 1:
+// a random duration between half and all of MinBackoff doubled per attempt,
+// capped at MaxBackoff.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.MinBackoff << min(attempt-1, 30)
+	if backoff > p.MaxBackoff || backoff <= 0 {
+		backoff = p.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// RetryHook is told about every retry of op, such as "GET /repos/o/r",
+// before waiting for it.
+type RetryHook func(op string, attempt int, wait time.Duration, reason string)
+
+// RetryTransport retries failed GitHub API requests with jittered
+// exponential backoff, waiting out rate limits as told by Retry-After and
+// X-RateLimit-Reset. Requests that failed in transit or with a server error
+// are only retried when idempotent, as a create may have gone through;
+// rate-limited requests were turned away unprocessed, so any request is
+// retried. It also bounds every attempt by RequestTimeout, records the
+// latest rate limit quota and logs every attempt, in full at
+// logging.LevelTrace.
+type RetryTransport struct {
+	Base           http.RoundTripper
+	Policy         RetryPolicy
+	RequestTimeout time.Duration
+	OnRetry        RetryHook
+	Logger         *slog.Logger
+
+	mu   sync.Mutex
+	rate github.Rate
+}
+
+type idempotentKey struct{}
+
+// withIdempotent marks requests made with ctx as safe to retry even though
+// their method is not, e.g. GraphQL mutations setting an absolute state.
+func withIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	marked, _ := req.Context().Value(idempotentKey{}).(bool)
+	return marked
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.attempt(req)
+		if err == nil {
+			t.recordRate(resp)
+		}
+
+		wait, reason, retry := t.shouldRetry(req, resp, err, attempt+1)
+		if !retry {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		op := req.Method + " " + req.URL.Path
+		t.logger().WarnContext(req.Context(), "retrying GitHub API request", "op", op, "attempt", attempt+1, "wait", wait.Round(time.Millisecond), "reason", reason)
+		if t.OnRetry != nil {
+			t.OnRetry(op, attempt+1, wait, reason)
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// attempt sends one copy of req, bounded by RequestTimeout until its body
+// is closed.
+func (t *RetryTransport) attempt(req *http.Request) (*http.Response, error) {
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if t.RequestTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.RequestTimeout)
+	}
+
+	attempt := req.Clone(ctx)
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		attempt.Body = body
+	}
+
+	logger := t.logger()
+	traceRequest(logger, attempt)
+
+	start := time.Now()
+	resp, err := t.base().RoundTrip(attempt)
+	if err != nil {
+		cancel()
+		logger.DebugContext(ctx, "GitHub API request failed", "method", req.Method, "url", req.URL.String(), "err", err, "duration", time.Since(start).Round(time.Millisecond))
+		return nil, err
+	}
+	logger.DebugContext(ctx, "GitHub API request", "method", req.Method, "url", req.URL.String(), "status", resp.StatusCode, "duration", time.Since(start).Round(time.Millisecond))
+	traceResponse(logger, resp)
+
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (t *RetryTransport) logger() *slog.Logger {
+	return logging.OrDiscard(t.Logger)
+}
+
+// shouldRetry decides whether a failed attempt is worth another and how
+// long to wait before it.
+func (t *RetryTransport) shouldRetry(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, string, bool) {
+	if attempt > t.Policy.MaxRetries || req.Context().Err() != nil {
+		return 0, "", false
+	}
+	// Without GetBody the body cannot be sent again
+	if req.Body != nil && req.GetBody == nil {
+		return 0, "", false
+	}
+
+	if err != nil {
+		if !isIdempotent(req) || !isTransient(err) {
+			return 0, "", false
+		}
+		return t.Policy.Backoff(attempt), err.Error(), true
+	}
+
+	if limited, reason := rateLimited(resp); limited {
+		wait, ok := retryAfter(resp)
+		if !ok {
+			wait = t.Policy.Backoff(attempt)
+		}
+		if wait > t.Policy.MaxWait {
+			return 0, "", false
+		}
+		return wait, reason, true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if !isIdempotent(req) {
+			return 0, "", false
+		}
+		wait, ok := retryAfter(resp)
+		if !ok || wait > t.Policy.MaxWait {
+			wait = t.Policy.Backoff(attempt)
+		}
+		return wait, resp.Status, true
+	}
+	return 0, "", false
+}
+
+// rateLimited reports whether GitHub turned the request away for exceeding
+// the primary or a secondary rate limit. The body is kept readable.
+func rateLimited(resp *http.Response) (bool, string) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return false, ""
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		return true, "rate limit exceeded"
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false, ""
+	}
+
+	text := strings.ToLower(string(body))
+	if strings.Contains(text, "secondary rate limit") || strings.Contains(text, "abuse") {
+		return true, "secondary rate limit exceeded"
+	}
+	return resp.StatusCode == http.StatusTooManyRequests, "too many requests"
+}
+
+// retryAfter returns how long GitHub asked to wait, from Retry-After in
+// seconds or X-RateLimit-Reset in epoch seconds.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if at, err := http.ParseTime(v); err == nil {
+			return max(time.Until(at), 0), true
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if v, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			// Allow for clock skew
+			return max(time.Until(time.Unix(v, 0)), 0) + time.Second, true
+		}
+	}
+	return 0, false
+}
+
+// isTransient reports whether a request failed in transit in a way another
+// attempt may not, including a timed out attempt.
+func isTransient(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func (t *RetryTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *RetryTransport) recordRate(resp *http.Response) {
+	limit, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return
+	}
+	remaining, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	reset, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rate = github.Rate{Limit: limit, Remaining: remaining, Reset: github.Timestamp{Time: time.Unix(reset, 0)}}
+}
+
+// Rate returns the rate limit quota of the latest response, or false if
+// none reported one.
+func (t *RetryTransport) Rate() (github.Rate, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rate, t.rate.Limit > 0
+}
+
+// cancelBody releases an attempt's timeout once its body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// retryableError reports whether a failed API call may succeed if made
+// again: a server error or a failure in transit. Rate limits are not, as
+// RetryTransport already waited for them as long as it would.
+func retryableError(err error) bool {
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response != nil {
+		return errResp.Response.StatusCode >= http.StatusInternalServerError
+	}
+	return isTransient(err)
+}
+
+// alreadyExists reports whether GitHub rejected a create because the
+// resource already exists.
+func alreadyExists(err error) bool {
+	var errResp *github.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil || errResp.Response.StatusCode != http.StatusUnprocessableEntity {
+		return false
+	}
+	for _, e := range errResp.Errors {
+		if strings.Contains(strings.ToLower(e.Message), "already exists") {
+			return true
+		}
+	}
+	return strings.Contains(strings.ToLower(errResp.Message), "already exists")
+}
+
+// FormatRate describes a rate limit quota for verbose output.
+func FormatRate(rate github.Rate) string {
+	return fmt.Sprintf("%d of %d requests left, resets at %s", rate.Remaining, rate.Limit, rate.Reset.Local().Format("15:04:05"))
+}
@@ -0,0 +1,61 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	stackStartMarker = "<!-- cpr-stack -->"
+	stackEndMarker   = "<!-- /cpr-stack -->"
+)
+
+// StackEntry is one pull request in a stack, listed bottom to top.
+type StackEntry struct {
+	Number int
+	Branch string
+	Title  string
+}
+
+// ApplyStackTable appends a navigation table for the stack to body, marking
+// the entry at index current. An existing table is replaced, so the body can
+// be refreshed on every run.
+func ApplyStackTable(body string, stack []StackEntry, current int) string {
+	body = strings.TrimRight(removeStackTable(body), "\n")
+
+	var table strings.Builder
+	table.WriteString(stackStartMarker + "\n")
+	table.WriteString("### Stack\n\n")
+	table.WriteString("| | PR | Branch | Title |\n")
+	table.WriteString("|---|---|---|---|\n")
+
+	// Render the top of the stack first, as GitHub users read downwards
+	for i := len(stack) - 1; i >= 0; i-- {
+		entry := stack[i]
+		marker := ""
+		if i == current {
+			marker = "👉"
+		}
+		table.WriteString(fmt.Sprintf("| %s | #%d | `%s` | %s |\n", marker, entry.Number, entry.Branch, entry.Title))
+	}
+	table.WriteString(stackEndMarker + "\n")
+
+	if body == "" {
+		return table.String()
+	}
+	return body + "\n\n" + table.String()
+}
+
+func removeStackTable(body string) string {
+	start := strings.Index(body, stackStartMarker)
+	if start < 0 {
+		return body
+	}
+
+	end := strings.Index(body[start:], stackEndMarker)
+	if end < 0 {
+		return body[:start]
+	}
+
+	return body[:start] + body[start+end+len(stackEndMarker):]
+}
@@ -0,0 +1,35 @@
+package github_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/github"
+)
+
+var _ = Describe("ApplyStackTable", func() {
+	stack := []github.StackEntry{
+		{Number: 1, Branch: "feat-a", Title: "feat: add a"},
+		{Number: 2, Branch: "feat-b", Title: "feat: add b"},
+	}
+
+	It("should append a table listing the stack top first", func() {
+		body := github.ApplyStackTable("## Summary", stack, 0)
+
+		Expect(body).To(HavePrefix("## Summary\n\n"))
+		Expect(body).To(ContainSubstring("### Stack"))
+		Expect(strings.Index(body, "#2")).To(BeNumerically("<", strings.Index(body, "#1")))
+		Expect(body).To(ContainSubstring("| 👉 | #1 | `feat-a` |"))
+	})
+
+	It("should replace an existing table", func() {
+		body := github.ApplyStackTable("## Summary", stack, 0)
+		body = github.ApplyStackTable(body, stack, 1)
+
+		Expect(strings.Count(body, "### Stack")).To(Equal(1))
+		Expect(body).To(ContainSubstring("| 👉 | #2 | `feat-b` |"))
+		Expect(body).NotTo(ContainSubstring("| 👉 | #1 |"))
+	})
+})
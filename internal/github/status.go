@@ -0,0 +1,229 @@
+package github
+
+import (
+	"context"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// Check states reported by CheckSummary.State.
+const (
+	CheckStatePending = "pending"
+	CheckStateSuccess = "success"
+	CheckStateFailure = "failure"
+	CheckStateNone    = "none"
+)
+
+// Review decisions reported by ReviewDecision.
+const (
+	ReviewApproved         = "APPROVED"
+	ReviewChangesRequested = "CHANGES_REQUESTED"
+	ReviewRequired         = "REVIEW_REQUIRED"
+)
+
+// CheckResult is a single check run or commit status attached to a ref.
+type CheckResult struct {
+	Name  string
+	State string
+	URL   string
+}
+
+// CheckSummary combines check runs and commit statuses for a ref.
+type CheckSummary struct {
+	Results []CheckResult
+	Success int
+	Pending int
+	Failure int
+}
+
+// State collapses the summary into a single pending, success, failure or
+// none state. Any failure wins over pending results.
+func (s CheckSummary) State() string {
+	switch {
+	case s.Failure > 0:
+		return CheckStateFailure
+	case s.Pending > 0:
+		return CheckStatePending
+	case s.Success > 0:
+		return CheckStateSuccess
+	default:
+		return CheckStateNone
+	}
+}
+
+// PullRequestStatus describes the review and CI state of a pull request.
+type PullRequestStatus struct {
+	PullRequest        *github.PullRequest
+	ReviewDecision     string
+	RequestedReviewers []string
+	Checks             CheckSummary
+}
+
+// GetPullRequestStatus fetches the pull request together with its reviews,
+// check runs and commit statuses for the head commit.
+func (c *Client) GetPullRequestStatus(ctx context.Context, owner, repo string, number int) (*PullRequestStatus, error) {
+	// The list endpoint omits mergeability, so fetch the full PR
+	pr, _, err := c.client.PullRequests.Get(ctx, owner, repo, number)
+	if err != nil {
+		return nil, apiError(ctx, "get pull request", err)
+	}
+
+	reviews, err := c.listReviews(ctx, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	sha := pr.GetHead().GetSHA()
+
+	runs, err := c.listCheckRuns(ctx, owner, repo, sha)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses, err := c.listStatuses(ctx, owner, repo, sha)
+	if err != nil {
+		return nil, err
+	}
+
+	var requested []string
+	for _, user := range pr.RequestedReviewers {
+		requested = append(requested, user.GetLogin())
+	}
+	for _, team := range pr.RequestedTeams {
+		requested = append(requested, team.GetSlug())
+	}
+
+	decision := ReviewDecision(reviews)
+	if decision == "" && len(requested) > 0 {
+		decision = ReviewRequired
+	}
+
+	return &PullRequestStatus{
+		PullRequest:        pr,
+		ReviewDecision:     decision,
+		RequestedReviewers: requested,
+		Checks:             SummarizeChecks(runs, statuses),
+	}, nil
+}
+
+// listReviews returns every review of a pull request, oldest first.
+func (c *Client) listReviews(ctx context.Context, owner, repo string, number int) ([]*github.PullRequestReview, error) {
+	opts := &github.ListOptions{PerPage: 100}
+	var reviews []*github.PullRequestReview
+	for {
+		page, resp, err := c.client.PullRequests.ListReviews(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, apiError(ctx, "list reviews", err)
+		}
+		reviews = append(reviews, page...)
+		if resp.NextPage == 0 {
+			return reviews, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// listCheckRuns returns every check run for a commit.
+func (c *Client) listCheckRuns(ctx context.Context, owner, repo, sha string) ([]*github.CheckRun, error) {
+	opts := &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	var runs []*github.CheckRun
+	for {
+		page, resp, err := c.client.Checks.ListCheckRunsForRef(ctx, owner, repo, sha, opts)
+		if err != nil {
+			return nil, apiError(ctx, "list check runs", err)
+		}
+		runs = append(runs, page.CheckRuns...)
+		if resp.NextPage == 0 {
+			return runs, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// listStatuses returns the latest commit status of each context for a
+// commit.
+func (c *Client) listStatuses(ctx context.Context, owner, repo, sha string) ([]*github.RepoStatus, error) {
+	opts := &github.ListOptions{PerPage: 100}
+	var statuses []*github.RepoStatus
+	for {
+		combined, resp, err := c.client.Repositories.GetCombinedStatus(ctx, owner, repo, sha, opts)
+		if err != nil {
+			return nil, apiError(ctx, "get combined status", err)
+		}
+		statuses = append(statuses, combined.Statuses...)
+		if resp.NextPage == 0 {
+			return statuses, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// ReviewDecision derives an overall decision from the latest review of each
+// reviewer. Changes requested by anyone outweigh approvals.
+func ReviewDecision(reviews []*github.PullRequestReview) string {
+	latest := make(map[string]string)
+	for _, review := range reviews {
+		state := review.GetState()
+		// Comments don't change a reviewer's standing decision
+		if state != ReviewApproved && state != ReviewChangesRequested && state != "DISMISSED" {
+			continue
+		}
+		latest[review.GetUser().GetLogin()] = state
+	}
+
+	decision := ""
+	for _, state := range latest {
+		switch state {
+		case ReviewChangesRequested:
+			return ReviewChangesRequested
+		case ReviewApproved:
+			decision = ReviewApproved
+		}
+	}
+
+	return decision
+}
+
+// SummarizeChecks merges check runs and legacy commit statuses into a single
+// summary.
+func SummarizeChecks(runs []*github.CheckRun, statuses []*github.RepoStatus) CheckSummary {
+	var summary CheckSummary
+
+	for _, run := range runs {
+		state := CheckStatePending
+		if run.GetStatus() == "completed" {
+			switch run.GetConclusion() {
+			case "success", "neutral", "skipped":
+				state = CheckStateSuccess
+			default:
+				state = CheckStateFailure
+			}
+		}
+		summary.add(CheckResult{Name: run.GetName(), State: state, URL: run.GetHTMLURL()})
+	}
+
+	for _, status := range statuses {
+		state := CheckStatePending
+		switch status.GetState() {
+		case "success":
+			state = CheckStateSuccess
+		case "failure", "error":
+			state = CheckStateFailure
+		}
+		summary.add(CheckResult{Name: status.GetContext(), State: state, URL: status.GetTargetURL()})
+	}
+
+	return summary
+}
+
+func (s *CheckSummary) add(result CheckResult) {
+	s.Results = append(s.Results, result)
+	switch result.State {
+	case CheckStateSuccess:
+		s.Success++
+	case CheckStateFailure:
+		s.Failure++
+	default:
+		s.Pending++
+	}
+}
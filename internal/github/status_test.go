@@ -0,0 +1,81 @@
+package github_test
+
+import (
+	gh "github.com/google/go-github/v66/github"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/github"
+)
+
+var _ = Describe("Pull Request Status", func() {
+	review := func(login, state string) *gh.PullRequestReview {
+		return &gh.PullRequestReview{
+			User:  &gh.User{Login: gh.String(login)},
+			State: gh.String(state),
+		}
+	}
+
+	Describe("ReviewDecision", func() {
+		It("should return empty when there are no reviews", func() {
+			Expect(github.ReviewDecision(nil)).To(BeEmpty())
+		})
+
+		It("should approve when all reviewers approved", func() {
+			reviews := []*gh.PullRequestReview{
+				review("alice", "APPROVED"),
+				review("bob", "COMMENTED"),
+			}
+			Expect(github.ReviewDecision(reviews)).To(Equal(github.ReviewApproved))
+		})
+
+		It("should prefer requested changes over approvals", func() {
+			reviews := []*gh.PullRequestReview{
+				review("alice", "APPROVED"),
+				review("bob", "CHANGES_REQUESTED"),
+			}
+			Expect(github.ReviewDecision(reviews)).To(Equal(github.ReviewChangesRequested))
+		})
+
+		It("should use each reviewer's latest decision", func() {
+			reviews := []*gh.PullRequestReview{
+				review("bob", "CHANGES_REQUESTED"),
+				review("bob", "APPROVED"),
+			}
+			Expect(github.ReviewDecision(reviews)).To(Equal(github.ReviewApproved))
+		})
+	})
+
+	Describe("SummarizeChecks", func() {
+		It("should report none without checks", func() {
+			Expect(github.SummarizeChecks(nil, nil).State()).To(Equal(github.CheckStateNone))
+		})
+
+		It("should count check runs and statuses together", func() {
+			runs := []*gh.CheckRun{
+				{Name: gh.String("build"), Status: gh.String("completed"), Conclusion: gh.String("success")},
+				{Name: gh.String("lint"), Status: gh.String("in_progress")},
+			}
+			statuses := []*gh.RepoStatus{
+				{Context: gh.String("ci/legacy"), State: gh.String("success")},
+			}
+
+			summary := github.SummarizeChecks(runs, statuses)
+			Expect(summary.Results).To(HaveLen(3))
+			Expect(summary.Success).To(Equal(2))
+			Expect(summary.Pending).To(Equal(1))
+			Expect(summary.State()).To(Equal(github.CheckStatePending))
+		})
+
+		It("should report failure when any check failed", func() {
+			runs := []*gh.CheckRun{
+				{Name: gh.String("build"), Status: gh.String("completed"), Conclusion: gh.String("failure")},
+				{Name: gh.String("lint"), Status: gh.String("queued")},
+			}
+
+			summary := github.SummarizeChecks(runs, nil)
+			Expect(summary.Failure).To(Equal(1))
+			Expect(summary.State()).To(Equal(github.CheckStateFailure))
+		})
+	})
+})
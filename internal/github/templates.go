@@ -0,0 +1,220 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// DefaultTemplateName names a repository's single PR template, as opposed
+// to those in .github/PULL_REQUEST_TEMPLATE/.
+const DefaultTemplateName = "default"
+
+// templatePaths are the locations GitHub reads a single PR template from.
+var templatePaths = []string{
+	".github/pull_request_template.md",
+	".github/PULL_REQUEST_TEMPLATE.md",
+	"pull_request_template.md",
+	"PULL_REQUEST_TEMPLATE.md",
+	"docs/pull_request_template.md",
+	"docs/PULL_REQUEST_TEMPLATE.md",
+}
+
+// templateDirs hold a repository's named PR templates.
+var templateDirs = []string{
+	".github/PULL_REQUEST_TEMPLATE",
+	"docs/PULL_REQUEST_TEMPLATE",
+	"PULL_REQUEST_TEMPLATE",
+}
+
+// OrgDefaultsRepo is the repository whose templates GitHub uses for an
+// owner's repositories that have none of their own.
+const OrgDefaultsRepo = ".github"
+
+// templateAliases are the template names tried for each commit type, in
+// order, when no template is chosen explicitly.
+var templateAliases = map[string][]string{
+	"feat":     {"feature", "feat", "enhancement"},
+	"fix":      {"bugfix", "bug", "fix", "hotfix"},
+	"docs":     {"docs", "documentation"},
+	"refactor": {"refactor", "refactoring"},
+	"perf":     {"perf", "performance"},
+	"test":     {"test", "tests"},
+	"build":    {"build", "dependencies"},
+	"ci":       {"ci"},
+	"chore":    {"chore", "maintenance"},
+	"style":    {"style"},
+}
+
+// PullRequestTemplate is a PR template found in a repository.
+type PullRequestTemplate struct {
+	// Name is DefaultTemplateName for the single template, or the file name
+	// without its extension for templates in .github/PULL_REQUEST_TEMPLATE/.
+	Name    string
+	Path    string
+	Content string
+}
+
+// TemplateFiles reads the files PR templates are looked up in.
+type TemplateFiles interface {
+	// ReadFile returns a file's content and whether it exists.
+	ReadFile(path string) (string, bool, error)
+	// ListDir returns the names of the files in a directory, sorted.
+	ListDir(dir string) ([]string, error)
+}
+
+// FindPullRequestTemplates returns the PR templates in files: the single
+// template first, if there is one, then the named templates in the first
+// template directory that has any.
+func FindPullRequestTemplates(files TemplateFiles) ([]PullRequestTemplate, error) {
+	var templates []PullRequestTemplate
+
+	for _, p := range templatePaths {
+		content, ok, err := files.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			templates = append(templates, PullRequestTemplate{Name: DefaultTemplateName, Path: p, Content: content})
+			break
+		}
+	}
+
+	for _, dir := range templateDirs {
+		names, err := files.ListDir(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+		for _, name := range names {
+			if !strings.EqualFold(path.Ext(name), ".md") {
+				continue
+			}
+			p := path.Join(dir, name)
+			content, ok, err := files.ReadFile(p)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			templates = append(templates, PullRequestTemplate{
+				Name:    strings.TrimSuffix(name, path.Ext(name)),
+				Path:    p,
+				Content: content,
+			})
+			found = true
+		}
+		if found {
+			break
+		}
+	}
+
+	return templates, nil
+}
+
+// ListPullRequestTemplates returns the PR templates on ref through the API,
+// falling back to the owner's .github repository like GitHub does. An empty
+// ref means the default branch.
+func (c *Client) ListPullRequestTemplates(ctx context.Context, owner, repo, ref string) ([]PullRequestTemplate, error) {
+	templates, err := FindPullRequestTemplates(&contentFiles{ctx: ctx, client: c, owner: owner, repo: repo, ref: ref})
+	if err != nil || len(templates) > 0 || repo == OrgDefaultsRepo {
+		return templates, err
+	}
+	return c.ListOrgPullRequestTemplates(ctx, owner)
+}
+
+// ListOrgPullRequestTemplates returns the PR templates in the owner's
+// .github repository, which GitHub uses for repositories without their own.
+func (c *Client) ListOrgPullRequestTemplates(ctx context.Context, owner string) ([]PullRequestTemplate, error) {
+	return FindPullRequestTemplates(&contentFiles{ctx: ctx, client: c, owner: owner, repo: OrgDefaultsRepo})
+}
+
+// contentFiles reads template files through the contents API.
+type contentFiles struct {
+	ctx         context.Context
+	client      *Client
+	owner, repo string
+	ref         string
+}
+
+func (f *contentFiles) ReadFile(filePath string) (string, bool, error) {
+	opts := &github.RepositoryContentGetOptions{Ref: f.ref}
+	file, _, _, err := f.client.client.Repositories.GetContents(f.ctx, f.owner, f.repo, filePath, opts)
+	if err != nil {
+		if isNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, apiError(f.ctx, "get "+filePath, err)
+	}
+	if file == nil {
+		return "", false, nil
+	}
+
+	content, err := file.GetContent()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to decode %s: %w", filePath, err)
+	}
+	return content, true, nil
+}
+
+func (f *contentFiles) ListDir(dir string) ([]string, error) {
+	opts := &github.RepositoryContentGetOptions{Ref: f.ref}
+	_, entries, _, err := f.client.client.Repositories.GetContents(f.ctx, f.owner, f.repo, dir, opts)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, apiError(f.ctx, "list "+dir, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.GetType() == "file" {
+			names = append(names, entry.GetName())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func isNotFound(err error) bool {
+	var errResp *github.ErrorResponse
+	return errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound
+}
+
+// FindTemplate returns the template called name, matched case-insensitively
+// against its name or file name.
+func FindTemplate(templates []PullRequestTemplate, name string) (PullRequestTemplate, bool) {
+	for _, t := range templates {
+		if strings.EqualFold(t.Name, name) || strings.EqualFold(path.Base(t.Path), name) {
+			return t, true
+		}
+	}
+	return PullRequestTemplate{}, false
+}
+
+// TemplateNames lists the names of templates.
+func TemplateNames(templates []PullRequestTemplate) []string {
+	names := make([]string, len(templates))
+	for i, t := range templates {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// TemplateCandidates returns the template names conventionally used for a
+// commit type, e.g. "bugfix" and "bug" for fix.
+func TemplateCandidates(commitType string) []string {
+	if aliases, ok := templateAliases[commitType]; ok {
+		return aliases
+	}
+	return []string{commitType}
+}
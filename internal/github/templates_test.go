@@ -0,0 +1,102 @@
+package github_test
+
+import (
+	"path"
+	"sort"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/github"
+)
+
+// fakeFiles serves template files from a map of paths to contents.
+type fakeFiles map[string]string
+
+func (f fakeFiles) ReadFile(p string) (string, bool, error) {
+	content, ok := f[p]
+	return content, ok, nil
+}
+
+func (f fakeFiles) ListDir(dir string) ([]string, error) {
+	var names []string
+	for p := range f {
+		if path.Dir(p) == dir {
+			names = append(names, path.Base(p))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+var _ = Describe("PR templates", func() {
+	templates := []github.PullRequestTemplate{
+		{Name: github.DefaultTemplateName, Path: ".github/pull_request_template.md"},
+		{Name: "bugfix", Path: ".github/PULL_REQUEST_TEMPLATE/bugfix.md"},
+		{Name: "Feature", Path: ".github/PULL_REQUEST_TEMPLATE/Feature.md"},
+	}
+
+	Describe("FindPullRequestTemplates", func() {
+		It("should list the single template first, then the named ones", func() {
+			templates, err := github.FindPullRequestTemplates(fakeFiles{
+				"docs/pull_request_template.md":            "single",
+				".github/PULL_REQUEST_TEMPLATE/release.md": "release",
+				".github/PULL_REQUEST_TEMPLATE/bugfix.md":  "bugfix",
+				".github/PULL_REQUEST_TEMPLATE/config.yml": "not a template",
+				"PULL_REQUEST_TEMPLATE/ignored.md":         "shadowed",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(github.TemplateNames(templates)).To(Equal([]string{"default", "bugfix", "release"}))
+			Expect(templates[0].Path).To(Equal("docs/pull_request_template.md"))
+			Expect(templates[1].Content).To(Equal("bugfix"))
+		})
+
+		It("should prefer .github over other locations", func() {
+			templates, err := github.FindPullRequestTemplates(fakeFiles{
+				"pull_request_template.md":         "root",
+				".github/pull_request_template.md": "github",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(templates).To(HaveLen(1))
+			Expect(templates[0].Content).To(Equal("github"))
+		})
+
+		It("should find nothing in an empty tree", func() {
+			templates, err := github.FindPullRequestTemplates(fakeFiles{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(templates).To(BeEmpty())
+		})
+	})
+
+	Describe("FindTemplate", func() {
+		It("should match names and file names case-insensitively", func() {
+			t, ok := github.FindTemplate(templates, "feature")
+			Expect(ok).To(BeTrue())
+			Expect(t.Path).To(HaveSuffix("Feature.md"))
+
+			t, ok = github.FindTemplate(templates, "bugfix.md")
+			Expect(ok).To(BeTrue())
+			Expect(t.Name).To(Equal("bugfix"))
+		})
+
+		It("should report missing templates", func() {
+			_, ok := github.FindTemplate(templates, "release")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("TemplateCandidates", func() {
+		It("should map commit types to conventional template names", func() {
+			Expect(github.TemplateCandidates("fix")[:2]).To(Equal([]string{"bugfix", "bug"}))
+			Expect(github.TemplateCandidates("feat")).To(ContainElement("feature"))
+		})
+
+		It("should fall back to the type itself", func() {
+			Expect(github.TemplateCandidates("release")).To(Equal([]string{"release"}))
+		})
+	})
+
+	It("should list template names", func() {
+		Expect(github.TemplateNames(templates)).To(Equal([]string{"default", "bugfix", "Feature"}))
+	})
+})
@@ -0,0 +1,43 @@
+package github
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+
+	"github.com/fraser-isbester/cpr/internal/logging"
+)
+
+// traceRequest logs req in full at logging.LevelTrace. Its Authorization
+// header is masked by the logger's redaction.
+func traceRequest(logger *slog.Logger, req *http.Request) {
+	ctx := req.Context()
+	if !logger.Enabled(ctx, logging.LevelTrace) {
+		return
+	}
+	dump, err := httputil.DumpRequestOut(req, true)
+	if err != nil {
+		logging.Trace(ctx, logger, "failed to dump GitHub API request", "err", err)
+		return
+	}
+	logging.Trace(ctx, logger, "GitHub API request dump", "dump", string(dump))
+}
+
+// traceResponse logs resp in full at logging.LevelTrace, leaving its body
+// readable.
+func traceResponse(logger *slog.Logger, resp *http.Response) {
+	ctx := context.Background()
+	if resp.Request != nil {
+		ctx = resp.Request.Context()
+	}
+	if !logger.Enabled(ctx, logging.LevelTrace) {
+		return
+	}
+	dump, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		logging.Trace(ctx, logger, "failed to dump GitHub API response", "err", err)
+		return
+	}
+	logging.Trace(ctx, logger, "GitHub API response dump", "dump", string(dump))
+}
@@ -0,0 +1,17 @@
+package github
+
+import (
+	"context"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// CurrentUser returns the user the client's token belongs to, which fails
+// if the token is not valid.
+func (c *Client) CurrentUser(ctx context.Context) (*github.User, error) {
+	user, _, err := c.client.Users.Get(ctx, "")
+	if err != nil {
+		return nil, apiError(ctx, "get authenticated user", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,186 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// LevelTrace is below slog.LevelDebug. At this level, GitHub API requests
+// and responses are logged in full, credentials redacted.
+const LevelTrace = slog.LevelDebug - 4
+
+// Log formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Redacted replaces credentials in log output.
+const Redacted = "[REDACTED]"
+
+var levelNames = map[string]slog.Level{
+	"trace": LevelTrace,
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// ParseLevel parses trace, debug, info, warn or error.
+func ParseLevel(name string) (slog.Level, error) {
+	level, ok := levelNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("invalid log level %q, must be one of trace, debug, info, warn, error", name)
+	}
+	return level, nil
+}
+
+// Options configures New.
+type Options struct {
+	Level  slog.Leveler
+	Format string
+	// Redactor masks credentials in messages and attributes. Attributes
+	// named like a credential, such as "token", are always masked.
+	Redactor *Redactor
+}
+
+// New returns a logger writing to w in the text or JSON format. Text
+// leaves out the time, as it is meant to be read as the command runs.
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	redactor := opts.Redactor
+	if redactor == nil {
+		redactor = &Redactor{}
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+	switch opts.Format {
+	case FormatText, "":
+		handlerOpts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return redactor.replaceAttr(groups, a)
+		}
+		return slog.New(slog.NewTextHandler(w, handlerOpts)), nil
+	case FormatJSON:
+		handlerOpts.ReplaceAttr = redactor.replaceAttr
+		return slog.New(slog.NewJSONHandler(w, handlerOpts)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q, must be text or json", opts.Format)
+}
+
+// Discard returns a logger that logs nothing, for packages given none.
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+// OrDiscard returns l, or a logger that logs nothing if l is nil.
+func OrDiscard(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return Discard()
+	}
+	return l
+}
+
+// Trace logs at LevelTrace.
+func Trace(ctx context.Context, l *slog.Logger, msg string, args ...any) {
+	l.Log(ctx, LevelTrace, msg, args...)
+}
+
+var (
+	// Authorization headers, as in dumped requests
+	authHeader = regexp.MustCompile(`(?im)^((?:proxy-)?authorization):[^\r\n]*`)
+	// GitHub personal access, OAuth, user-to-server, server-to-server and
+	// refresh tokens
+	githubToken = regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{20,}|github_pat_[A-Za-z0-9_]{20,})\b`)
+	// JSON Web Tokens, as used to authenticate GitHub Apps
+	jwt = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)
+	// Tokens following a scheme, e.g. "Bearer abc..." or "token abc..."
+	schemeToken = regexp.MustCompile(`(?i)\b(bearer|token)(\s+)[A-Za-z0-9._~+/=-]{20,}`)
+	// Credentials in URLs and query strings
+	urlUserinfo = regexp.MustCompile(`(://)[^/\s:@]+:[^/\s@]+@`)
+	queryToken  = regexp.MustCompile(`(?i)\b(access_token|client_secret|token)=[^&\s"]+`)
+)
+
+// sensitiveKeys are attribute name parts whose values are always masked.
+var sensitiveKeys = []string{"token", "authorization", "password", "secret", "private_key", "privatekey"}
+
+// Redactor masks credentials: any of the secrets it was given, and
+// anything that looks like a token or Authorization header.
+type Redactor struct {
+	mu      sync.RWMutex
+	secrets []string
+}
+
+// AddSecret masks secret wherever it appears, e.g. a token whose format
+// the patterns would not recognize.
+func (r *Redactor) AddSecret(secret string) {
+	if len(secret) < 4 {
+		// Masking every "a" would make logs unreadable without hiding much
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secrets = append(r.secrets, secret)
+}
+
+// Redact masks the credentials in s.
+func (r *Redactor) Redact(s string) string {
+	r.mu.RLock()
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	r.mu.RUnlock()
+
+	s = authHeader.ReplaceAllString(s, "$1: "+Redacted)
+	s = githubToken.ReplaceAllString(s, Redacted)
+	s = jwt.ReplaceAllString(s, Redacted)
+	s = schemeToken.ReplaceAllString(s, "$1$2"+Redacted)
+	s = urlUserinfo.ReplaceAllString(s, "$1"+Redacted+"@")
+	s = queryToken.ReplaceAllString(s, "$1="+Redacted)
+	return s
+}
+
+func (r *Redactor) replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.LevelKey {
+		if level, ok := a.Value.Any().(slog.Level); ok && level == LevelTrace {
+			return slog.String(a.Key, "TRACE")
+		}
+		return a
+	}
+	if isSensitive(a.Key) && !isEmpty(a.Value) {
+		return slog.String(a.Key, Redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.Redact(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, r.Redact(v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, r.Redact(v.String()))
+		}
+	}
+	return a
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range sensitiveKeys {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmpty(v slog.Value) bool {
+	return v.Kind() == slog.KindString && v.String() == ""
+}
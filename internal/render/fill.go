@@ -0,0 +1,338 @@
+package render
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+)
+
+// Sources that can fill a PR template section.
+const (
+	SourceSummary  = "summary"
+	SourceChanges  = "changes"
+	SourceFiles    = "files"
+	SourceCommits  = "commits"
+	SourceBreaking = "breaking"
+	SourceTests    = "tests"
+	SourceIssues   = "issues"
+)
+
+// Conditions that tick a PR template checklist item.
+const (
+	ConditionTestsChanged = "tests_changed"
+	ConditionDocsChanged  = "docs_changed"
+	ConditionDepsChanged  = "deps_changed"
+	ConditionBreaking     = "breaking"
+	ConditionNotBreaking  = "not_breaking"
+	ConditionHasIssues    = "has_issues"
+)
+
+var (
+	checklistPattern     = regexp.MustCompile(`^(\s*[-*+]\s+)\[ \](\s+)(.*)$`)
+	checklistItemPattern = regexp.MustCompile(`^\s*[-*+]\s+\[[ xX]\]\s`)
+	testFilePattern      = regexp.MustCompile(`(_test\.go|_test\.py|\.test\.[jt]sx?|\.spec\.[jt]sx?)$|(^|/)(tests?|__tests__|spec)/|(^|/)test_[^/]*\.py$`)
+	docFilePattern       = regexp.MustCompile(`(?i)\.(md|mdx|rst|adoc)$|(^|/)docs?/`)
+)
+
+// dependencyFiles are manifests and lock files whose change means the
+// dependencies changed.
+var dependencyFiles = map[string]bool{
+	"go.mod": true, "go.sum": true,
+	"package.json": true, "package-lock.json": true, "yarn.lock": true, "pnpm-lock.yaml": true,
+	"requirements.txt": true, "pyproject.toml": true, "poetry.lock": true,
+	"Cargo.toml": true, "Cargo.lock": true,
+	"Gemfile": true, "Gemfile.lock": true,
+}
+
+// FillRules configures how Fill maps the body data onto a PR template.
+type FillRules struct {
+	// Sections maps section headings, normalized with SectionKey, to the
+	// source that fills them.
+	Sections map[string]string
+	// Checklist maps a case-insensitive phrase of a checklist item, matched
+	// as whole words, to the condition that ticks it.
+	Checklist map[string]string
+	// KeepComments leaves <!-- --> guidance comments in place.
+	KeepComments bool
+}
+
+// DefaultFillRules returns the rules used when a repository configures none.
+func DefaultFillRules() FillRules {
+	return FillRules{
+		Sections: map[string]string{
+			"summary":          SourceSummary,
+			"description":      SourceSummary,
+			"what":             SourceSummary,
+			"what changed":     SourceSummary,
+			"changes":          SourceSummary,
+			"testing":          SourceTests,
+			"breaking changes": SourceBreaking,
+			"related issues":   SourceIssues,
+		},
+		Checklist: map[string]string{
+			"tests added":           ConditionTestsChanged,
+			"added tests":           ConditionTestsChanged,
+			"tests updated":         ConditionTestsChanged,
+			"docs updated":          ConditionDocsChanged,
+			"documentation updated": ConditionDocsChanged,
+			"updated documentation": ConditionDocsChanged,
+		},
+	}
+}
+
+// Validate reports sources and conditions Fill does not know.
+func (r FillRules) Validate() error {
+	for heading, source := range r.Sections {
+		switch source {
+		case "", SourceSummary, SourceChanges, SourceFiles, SourceCommits, SourceBreaking, SourceTests, SourceIssues:
+		default:
+			return fmt.Errorf("unknown source %q for section %q", source, heading)
+		}
+	}
+	for item, condition := range r.Checklist {
+		switch condition {
+		case "", ConditionTestsChanged, ConditionDocsChanged, ConditionDepsChanged, ConditionBreaking, ConditionNotBreaking, ConditionHasIssues:
+		default:
+			return fmt.Errorf("unknown condition %q for checklist item %q", condition, item)
+		}
+	}
+	return nil
+}
+
+// Fill fills in a Markdown PR template. It strips guidance comments,
+// replaces the legacy {{title}} and [Summary] style placeholders, fills
+// each mapped section with its source and ticks checklist items whose
+// condition holds. Each source fills at most one section; if the template
+// has section rules but no place for the summary, the summary is appended.
+func Fill(text string, data commit.BodyData, rules FillRules) string {
+	if strings.TrimSpace(text) == "" {
+		return data.Summary
+	}
+
+	if !rules.KeepComments {
+		text = StripComments(text)
+	}
+
+	filled := make(map[string]bool)
+	text, filled[SourceSummary] = replacePlaceholders(text, data)
+
+	sections := make(map[string]string, len(rules.Sections))
+	for heading, source := range rules.Sections {
+		sections[SectionKey(heading)] = source
+	}
+
+	root := ParseSections(text)
+	root.Walk(func(s *Section) {
+		if s.Level > 0 {
+			source := sections[SectionKey(s.Heading)]
+			if source != "" && !filled[source] {
+				if content := sourceContent(source, data); content != "" {
+					s.Content = fillContent(s.Content, content)
+					filled[source] = true
+				}
+			}
+		}
+		tickChecklist(s.Content, data, rules.Checklist)
+	})
+
+	result := root.String()
+	if len(sections) > 0 && !filled[SourceSummary] && strings.TrimSpace(data.Summary) != "" {
+		result = strings.TrimRight(result, "\n") + "\n\n" + data.Summary
+	}
+
+	return strings.TrimSpace(blankRunPattern.ReplaceAllString(result, "\n\n")) + "\n"
+}
+
+// replacePlaceholders substitutes the legacy placeholders and reports
+// whether the summary was placed.
+func replacePlaceholders(text string, data commit.BodyData) (string, bool) {
+	for _, p := range []string{"{{title}}", "{{TITLE}}", "[Title]", "[TITLE]"} {
+		text = strings.ReplaceAll(text, p, data.Title)
+	}
+
+	placed := false
+	for _, p := range []string{
+		"{{description}}", "{{DESCRIPTION}}", "{{summary}}", "{{SUMMARY}}",
+		"[Description]", "[DESCRIPTION]", "[Summary]", "[SUMMARY]",
+	} {
+		if strings.Contains(text, p) {
+			text = strings.ReplaceAll(text, p, data.Summary)
+			placed = true
+		}
+	}
+	return text, placed
+}
+
+// fillContent replaces a section's prose with content, keeping its
+// checklist items.
+func fillContent(lines []string, content string) []string {
+	filled := []string{""}
+	filled = append(filled, strings.Split(strings.TrimRight(content, "\n"), "\n")...)
+	filled = append(filled, "")
+
+	var checklist []string
+	for _, line := range lines {
+		if checklistItemPattern.MatchString(line) {
+			checklist = append(checklist, line)
+		}
+	}
+	if len(checklist) > 0 {
+		filled = append(filled, checklist...)
+		filled = append(filled, "")
+	}
+	return filled
+}
+
+// tickChecklist ticks the unchecked items in lines whose text matches a
+// rule with a condition that holds. Items are never unticked.
+func tickChecklist(lines []string, data commit.BodyData, rules map[string]string) {
+	keys := make([]string, 0, len(rules))
+	for key := range rules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for i, line := range lines {
+		m := checklistPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		item := strings.ToLower(m[3])
+		for _, key := range keys {
+			if containsPhrase(item, strings.ToLower(key)) && conditionHolds(rules[key], data) {
+				lines[i] = m[1] + "[x]" + m[2] + m[3]
+				break
+			}
+		}
+	}
+}
+
+// containsPhrase reports whether phrase occurs in text as whole words, so
+// that "test" does not match "Tests pass" or "latest".
+func containsPhrase(text, phrase string) bool {
+	if phrase == "" {
+		return false
+	}
+	for start := 0; ; {
+		i := strings.Index(text[start:], phrase)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(phrase)
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func conditionHolds(condition string, data commit.BodyData) bool {
+	switch condition {
+	case ConditionTestsChanged:
+		return len(testFiles(data.Files)) > 0
+	case ConditionDocsChanged:
+		return anyFile(data.Files, func(p string) bool { return docFilePattern.MatchString(p) })
+	case ConditionDepsChanged:
+		return anyFile(data.Files, func(p string) bool { return dependencyFiles[path.Base(p)] })
+	case ConditionBreaking:
+		return data.Breaking
+	case ConditionNotBreaking:
+		return !data.Breaking
+	case ConditionHasIssues:
+		return len(data.Issues) > 0
+	}
+	return false
+}
+
+// sourceContent renders a source as Markdown, or "" if it has nothing to say.
+func sourceContent(source string, data commit.BodyData) string {
+	switch source {
+	case SourceSummary:
+		return stripLeadingHeading(data.Summary)
+	case SourceChanges:
+		return bullets(data.Changes)
+	case SourceFiles:
+		var files []string
+		for _, f := range data.Files {
+			files = append(files, fmt.Sprintf("`%s` (%s, +%d/-%d)", f.Path, f.Status, f.Additions, f.Deletions))
+		}
+		return bullets(files)
+	case SourceCommits:
+		var commits []string
+		for _, c := range data.Commits {
+			hash := c.Hash
+			if len(hash) > 7 {
+				hash = hash[:7]
+			}
+			commits = append(commits, fmt.Sprintf("%s (%s)", c.Subject, hash))
+		}
+		return bullets(commits)
+	case SourceBreaking:
+		return bullets(data.BreakingChanges)
+	case SourceTests:
+		var tests []string
+		for _, f := range testFiles(data.Files) {
+			tests = append(tests, fmt.Sprintf("`%s`", f))
+		}
+		return bullets(tests)
+	case SourceIssues:
+		return bullets(data.Issues)
+	}
+	return ""
+}
+
+func bullets(items []string) string {
+	if len(items) == 0 {
+		return ""
+	}
+	return "- " + strings.Join(items, "\n- ")
+}
+
+func testFiles(files []commit.FileChange) []string {
+	var tests []string
+	for _, f := range files {
+		if testFilePattern.MatchString(f.Path) {
+			tests = append(tests, f.Path)
+		}
+	}
+	return tests
+}
+
+func anyFile(files []commit.FileChange, match func(string) bool) bool {
+	for _, f := range files {
+		if match(f.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripLeadingHeading drops the "## Summary" style heading the generated
+// summary starts with, since the template provides its own.
+func stripLeadingHeading(text string) string {
+	text = strings.TrimLeft(text, "\n")
+	first, rest, _ := strings.Cut(text, "\n")
+	if headingPattern.MatchString(first) {
+		return strings.TrimSpace(rest)
+	}
+	return strings.TrimSpace(text)
+}
+
+// SectionKey normalizes a heading for matching against FillRules.Sections:
+// lower case, without surrounding space or a trailing colon.
+func SectionKey(heading string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(heading), ":")))
+}
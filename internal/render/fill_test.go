@@ -0,0 +1,115 @@
+package render_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+	"github.com/fraser-isbester/cpr/internal/render"
+)
+
+var _ = Describe("Fill", func() {
+	var (
+		data  commit.BodyData
+		rules render.FillRules
+	)
+
+	BeforeEach(func() {
+		data = commit.BodyData{
+			Title:   "fix(api): handle timeouts",
+			Summary: "## Summary\n\n- handle timeouts\n",
+			Files: []commit.FileChange{
+				{Path: "api/client.go", Status: "modified"},
+				{Path: "api/client_test.go", Status: "modified"},
+			},
+		}
+		rules = render.DefaultFillRules()
+	})
+
+	It("should fill every mapped section and keep the rest", func() {
+		template := `## Description
+<!-- What does this PR do? -->
+Please describe your change.
+
+## Testing
+<!-- How was this tested? -->
+
+## Notes
+Leave me alone.
+`
+		Expect(render.Fill(template, data, rules)).To(Equal(`## Description
+
+- handle timeouts
+
+## Testing
+
+- ` + "`api/client_test.go`" + `
+
+## Notes
+Leave me alone.
+`))
+	})
+
+	It("should fill a source only once", func() {
+		body := render.Fill("## Summary\n\n## Description\n", data, rules)
+		Expect(body).To(Equal("## Summary\n\n- handle timeouts\n\n## Description\n"))
+	})
+
+	It("should leave sections alone when their source is empty", func() {
+		body := render.Fill("## Summary\n\n## Breaking changes\nNone.\n", data, rules)
+		Expect(body).To(HaveSuffix("## Breaking changes\nNone.\n"))
+	})
+
+	It("should tick checklist items whose condition holds", func() {
+		template := `## Checklist
+- [ ] Tests added
+- [ ] Documentation updated
+- [x] I read the contributing guide
+`
+		body := render.Fill(template, data, rules)
+		Expect(body).To(ContainSubstring("- [x] Tests added"))
+		Expect(body).To(ContainSubstring("- [ ] Documentation updated"))
+		Expect(body).To(ContainSubstring("- [x] I read the contributing guide"))
+	})
+
+	It("should keep checklists in filled sections", func() {
+		body := render.Fill("## Summary\nTODO\n- [ ] Tests added\n- [ ] Tests pass\n", data, rules)
+		Expect(body).To(Equal("## Summary\n\n- handle timeouts\n\n- [x] Tests added\n- [ ] Tests pass\n"))
+	})
+
+	It("should match checklist phrases as whole words", func() {
+		rules.Checklist = map[string]string{"test": render.ConditionTestsChanged, "doc": render.ConditionTestsChanged}
+		template := "- [ ] Add a test\n- [ ] Tests pass\n- [ ] Latest Docker image builds\n- [ ] Update the doc\n"
+
+		body := render.Fill(template, data, rules)
+		Expect(body).To(HavePrefix("- [x] Add a test\n- [ ] Tests pass\n- [ ] Latest Docker image builds\n- [x] Update the doc\n"))
+	})
+
+	It("should replace legacy placeholders", func() {
+		body := render.Fill("# [Title]\n\n{{summary}}", data, rules)
+		Expect(body).To(Equal("# fix(api): handle timeouts\n\n## Summary\n\n- handle timeouts\n"))
+	})
+
+	It("should append the summary when the template has no place for it", func() {
+		body := render.Fill("## Checklist\n- [ ] Reviewed\n", data, rules)
+		Expect(body).To(Equal("## Checklist\n- [ ] Reviewed\n\n## Summary\n\n- handle timeouts\n"))
+	})
+
+	It("should keep comments when configured", func() {
+		rules.KeepComments = true
+		Expect(render.Fill("<!-- keep -->\n## Notes\n", data, rules)).To(HavePrefix("<!-- keep -->"))
+	})
+
+	It("should fill sections from other sources", func() {
+		rules.Sections = map[string]string{"commits": render.SourceCommits}
+		data.Commits = []commit.CommitInfo{{Hash: "0123456789abcdef", Subject: "fix: handle timeouts"}}
+
+		body := render.Fill("## Commits:\n", data, rules)
+		Expect(body).To(HavePrefix("## Commits:\n\n- fix: handle timeouts (0123456)\n"))
+	})
+
+	It("should reject unknown sources and conditions", func() {
+		rules.Sections["notes"] = "vibes"
+		Expect(rules.Validate()).To(MatchError(ContainSubstring(`unknown source "vibes"`)))
+	})
+})
@@ -0,0 +1,92 @@
+package render
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	headingPattern  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	commentPattern  = regexp.MustCompile(`(?s)<!--.*?-->`)
+	blankRunPattern = regexp.MustCompile(`\n{3,}`)
+)
+
+// Section is a Markdown heading with the lines up to its first subsection
+// and its subsections. The root section has level 0 and no heading.
+type Section struct {
+	Level    int
+	Heading  string
+	Content  []string
+	Children []*Section
+}
+
+// ParseSections parses Markdown into a tree of sections keyed by ATX
+// headings. Headings inside fenced code blocks are left alone.
+func ParseSections(text string) *Section {
+	root := &Section{}
+	stack := []*Section{root}
+	inFence := false
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+
+		m := headingPattern.FindStringSubmatch(line)
+		if m == nil || inFence {
+			current := stack[len(stack)-1]
+			current.Content = append(current.Content, line)
+			continue
+		}
+
+		section := &Section{Level: len(m[1]), Heading: m[2]}
+		for stack[len(stack)-1].Level >= section.Level {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1]
+		parent.Children = append(parent.Children, section)
+		stack = append(stack, section)
+	}
+
+	return root
+}
+
+// String renders the section and its subsections back to Markdown.
+func (s *Section) String() string {
+	var lines []string
+	s.render(&lines)
+	return strings.Join(lines, "\n")
+}
+
+func (s *Section) render(lines *[]string) {
+	if s.Level > 0 {
+		*lines = append(*lines, strings.Repeat("#", s.Level)+" "+s.Heading)
+	}
+	*lines = append(*lines, s.Content...)
+	for _, child := range s.Children {
+		child.render(lines)
+	}
+}
+
+// Walk calls fn for the section and every subsection, depth first.
+func (s *Section) Walk(fn func(*Section)) {
+	fn(s)
+	for _, child := range s.Children {
+		child.Walk(fn)
+	}
+}
+
+// StripComments removes HTML comments, such as template guidance, and the
+// blank lines they leave behind.
+func StripComments(text string) string {
+	text = commentPattern.ReplaceAllString(text, "")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = ""
+		}
+	}
+	return blankRunPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+}
@@ -0,0 +1,38 @@
+package render_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/render"
+)
+
+var _ = Describe("ParseSections", func() {
+	It("should nest sections by heading level", func() {
+		root := render.ParseSections("intro\n## Summary\ntext\n### Details\nmore\n## Testing\n")
+
+		Expect(root.Content).To(Equal([]string{"intro"}))
+		Expect(root.Children).To(HaveLen(2))
+		Expect(root.Children[0].Heading).To(Equal("Summary"))
+		Expect(root.Children[0].Children[0].Heading).To(Equal("Details"))
+		Expect(root.Children[1].Heading).To(Equal("Testing"))
+	})
+
+	It("should ignore headings in code fences", func() {
+		root := render.ParseSections("## Usage\n```sh\n# not a heading\n```\n")
+		Expect(root.Children).To(HaveLen(1))
+		Expect(root.Children[0].Content).To(ContainElement("# not a heading"))
+	})
+
+	It("should round-trip the text", func() {
+		text := "intro\n\n## Summary\n\ntext\n\n### Details\n- a\n"
+		Expect(render.ParseSections(text).String()).To(Equal(text))
+	})
+})
+
+var _ = Describe("StripComments", func() {
+	It("should remove multi-line comments and the blank lines they leave", func() {
+		text := "## Summary\n\n<!--\nDescribe your change.\n-->\n\n\n## Testing\n"
+		Expect(render.StripComments(text)).To(Equal("## Summary\n\n## Testing\n"))
+	})
+})
@@ -0,0 +1,125 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+)
+
+// Dir is where body templates live, relative to the repository root.
+const Dir = ".cpr/templates"
+
+// BodyTemplate is the template used for PR bodies when present.
+const BodyTemplate = "body"
+
+// Templates is a set of named templates loaded from Dir. Every template can
+// include the others with {{template "name" .}}, and the set is associated
+// with PR templates rendered through Render.
+type Templates struct {
+	set *template.Template
+}
+
+// Load parses every *.tmpl file in dir, named after the file without its
+// extension. A missing directory yields an empty set.
+func Load(dir string) (*Templates, error) {
+	set := template.New("").Funcs(funcs(commit.BodyData{}))
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read template %s: %w", path, err)
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		if _, err := set.New(name).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", path, err)
+		}
+	}
+
+	return &Templates{set: set}, nil
+}
+
+// Has reports whether the set contains a template called name.
+func (t *Templates) Has(name string) bool {
+	return t != nil && t.set.Lookup(name) != nil
+}
+
+// Execute renders the named template with data.
+func (t *Templates) Execute(name string, data commit.BodyData) (string, error) {
+	if !t.Has(name) {
+		return "", fmt.Errorf("template %q not found in %s", name, Dir)
+	}
+
+	set, err := t.set.Clone()
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	if err := set.Funcs(funcs(data)).ExecuteTemplate(&out, name, data); err != nil {
+		return "", fmt.Errorf("failed to render template %q: %w", name, err)
+	}
+	return out.String(), nil
+}
+
+// Render renders text, such as a PR template, as a Go template with data and
+// access to the templates in the set. The legacy placeholders {{title}},
+// {{description}} and {{summary}} keep working as functions.
+func (t *Templates) Render(text string, data commit.BodyData) (string, error) {
+	var set *template.Template
+	if t == nil {
+		set = template.New("")
+	} else {
+		clone, err := t.set.Clone()
+		if err != nil {
+			return "", err
+		}
+		set = clone
+	}
+
+	tmpl, err := set.New("pull_request_template").Funcs(funcs(data)).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse PR template: %w", err)
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render PR template: %w", err)
+	}
+	return out.String(), nil
+}
+
+// IsTemplate reports whether text contains Go template actions.
+func IsTemplate(text string) bool {
+	return strings.Contains(text, "{{") && strings.Contains(text, "}}")
+}
+
+func funcs(data commit.BodyData) template.FuncMap {
+	title := func() string { return data.Title }
+	summary := func() string { return data.Summary }
+
+	return template.FuncMap{
+		"upper":       func(v any) string { return strings.ToUpper(fmt.Sprint(v)) },
+		"lower":       func(v any) string { return strings.ToLower(fmt.Sprint(v)) },
+		"join":        strings.Join,
+		"title":       title,
+		"TITLE":       title,
+		"summary":     summary,
+		"SUMMARY":     summary,
+		"description": summary,
+		"DESCRIPTION": summary,
+	}
+}
@@ -0,0 +1,98 @@
+package render_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+	"github.com/fraser-isbester/cpr/internal/render"
+)
+
+var _ = Describe("Templates", func() {
+	var (
+		tmpDir string
+		data   commit.BodyData
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "cpr-templates-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		data = commit.BodyData{
+			Title:   "fix(api): handle timeouts",
+			Type:    commit.TypeFix,
+			Scope:   "api",
+			Summary: "## Summary\n\n- handle timeouts\n",
+			Files:   []commit.FileChange{{Path: "api/client.go", Status: "modified", Additions: 3}},
+			Stats:   commit.Stats{Files: 1, Additions: 3},
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	write := func(name, content string) {
+		Expect(os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644)).To(Succeed())
+	}
+
+	It("should load an empty set from a missing directory", func() {
+		templates, err := render.Load(filepath.Join(tmpDir, "missing"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(templates.Has(render.BodyTemplate)).To(BeFalse())
+	})
+
+	It("should render the body template with the data model", func() {
+		write("body.tmpl", `## {{.Type | upper}} in {{.Scope}}
+{{range .Files}}- {{.Path}} (+{{.Additions}})
+{{end}}{{template "footer" .}}`)
+		write("footer.tmpl", `{{.Stats.Files}} file(s) changed`)
+
+		templates, err := render.Load(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		body, err := templates.Execute(render.BodyTemplate, data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(Equal("## FIX in api\n- api/client.go (+3)\n1 file(s) changed"))
+	})
+
+	It("should report template errors", func() {
+		write("body.tmpl", `{{.Missing}}`)
+
+		templates, err := render.Load(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = templates.Execute(render.BodyTemplate, data)
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("Render", func() {
+		It("should render PR templates using repository templates", func() {
+			write("files.tmpl", `{{range .Files}}- {{.Path}}{{end}}`)
+			templates, err := render.Load(tmpDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			out, err := templates.Render("# {{.Title}}\n\n{{template \"files\" .}}", data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out).To(Equal("# fix(api): handle timeouts\n\n- api/client.go"))
+		})
+
+		It("should keep the legacy placeholders working", func() {
+			templates, err := render.Load(tmpDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			out, err := templates.Render("# {{title}}\n\n{{summary}}", data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out).To(Equal("# fix(api): handle timeouts\n\n## Summary\n\n- handle timeouts\n"))
+		})
+	})
+
+	It("should detect template actions", func() {
+		Expect(render.IsTemplate("## Summary\n\n{{.Summary}}")).To(BeTrue())
+		Expect(render.IsTemplate("## Summary\n\n[Description]")).To(BeFalse())
+	})
+})
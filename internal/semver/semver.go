@@ -0,0 +1,197 @@
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+)
+
+// Bump is the part of a version a release increments.
+type Bump int
+
+const (
+	BumpNone Bump = iota
+	BumpPatch
+	BumpMinor
+	BumpMajor
+)
+
+func (b Bump) String() string {
+	switch b {
+	case BumpPatch:
+		return "patch"
+	case BumpMinor:
+		return "minor"
+	case BumpMajor:
+		return "major"
+	default:
+		return "none"
+	}
+}
+
+var versionPattern = regexp.MustCompile(`^(v?)(\d+)\.(\d+)\.(\d+)(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+// Version is a semantic version as used in release tags.
+type Version struct {
+	// Prefix is kept from the tag, usually "v" or "".
+	Prefix string
+	Major  int
+	Minor  int
+	Patch  int
+	Pre    string
+}
+
+// Parse parses tags such as "v1.2.3" or "1.2.3-rc.1". Build metadata is
+// accepted and dropped.
+func Parse(s string) (Version, error) {
+	m := versionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Version{}, fmt.Errorf("%q is not a semantic version", s)
+	}
+
+	major, _ := strconv.Atoi(m[2])
+	minor, _ := strconv.Atoi(m[3])
+	patch, _ := strconv.Atoi(m[4])
+
+	return Version{Prefix: m[1], Major: major, Minor: minor, Patch: patch, Pre: m[5]}, nil
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%s%d.%d.%d", v.Prefix, v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// IsPrerelease reports whether the version has a pre-release part.
+func (v Version) IsPrerelease() bool {
+	return v.Pre != ""
+}
+
+// Compare returns -1, 0 or 1 as v sorts before, equal to or after other,
+// following semver precedence rules.
+func (v Version) Compare(other Version) int {
+	for _, d := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+
+	switch {
+	case v.Pre == other.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case other.Pre == "":
+		return -1
+	}
+
+	a, b := strings.Split(v.Pre, "."), strings.Split(other.Pre, ".")
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		an, aErr := strconv.Atoi(a[i])
+		bn, bErr := strconv.Atoi(b[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			return sign(an - bn)
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			return strings.Compare(a[i], b[i])
+		}
+	}
+	return sign(len(a) - len(b))
+}
+
+// Latest returns the highest version among tags, ignoring tags that aren't
+// semantic versions. Pre-releases are only considered if includePre is set.
+func Latest(tags []string, includePre bool) (Version, bool) {
+	var latest Version
+	found := false
+	for _, tag := range tags {
+		v, err := Parse(tag)
+		if err != nil || (v.IsPrerelease() && !includePre) {
+			continue
+		}
+		if !found || v.Compare(latest) > 0 {
+			latest = v
+			found = true
+		}
+	}
+	return latest, found
+}
+
+// BumpFor returns the bump implied by Conventional commits: breaking changes
+// are major, features minor, and fixes and performance improvements patch.
+func BumpFor(commits []*commit.Conventional) Bump {
+	bump := BumpNone
+	for _, c := range commits {
+		switch {
+		case c.Breaking:
+			return BumpMajor
+		case c.Type == commit.TypeFeat:
+			bump = max(bump, BumpMinor)
+		case c.Type == commit.TypeFix || c.Type == commit.TypePerf:
+			bump = max(bump, BumpPatch)
+		}
+	}
+	return bump
+}
+
+// Next returns the version following v for bump. With a channel such as
+// "rc", it returns the next pre-release of that version, numbered after any
+// existing one among tags, e.g. 1.3.0-rc.2 after 1.3.0-rc.1.
+func Next(v Version, bump Bump, channel string, tags []string) Version {
+	next := Version{Prefix: v.Prefix, Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+	switch bump {
+	case BumpMajor:
+		next.Major++
+		next.Minor, next.Patch = 0, 0
+	case BumpMinor:
+		next.Minor++
+		next.Patch = 0
+	case BumpPatch:
+		next.Patch++
+	case BumpNone:
+		return v
+	}
+
+	if channel == "" {
+		return next
+	}
+
+	n := 0
+	for _, tag := range tags {
+		t, err := Parse(tag)
+		if err != nil || t.Major != next.Major || t.Minor != next.Minor || t.Patch != next.Patch {
+			continue
+		}
+		if num, ok := strings.CutPrefix(t.Pre, channel+"."); ok {
+			if i, err := strconv.Atoi(num); err == nil && i > n {
+				n = i
+			}
+		}
+	}
+	next.Pre = fmt.Sprintf("%s.%d", channel, n+1)
+
+	return next
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,88 @@
+package semver_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/fraser-isbester/cpr/internal/commit"
+	"github.com/fraser-isbester/cpr/internal/semver"
+)
+
+var _ = Describe("Semver", func() {
+	parse := func(s string) semver.Version {
+		v, err := semver.Parse(s)
+		Expect(err).NotTo(HaveOccurred())
+		return v
+	}
+
+	conventional := func(message string) *commit.Conventional {
+		c, err := commit.ParseConventional(message)
+		Expect(err).NotTo(HaveOccurred())
+		return c
+	}
+
+	Describe("Parse", func() {
+		It("should keep the tag prefix and pre-release", func() {
+			v := parse("v1.2.3-rc.1")
+			Expect(v).To(Equal(semver.Version{Prefix: "v", Major: 1, Minor: 2, Patch: 3, Pre: "rc.1"}))
+			Expect(v.String()).To(Equal("v1.2.3-rc.1"))
+		})
+
+		It("should reject other tags", func() {
+			_, err := semver.Parse("release-2024")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Compare", func() {
+		It("should order pre-releases before the release", func() {
+			Expect(parse("1.0.0-rc.1").Compare(parse("1.0.0"))).To(Equal(-1))
+			Expect(parse("1.0.0-rc.2").Compare(parse("1.0.0-rc.10"))).To(Equal(-1))
+			Expect(parse("1.0.0-alpha").Compare(parse("1.0.0-beta"))).To(Equal(-1))
+		})
+	})
+
+	Describe("Latest", func() {
+		tags := []string{"v1.2.0", "v1.10.0", "v1.11.0-rc.1", "nightly"}
+
+		It("should pick the highest stable version", func() {
+			v, found := semver.Latest(tags, false)
+			Expect(found).To(BeTrue())
+			Expect(v.String()).To(Equal("v1.10.0"))
+		})
+
+		It("should include pre-releases when asked", func() {
+			v, _ := semver.Latest(tags, true)
+			Expect(v.String()).To(Equal("v1.11.0-rc.1"))
+		})
+	})
+
+	Describe("BumpFor", func() {
+		It("should bump major for breaking changes", func() {
+			commits := []*commit.Conventional{conventional("fix: a"), conventional("feat!: b")}
+			Expect(semver.BumpFor(commits)).To(Equal(semver.BumpMajor))
+		})
+
+		It("should bump minor for features", func() {
+			commits := []*commit.Conventional{conventional("fix: a"), conventional("feat: b")}
+			Expect(semver.BumpFor(commits)).To(Equal(semver.BumpMinor))
+		})
+
+		It("should not bump for docs only", func() {
+			Expect(semver.BumpFor([]*commit.Conventional{conventional("docs: a")})).To(Equal(semver.BumpNone))
+		})
+	})
+
+	Describe("Next", func() {
+		It("should reset lower parts", func() {
+			Expect(semver.Next(parse("v1.2.3"), semver.BumpMinor, "", nil).String()).To(Equal("v1.3.0"))
+			Expect(semver.Next(parse("v1.2.3"), semver.BumpMajor, "", nil).String()).To(Equal("v2.0.0"))
+		})
+
+		It("should number pre-releases after existing ones", func() {
+			tags := []string{"v1.2.3", "v1.3.0-rc.1", "v1.3.0-rc.2"}
+			Expect(semver.Next(parse("v1.2.3"), semver.BumpMinor, "rc", tags).String()).To(Equal("v1.3.0-rc.3"))
+			Expect(semver.Next(parse("v1.2.3"), semver.BumpPatch, "rc", tags).String()).To(Equal("v1.2.4-rc.1"))
+		})
+	})
+})